@@ -4,11 +4,12 @@ go 1.16
 
 require (
 	github.com/AlecAivazis/survey/v2 v2.3.4 // indirect
-	github.com/akamensky/argparse v1.4.0 // indirect
-	github.com/awalterschulze/gographviz v2.0.3+incompatible // indirect
-	github.com/fatih/color v1.13.0 // indirect
-	github.com/knightsc/gapstone v4.0.1+incompatible // indirect
+	github.com/akamensky/argparse v1.4.0
+	github.com/awalterschulze/gographviz v2.0.3+incompatible
+	github.com/fatih/color v1.13.0
+	github.com/knightsc/gapstone v4.0.1+incompatible
 	github.com/kr/pty v1.1.4 // indirect
-	github.com/sergi/go-diff v1.2.0 // indirect
+	github.com/sergi/go-diff v1.2.0
 	golang.org/x/crypto v0.0.0-20190530122614-20be4c3c3ed5 // indirect
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -13,7 +13,11 @@ import (
 )
 
 const (
-	rootArg = "root"
+	rootArg   = "root"
+	filesArg  = "file"
+	outputArg = "output"
+	verifyArg = "verify"
+	linkerArg = "linker"
 )
 
 // ParseArguments parses arguments of the application.
@@ -23,6 +27,18 @@ func parseLocalArguments(p *argparse.Parser, args *u.Arguments) error {
 
 	args.InitArgParse(p, args, u.STRING, "r", rootArg,
 		&argparse.Options{Required: false, Help: "The root folder which contains unikernels directories "})
+	args.InitArgParse(p, args, u.STRING, "f", filesArg,
+		&argparse.Options{Required: false, Help: "Json file that contains " +
+			"the unikernels to align (groups, excluded and pinned micro-libs)"})
+	args.InitArgParse(p, args, u.STRING, "o", outputArg,
+		&argparse.Options{Required: false, Default: "",
+			Help: "The output folder of the linker scripts (one folder per group)"})
+	args.InitArgParse(p, args, u.BOOL, "v", verifyArg,
+		&argparse.Options{Required: false, Default: false,
+			Help: "Relink the unikernels with the aligned linker scripts and verify the alignment"})
+	args.InitArgParse(p, args, u.STRING, "l", linkerArg,
+		&argparse.Options{Required: false, Default: "ld",
+			Help: "The linker used to verify the alignment"})
 
 	return u.ParserWrapper(p, os.Args)
 }
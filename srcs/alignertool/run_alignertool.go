@@ -8,8 +8,10 @@ package alignertool
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"tools/srcs/alignertool/ukManager"
 	"tools/srcs/binarytool/elf64analyser"
 	u "tools/srcs/common"
@@ -31,10 +33,9 @@ func RunAligner(homeDir string) {
 	}
 
 	// Check if a json file is used or if it is via command line
-	manager := new(ukManager.Manager)
-	manager.MicroLibs = make(map[string]*ukManager.MicroLib)
+	var uks []*ukManager.Unikernel
 	if len(*args.StringArg[rootArg]) > 0 {
-		manager.Unikernels = make([]*ukManager.Unikernel, 0)
+		uks = make([]*ukManager.Unikernel, 0)
 		files, err := os.ReadDir(*args.StringArg[rootArg])
 		if err != nil {
 			u.PrintErr(err)
@@ -42,28 +43,90 @@ func RunAligner(homeDir string) {
 		for _, file := range files {
 			if file.IsDir() {
 
-				basename := filepath.Join(*args.StringArg[rootArg], file.Name())
-				if _, err := os.Stat(filepath.Join(basename, "build")); !os.IsNotExist(err) {
-					// A build folder exist
-					basename = filepath.Join(basename, "build")
-				}
-
-				manager.Unikernels = append(manager.Unikernels, &ukManager.Unikernel{
-					BuildPath: basename,
+				uks = append(uks, &ukManager.Unikernel{
+					BuildPath:    filepath.Join(*args.StringArg[rootArg], file.Name()),
+					CompareGroup: 1,
 				})
 			}
 		}
+	} else if len(*args.StringArg[filesArg]) > 0 {
+		var err error
+		uks, err = ukManager.ReadJsonFile(*args.StringArg[filesArg])
+		if err != nil {
+			u.PrintErr(err)
+		}
 	} else {
 		u.PrintErr(errors.New("argument(s) must be provided"))
 	}
 
+	// Group unikernels by alignment group
+	groups := make(map[int][]*ukManager.Unikernel)
+	for _, uk := range uks {
+		if uk.CompareGroup > 0 {
+			groups[uk.CompareGroup] = append(groups[uk.CompareGroup], uk)
+		} else {
+			u.PrintWarning("Unikernel " + uk.Name() + " does not belong to any group")
+		}
+	}
+
+	keys := make([]int, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+
+	for _, k := range keys {
+		u.PrintInfo(fmt.Sprintf("Aligning %d unikernels (group %d)", len(groups[k]), k))
+
+		// Linker scripts are written into the build folders when the root
+		// folder is used without output folder
+		ldsDir := ""
+		if len(*args.StringArg[outputArg]) > 0 || len(*args.StringArg[rootArg]) == 0 {
+			// Absolute since the verification relinks in the build folders
+			dir, err := filepath.Abs(filepath.Join(*args.StringArg[outputArg],
+				fmt.Sprintf("group_%d", k)))
+			if err != nil {
+				u.PrintErr(err)
+			}
+			ldsDir = dir
+		}
+
+		manager := alignGroup(groups[k], ldsDir)
+
+		if *args.BoolArg[verifyArg] {
+			verification := manager.VerifyAlignment(*args.StringArg[linkerArg])
+			verification.DisplayVerification()
+
+			filename := filepath.Join(ldsDir, "alignment_verification")
+			if len(ldsDir) == 0 {
+				filename = filepath.Join(*args.StringArg[rootArg], "alignment_verification")
+			}
+			if err := u.RecordDataJson(filename, verification); err != nil {
+				u.PrintWarning(err)
+			} else {
+				u.PrintOk("Verification saved into " + filename + ".json")
+			}
+		}
+	}
+}
+
+// alignGroup aligns the micro-libs of a group of unikernels and writes their
+// linker scripts into the given folder.
+//
+// It returns a pointer to the Manager of the group.
+func alignGroup(uks []*ukManager.Unikernel, ldsDir string) *ukManager.Manager {
+
+	manager := new(ukManager.Manager)
+	manager.MicroLibs = make(map[string]*ukManager.MicroLib)
+	manager.Unikernels = uks
+	manager.LdsDir = ldsDir
+
 	for _, uk := range manager.Unikernels {
 
 		uk.Analyser = new(elf64analyser.ElfAnalyser)
 		if len(uk.BuildPath) > 0 {
-			if uk.BuildPath[len(uk.BuildPath)-1] != os.PathSeparator {
-				uk.BuildPath += u.SEP
-			}
+			// Same resolution as the binary analyser (app or build folder)
+			uk.BuildPath, _ = u.BuildFolder(uk.BuildPath)
 			if err := uk.GetFiles(); err != nil {
 				u.PrintErr(err)
 			}
@@ -76,8 +139,19 @@ func RunAligner(homeDir string) {
 			}
 		}
 
-		manager.ComputeAlignment(*uk)
+		if err := manager.ComputeAlignment(*uk); err != nil {
+			u.PrintErr(err)
+		}
+
+		// Pinned micro-libs are shared by the whole group
+		for _, lib := range uk.PinnedLibs {
+			if !u.Contains(manager.PinnedLibs, lib) {
+				manager.PinnedLibs = append(manager.PinnedLibs, lib)
+			}
+		}
 	}
 
 	manager.PerformAlignement()
+
+	return manager
 }
@@ -0,0 +1,67 @@
+// Copyright 2019 The UNICORE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// Author: Gaulthier Gain <gaulthier.gain@uliege.be>
+
+package ukManager
+
+import (
+	"debug/elf"
+	"fmt"
+	"tools/srcs/binarytool/elf64analyser"
+	"tools/srcs/binarytool/elf64core"
+	u "tools/srcs/common"
+)
+
+// ArchConstants contains the architecture-specific values used to align
+// micro-libs.
+type ArchConstants struct {
+	// Address of the first aligned micro-lib (0 to compute it from the ELF file)
+	StartValue uint64
+	// Offsets added to the rodata size and to the size of some micro-libs
+	RodataOffsets map[string]uint64
+	SizeOffsets   map[string]uint64
+}
+
+var archConstants = map[elf.Machine]*ArchConstants{
+	elf.EM_X86_64: {
+		StartValue:    0x107000,
+		RodataOffsets: map[string]uint64{ukkvmPlat: 0x10},
+		SizeOffsets:   map[string]uint64{libnewlibc: 0x22}, // 0x12 for 9 apps
+	},
+	elf.EM_AARCH64: {
+		StartValue:    0,
+		RodataOffsets: map[string]uint64{},
+		SizeOffsets:   map[string]uint64{},
+	},
+}
+
+// getArchConstants returns the alignment constants of the architecture of an
+// ELF file. Unknown architectures do not use any offset.
+//
+// It returns a pointer to an ArchConstants structure.
+func getArchConstants(elfFile *elf64core.ELF64File) *ArchConstants {
+
+	constants, ok := archConstants[elfFile.Machine()]
+	if !ok {
+		u.PrintWarning(fmt.Sprintf("No alignment constants for %s, no offset is used",
+			elfFile.Machine()))
+		constants = &ArchConstants{
+			RodataOffsets: map[string]uint64{},
+			SizeOffsets:   map[string]uint64{},
+		}
+	}
+
+	if constants.StartValue > 0 {
+		return constants
+	}
+
+	// Use the page following the beginning of the text section (boot code)
+	arch := *constants
+	if index, ok := elfFile.IndexSections[elf64core.TextSection]; ok {
+		addr := elfFile.SectionsTable.DataSect[index].Elf64section.VirtualAddress
+		arch.StartValue = roundAddr(addr, elf64analyser.PageSize)
+	}
+	return &arch
+}
@@ -7,9 +7,15 @@
 package ukManager
 
 import (
+	"crypto/sha256"
+	"debug/elf"
+	"encoding/hex"
 	"strings"
+	"tools/srcs/binarytool/elf64core"
 )
 
+const hashLength = 12
+
 func stringInSlice(name string, plats []string) bool {
 	for _, plat := range plats {
 		if strings.Contains(name, plat) {
@@ -18,3 +24,41 @@ func stringInSlice(name string, plats []string) bool {
 	}
 	return false
 }
+
+// libHash computes the hash of the content of the sections of a micro-lib
+// (object file) which are loaded into memory.
+//
+// It returns the hash and an error if any, otherwise it returns nil.
+func libHash(obj *elf64core.ELF64File) (string, error) {
+
+	h := sha256.New()
+	for i, s := range obj.SectionsTable.DataSect {
+		header := s.Elf64section
+		if header.Flags&uint64(elf.SHF_ALLOC) == 0 ||
+			header.Type == uint32(elf.SHT_NOBITS) {
+			continue
+		}
+		content, err := obj.GetSectionContent(uint16(i))
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte(s.Name))
+		h.Write(content)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// libKey returns the key of a micro-lib variant.
+func libKey(name, hash string) string {
+	if len(hash) == 0 {
+		return name
+	}
+	return name + "@" + shortHash(hash)
+}
+
+func shortHash(hash string) string {
+	if len(hash) > hashLength {
+		return hash[:hashLength]
+	}
+	return hash
+}
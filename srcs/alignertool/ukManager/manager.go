@@ -1,11 +1,14 @@
 package ukManager
 
 import (
+	"debug/elf"
 	"fmt"
 	"math"
+	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"text/tabwriter"
 	"tools/srcs/binarytool/elf64analyser"
 	"tools/srcs/binarytool/elf64core"
 	u "tools/srcs/common"
@@ -21,10 +24,18 @@ type Manager struct {
 	Unikernels      []*Unikernel
 	MicroLibs       map[string]*MicroLib
 	SortedMicroLibs []*MicroLib //Used for the display
+	Arch            *ArchConstants
+	machine         elf.Machine
+
+	// Micro-libs which are placed first (in this order)
+	PinnedLibs []string
+	// Folder of the linker scripts (empty to write them into the build folders)
+	LdsDir string
 }
 
 type MicroLib struct {
 	name        string
+	hash        string
 	startAddr   uint64
 	size        uint64
 	instance    int
@@ -42,7 +53,21 @@ type SectionMicroLibs struct {
 	bssAddr    uint64
 }
 
-func (manager *Manager) ComputeAlignment(unikernel Unikernel) {
+func (manager *Manager) ComputeAlignment(unikernel Unikernel) error {
+
+	// All the unikernels must target the same architecture
+	if manager.Arch == nil {
+		manager.machine = unikernel.ElfFile.Machine()
+		manager.Arch = getArchConstants(unikernel.ElfFile)
+	} else if manager.machine != unikernel.ElfFile.Machine() {
+		return fmt.Errorf("cannot align %s (%s) with %s unikernels",
+			unikernel.ElfFile.Name, unikernel.ElfFile.Machine(), manager.machine)
+	}
+
+	objs := make(map[string]*elf64core.ELF64File, len(unikernel.ListObjs))
+	for _, obj := range unikernel.ListObjs {
+		objs[obj.Name] = obj
+	}
 
 	for _, libs := range unikernel.Analyser.ElfLibs {
 
@@ -51,31 +76,43 @@ func (manager *Manager) ComputeAlignment(unikernel Unikernel) {
 			continue
 		}
 
+		// Micro-libs are identified by their content: variants of a
+		// micro-lib (same name, different content) are considered as
+		// different micro-libs
+		hash := ""
+		if obj, ok := objs[libs.Name]; ok {
+			var err error
+			if hash, err = libHash(obj); err != nil {
+				return err
+			}
+		}
+		key := libKey(libs.Name, hash)
+		if u.Contains(unikernel.ExcludedLibs, libs.Name) {
+			// Excluded micro-libs are never shared with other unikernels
+			key += "#" + unikernel.id()
+		}
+
 		// Add microlib to a global map per instance
-		if val, ok := manager.MicroLibs[libs.Name]; ok {
-			if manager.MicroLibs[libs.Name].size != libs.Size {
-				//u.PrintWarning(fmt.Sprintf("Different size between %s (0x%x) (0x%x)", libs.Name, libs.Size, val.size))
+		if val, ok := manager.MicroLibs[key]; ok {
+			if val.size != libs.Size {
 				if val.size < libs.Size {
 					u.PrintWarning(fmt.Sprintf("Bigger size found %s (0x%x) > (0x%x)", libs.Name, libs.Size, val.size))
-					manager.MicroLibs[libs.Name].size = libs.Size
-					manager.MicroLibs[libs.Name].sectionSize.rodataSize = libs.RodataSize
-					manager.MicroLibs[libs.Name].sectionSize.dataSize = libs.DataSize
-					manager.MicroLibs[libs.Name].sectionSize.bssSize = libs.BssSize
+					val.size = libs.Size
+					val.sectionSize.rodataSize = libs.RodataSize
+					val.sectionSize.dataSize = libs.DataSize
+					val.sectionSize.bssSize = libs.BssSize
 				}
-				//todo handle case where microlibs do not have same content -> use hash instead of name
 			}
-			manager.MicroLibs[libs.Name].instance += 1
+			val.instance += 1
+			val.usedBy = append(val.usedBy, unikernel.id())
 		} else {
-			// Add offset
-			if libs.Name == ukkvmPlat {
-				libs.RodataSize += 0x10
-			} else if libs.Name == libnewlibc {
-				libs.Size += 0x22
-				//libs.Size += 0x12 // for 9 apps
-			}
+			// Add architecture-specific offset
+			libs.RodataSize += manager.Arch.RodataOffsets[libs.Name]
+			libs.Size += manager.Arch.SizeOffsets[libs.Name]
 
 			mlib := &MicroLib{
 				name:      libs.Name,
+				hash:      hash,
 				startAddr: libs.StartAddr,
 				size:      libs.Size,
 				instance:  1,
@@ -84,10 +121,13 @@ func (manager *Manager) ComputeAlignment(unikernel Unikernel) {
 					dataSize:   libs.DataSize,
 					bssSize:    libs.BssSize,
 				},
+				usedBy: []string{unikernel.id()},
 			}
-			manager.MicroLibs[libs.Name] = mlib
+			manager.MicroLibs[key] = mlib
 		}
 	}
+
+	return nil
 }
 
 func (manager *Manager) sortMicroLibs() {
@@ -95,15 +135,19 @@ func (manager *Manager) sortMicroLibs() {
 		key      string
 		instance int
 		addr     uint64
+		pinned   int
 	}
 	i := 0
 	var kvSlice = make([]kv, len(manager.MicroLibs))
 	for k, v := range manager.MicroLibs {
-		kvSlice[i] = kv{k, v.instance, v.startAddr}
+		kvSlice[i] = kv{k, v.instance, v.startAddr, manager.pinnedIndex(v)}
 		i++
 	}
 
 	sort.Slice(kvSlice, func(i, j int) bool {
+		if kvSlice[i].pinned != kvSlice[j].pinned {
+			return kvSlice[i].pinned < kvSlice[j].pinned
+		}
 		if kvSlice[i].instance != kvSlice[j].instance {
 			return kvSlice[i].instance > kvSlice[j].instance
 		}
@@ -116,6 +160,23 @@ func (manager *Manager) sortMicroLibs() {
 	}
 }
 
+// pinnedIndex returns the position of a micro-lib in the pinned list or the
+// length of this list if the micro-lib is not pinned.
+func (manager *Manager) pinnedIndex(lib *MicroLib) int {
+	for i, name := range manager.PinnedLibs {
+		if lib.name == name {
+			return i
+		}
+	}
+	return len(manager.PinnedLibs)
+}
+
+// isShared returns true if the micro-lib is aligned at the same address in
+// several unikernels (used by several unikernels or pinned).
+func (manager *Manager) isShared(lib *MicroLib) bool {
+	return lib.instance > 1 || manager.pinnedIndex(lib) < len(manager.PinnedLibs)
+}
+
 func (manager *Manager) DisplayMicroLibs() {
 	if manager.SortedMicroLibs == nil {
 		manager.sortMicroLibs()
@@ -124,6 +185,49 @@ func (manager *Manager) DisplayMicroLibs() {
 	for i, lib := range manager.SortedMicroLibs {
 		fmt.Printf("%d %s: %x - %x - %d\n", i, lib.name, lib.startAddr, lib.size, lib.instance)
 	}
+	manager.DisplayVariants()
+}
+
+// DisplayVariants displays the micro-libs which have several variants (same
+// name but different content) and the unikernels which use each variant.
+func (manager *Manager) DisplayVariants() {
+
+	variants := make(map[string][]*MicroLib)
+	names := make([]string, 0)
+	for _, lib := range manager.MicroLibs {
+		if _, ok := variants[lib.name]; !ok {
+			names = append(names, lib.name)
+		}
+		variants[lib.name] = append(variants[lib.name], lib)
+	}
+	sort.Strings(names)
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 8, 1, '\t', 0)
+	header := false
+	for _, name := range names {
+		// Excluded micro-libs are not variants (same content)
+		hashes := make(map[string]bool)
+		for _, lib := range variants[name] {
+			hashes[lib.hash] = true
+		}
+		if len(hashes) < 2 {
+			continue
+		}
+		if !header {
+			fmt.Println("-----------------------------------------------------------------------")
+			_, _ = fmt.Fprintln(w, "Micro-lib\tVariant\tSize\tUnikernels")
+			header = true
+		}
+		sort.Slice(variants[name], func(i, j int) bool {
+			return variants[name][i].instance > variants[name][j].instance
+		})
+		for _, lib := range variants[name] {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t0x%x\t%s\n", name, shortHash(lib.hash),
+				lib.size, strings.Join(lib.usedBy, ", "))
+		}
+	}
+	_ = w.Flush()
 }
 
 func (manager *Manager) updateRodataInner(maxValSection map[string]uint64, linkerInfoGlobal *LinkerInfo) {
@@ -132,7 +236,7 @@ func (manager *Manager) updateRodataInner(maxValSection map[string]uint64, linke
 	for _, lib := range manager.SortedMicroLibs {
 
 		fmt.Printf("%s 0x%x \n", lib.name, lib.sectionSize.rodataSize)
-		if lib.instance > 1 {
+		if manager.isShared(lib) {
 			// Update inner rodata location counter
 			lib.sectionSize.rodataAddr = linkerInfoGlobal.rodataAddr
 			linkerInfoGlobal.rodataAddr += roundAddr(lib.sectionSize.rodataSize, 32)
@@ -148,7 +252,7 @@ func (manager *Manager) updateDataInner(maxValSection map[string]uint64, linkerI
 	var initData = linkerInfoGlobal.dataAddr
 	for _, lib := range manager.SortedMicroLibs {
 
-		if lib.instance > 1 {
+		if manager.isShared(lib) {
 			// Update inner dataAddr location counter
 			lib.sectionSize.dataAddr = linkerInfoGlobal.dataAddr
 			linkerInfoGlobal.dataAddr += roundAddr(lib.sectionSize.dataSize, 32)
@@ -167,7 +271,7 @@ func (manager *Manager) updateBssInner(maxValSection map[string]uint64, linkerIn
 	var initBss = linkerInfoGlobal.bssAddr
 	for _, lib := range manager.SortedMicroLibs {
 
-		if lib.instance > 1 {
+		if manager.isShared(lib) {
 			// Update inner bssAddr location counter
 			lib.sectionSize.bssAddr = linkerInfoGlobal.bssAddr
 			linkerInfoGlobal.bssAddr += roundAddr(lib.sectionSize.bssSize, 32)
@@ -179,7 +283,12 @@ func (manager *Manager) updateBssInner(maxValSection map[string]uint64, linkerIn
 
 func (manager *Manager) PerformAlignement() {
 
-	var startValue uint64 = 0x107000
+	if len(manager.Unikernels) == 0 || manager.Arch == nil {
+		u.PrintWarning("No unikernel to align")
+		return
+	}
+
+	var startValue = manager.Arch.StartValue
 	var locationCnt = startValue
 	commonMicroLibs := make([]*MicroLib, 0)
 
@@ -192,15 +301,18 @@ func (manager *Manager) PerformAlignement() {
 
 	// Update micro-libs mapping globally and per unikernels
 	for i, lib := range manager.SortedMicroLibs {
-		if lib.instance == len(manager.Unikernels) {
-			// micro-libs common to all instances
+		pinned := manager.pinnedIndex(lib) < len(manager.PinnedLibs)
+		if lib.instance == len(manager.Unikernels) || pinned {
+			// micro-libs common to all instances (pinned micro-libs are
+			// placed as common ones even if some instances do not use them)
 			lib.startAddr = locationCnt
 			commonMicroLibs = append(commonMicroLibs, lib)
 			//locationCnt += lib.size
 			locationCnt += roundAddr(locationCnt, elf64analyser.PageSize)
 		} else if lib.instance > 1 {
 			// micro-libs common to particular instances
-			if manager.SortedMicroLibs[i-1].instance == len(manager.Unikernels) {
+			if i > 0 && len(commonMicroLibs) > 0 &&
+				commonMicroLibs[len(commonMicroLibs)-1] == manager.SortedMicroLibs[i-1] {
 				// Add ukboot main after all common micro-libs
 				locationCnt = roundAddr(locationCnt, elf64analyser.PageSize)
 				ukbootMainLib := &MicroLib{
@@ -240,6 +352,10 @@ func (manager *Manager) PerformAlignement() {
 	for _, uk := range manager.Unikernels {
 
 		// Update the locationCnt by finding the maximum one from unikernel (the biggest size)
+		if uk.alignedLibs == nil {
+			// All the micro-libs of this unikernel are common
+			uk.InitAlignment()
+		}
 		uk.alignedLibs.AllCommonMicroLibs = commonMicroLibs
 		if locationCnt < uk.alignedLibs.startValueUk {
 			locationCnt = uk.alignedLibs.startValueUk
@@ -275,13 +391,21 @@ func (manager *Manager) PerformAlignement() {
 	for _, uk := range manager.Unikernels {
 
 		var filename string
-		if !strings.Contains("libkvmplat", uk.BuildPath) {
+		if len(manager.LdsDir) > 0 {
+			// One set of linker scripts per group
+			folder := filepath.Join(manager.LdsDir, uk.Name())
+			if err := os.MkdirAll(folder, u.PERM); err != nil {
+				u.PrintErr(err)
+			}
+			filename = filepath.Join(folder, "link64_out.lds")
+		} else if !strings.Contains("libkvmplat", uk.BuildPath) {
 			filename = filepath.Join(uk.BuildPath, "libkvmplat", "link64_out.lds")
 		} else {
 			filename = filepath.Join(uk.BuildPath, "link64_out.lds")
 		}
 
 		u.PrintInfo("Writing aligned linker script into: " + filename)
+		uk.ldsFile = filename
 		uk.writeTextAlignment(startValue)
 
 		// todo remove and replace per uk.buildpath
@@ -39,12 +39,25 @@ type Unikernel struct {
 	ComputeTextAddr string   `json:"computeTextAddr"`
 	LibsMapping     []string `json:"LibsMapping"`
 
+	// Unikernels of the same group are aligned together
+	CompareGroup int `json:"compareGroup"`
+	// Object files which are not taken into account (e.g., other platforms)
+	IgnoredPlats []string `json:"ignoredPlats"`
+	// Micro-libs of this unikernel which are never shared with other ones
+	ExcludedLibs []string `json:"excludedLibs"`
+	// Micro-libs which are always placed first in the group
+	PinnedLibs []string `json:"pinnedLibs"`
+
 	ElfFile  *elf64core.ELF64File
 	ListObjs []*elf64core.ELF64File
 	Analyser *elf64analyser.ElfAnalyser
 
 	alignedLibs *AlignedLibs
 	strBuilder  strings.Builder
+
+	// Used to verify the alignment
+	ldsFile      string
+	plannedAddrs map[string]uint64
 }
 
 type AlignedLibs struct {
@@ -104,7 +117,8 @@ func (uk *Unikernel) GetFiles() error {
 	for _, f := range files {
 
 		if f.IsDir() || strings.Contains(f.Name(), makefile) ||
-			strings.Contains(f.Name(), config) {
+			strings.Contains(f.Name(), config) ||
+			u.StringInSlice(f.Name(), uk.IgnoredPlats) {
 			continue
 		}
 
@@ -153,15 +167,44 @@ func (uk *Unikernel) InitAlignment() {
 	}
 }
 
+// id returns the identifier of a unikernel (its build path or its kernel).
+func (uk *Unikernel) id() string {
+	if len(uk.BuildPath) > 0 {
+		return uk.BuildPath
+	}
+	return uk.Kernel
+}
+
+// Name returns the name of a unikernel (the name of its folder).
+func (uk *Unikernel) Name() string {
+	name := filepath.Base(filepath.Clean(uk.id()))
+	if name == "build" {
+		name = filepath.Base(filepath.Dir(filepath.Clean(uk.id())))
+	}
+	return strings.TrimSuffix(name, dbgExt)
+}
+
+// usesMicroLib returns true if the unikernel uses the given variant of a
+// micro-lib.
+func (uk *Unikernel) usesMicroLib(lib *MicroLib) bool {
+	return u.Contains(lib.usedBy, uk.id())
+}
+
+// usesCommonMicroLib returns true if the unikernel uses the given common
+// micro-lib (ukbootMain is used by all the unikernels).
+func (uk *Unikernel) usesCommonMicroLib(lib *MicroLib) bool {
+	return lib.usedBy == nil || uk.usesMicroLib(lib)
+}
+
 func (uk *Unikernel) AddAlignedMicroLibs(startValue uint64, lib *MicroLib) {
-	if _, ok := uk.Analyser.MapElfLibs[lib.name]; ok {
+	if uk.usesMicroLib(lib) {
 		lib.startAddr = startValue
 		uk.alignedLibs.OnlyFewMicroLibs = append(uk.alignedLibs.OnlyFewMicroLibs, lib)
 	}
 }
 
 func (uk *Unikernel) AddSingleMicroLibs(startValue uint64, lib *MicroLib) {
-	if _, ok := uk.Analyser.MapElfLibs[lib.name]; ok {
+	if uk.usesMicroLib(lib) {
 
 		uk.alignedLibs.SingleMicroLibs = append(uk.alignedLibs.SingleMicroLibs, lib)
 		if uk.alignedLibs.startValueInit == 0 {
@@ -178,17 +221,28 @@ func (uk *Unikernel) writeTextAlignment(startValue uint64) {
 	uk.strBuilder.WriteString("SECTIONS\n{\n")
 	uk.strBuilder.WriteString(fmt.Sprintf(" . = 0x%x;\n", startValue))
 
+	// Keep the address of each micro-lib to verify the alignment
+	uk.plannedAddrs = make(map[string]uint64)
+
 	startValueInit := startValue
 	for _, lib := range uk.alignedLibs.AllCommonMicroLibs {
-		uk.strBuilder.WriteString(fmt.Sprintf(" .text.%s 0x%x: {\n\t %s(.text);\n }\n", strings.Replace(lib.name, ldExt, "", -1), startValueInit, lib.name))
+		// Pinned micro-libs keep their slot even if they are not used
+		if uk.usesCommonMicroLib(lib) {
+			uk.strBuilder.WriteString(fmt.Sprintf(" .text.%s 0x%x: {\n\t %s(.text);\n }\n", strings.Replace(lib.name, ldExt, "", -1), startValueInit, lib.name))
+			uk.plannedAddrs[lib.name] = startValueInit
+		}
 		startValueInit += lib.size
 	}
 
 	for _, lib := range uk.alignedLibs.OnlyFewMicroLibs {
 		uk.strBuilder.WriteString(fmt.Sprintf(" .text.%s 0x%x: {\n\t %s(.text);\n }\n", strings.Replace(lib.name, ldExt, "", -1), lib.startAddr, lib.name))
+		uk.plannedAddrs[lib.name] = lib.startAddr
 	}
 
-	uk.strBuilder.WriteString(fmt.Sprintf(" . = 0x%x;\n", uk.alignedLibs.startValueInit))
+	if uk.alignedLibs.startValueInit > 0 {
+		// The unikernel has single micro-libs
+		uk.strBuilder.WriteString(fmt.Sprintf(" . = 0x%x;\n", uk.alignedLibs.startValueInit))
+	}
 
 	for _, lib := range uk.alignedLibs.SingleMicroLibs {
 		uk.strBuilder.WriteString(fmt.Sprintf(" .text.%s : {\n\t %s(.text);\n }\n", strings.Replace(lib.name, ldExt, "", -1), lib.name))
@@ -202,8 +256,8 @@ func (uk *Unikernel) sectionsObjs(linkerInfo LinkerInfo) string {
 	// 0: rodata, 1: data, 2: bss
 	strBuilder := [3]strings.Builder{}
 	for _, obj := range uk.alignedLibs.AllCommonMicroLibs {
-		if obj.name == ukbootMain {
-			// Ignore ukbootMain
+		if obj.name == ukbootMain || !uk.usesCommonMicroLib(obj) {
+			// Ignore ukbootMain and unused pinned micro-libs
 			continue
 		}
 		strBuilder[0].WriteString(fmt.Sprintf(". = ABSOLUTE(0x%x);%s (.rodata);\n", obj.sectionSize.rodataAddr, obj.name))
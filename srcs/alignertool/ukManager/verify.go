@@ -0,0 +1,279 @@
+// Copyright 2019 The UNICORE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// Author: Gaulthier Gain <gaulthier.gain@uliege.be>
+
+package ukManager
+
+import (
+	"crypto/sha256"
+	"debug/elf"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"tools/srcs/binarytool/elf64analyser"
+	"tools/srcs/binarytool/elf64core"
+	u "tools/srcs/common"
+)
+
+const alignedSuffix = "_aligned"
+
+type Verification struct {
+	Unikernels  []*UnikernelCheck `json:"unikernels"`
+	TotalPages  int               `json:"totalPages"`
+	TotalFrames int               `json:"totalFrames"`
+	SharedPages int               `json:"sharedPages"`
+}
+
+type UnikernelCheck struct {
+	Name        string          `json:"name"`
+	Image       string          `json:"image"`
+	Error       string          `json:"error,omitempty"`
+	Aligned     int             `json:"aligned"`
+	Misaligned  []*Misalignment `json:"misaligned"`
+	Missing     []string        `json:"missing"`
+	TotalPages  int             `json:"totalPages"`
+	SharedPages int             `json:"sharedPages"`
+	// Addresses of the pages which are found in at least another image
+	IdenticalPages []uint64 `json:"identicalPages"`
+
+	pages map[uint64][32]byte
+}
+
+type Misalignment struct {
+	Lib     string `json:"lib"`
+	Planned uint64 `json:"planned"`
+	Actual  uint64 `json:"actual"`
+}
+
+// relink links the objects of a unikernel with its aligned linker script
+// and saves the new image into the given folder.
+//
+// It returns the path of the new image and an error if any, otherwise it
+// returns nil.
+func (uk *Unikernel) relink(linker, folder string) (string, error) {
+
+	if len(uk.ldsFile) == 0 {
+		return "", errors.New("no aligned linker script for " + uk.BuildPath)
+	}
+
+	if len(uk.ListObjs) == 0 {
+		return "", errors.New("no object file found in " + uk.BuildPath)
+	}
+
+	// The linker runs in the build folder: the script and the output must not
+	// be relative to the current folder
+	ldsFile, err := filepath.Abs(uk.ldsFile)
+	if err != nil {
+		return "", err
+	}
+	name := filepath.Base(uk.ElfFile.Name)
+	output, err := filepath.Abs(filepath.Join(folder,
+		strings.TrimSuffix(name, filepath.Ext(name))+alignedSuffix+dbgExt))
+	if err != nil {
+		return "", err
+	}
+
+	args := []string{"-nostdlib", "-T", ldsFile, "-o", output}
+	for _, obj := range uk.ListObjs {
+		// The linker script refers to the object files by their name
+		args = append(args, obj.Name)
+	}
+
+	cmd := exec.Command(linker, args...)
+	cmd.Dir = uk.BuildPath
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("%s failed: %v\n%s", linker, err, out)
+	}
+
+	return output, nil
+}
+
+// checkAddresses checks that each aligned micro-lib is located at its planned
+// address in the relinked image.
+func (uk *Unikernel) checkAddresses(image *elf64core.ELF64File, check *UnikernelCheck) {
+
+	names := make([]string, 0, len(uk.plannedAddrs))
+	for name := range uk.plannedAddrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		section := elf64core.TextSection + "." + strings.Replace(name, ldExt, "", -1)
+		index, ok := image.IndexSections[section]
+		if !ok {
+			check.Missing = append(check.Missing, name)
+			continue
+		}
+
+		actual := image.SectionsTable.DataSect[index].Elf64section.VirtualAddress
+		if actual != uk.plannedAddrs[name] {
+			check.Misaligned = append(check.Misaligned, &Misalignment{
+				Lib:     name,
+				Planned: uk.plannedAddrs[name],
+				Actual:  actual,
+			})
+			continue
+		}
+		check.Aligned++
+	}
+}
+
+// memoryPages splits the loaded sections of an ELF file into memory pages.
+//
+// It returns a map of the hash of each page indexed by its virtual address.
+func memoryPages(elfFile *elf64core.ELF64File) map[uint64][32]byte {
+
+	contents := make(map[uint64][]byte)
+	for _, s := range elfFile.SectionsTable.DataSect {
+		header := s.Elf64section
+		if header.Flags&uint64(elf.SHF_ALLOC) == 0 ||
+			header.Type == uint32(elf.SHT_NOBITS) || header.Size == 0 {
+			continue
+		}
+
+		end := header.FileOffset + header.Size
+		if end > uint64(len(elfFile.Raw)) {
+			continue
+		}
+		raw := elfFile.Raw[header.FileOffset:end]
+
+		// A page may contain several sections
+		for addr := header.VirtualAddress; addr < header.VirtualAddress+header.Size; {
+			page := addr &^ (elf64analyser.PageSize - 1)
+			if _, ok := contents[page]; !ok {
+				contents[page] = make([]byte, elf64analyser.PageSize)
+			}
+			next := page + elf64analyser.PageSize
+			if next > header.VirtualAddress+header.Size {
+				next = header.VirtualAddress + header.Size
+			}
+			copy(contents[page][addr-page:], raw[addr-header.VirtualAddress:next-header.VirtualAddress])
+			addr = next
+		}
+	}
+
+	pages := make(map[uint64][32]byte, len(contents))
+	for addr, content := range contents {
+		pages[addr] = sha256.Sum256(content)
+	}
+	return pages
+}
+
+// VerifyAlignment relinks each unikernel with its aligned linker script,
+// checks that the micro-libs are located at their planned address and
+// computes the pages which are identical across the relinked images (pages
+// which can be merged by KSM).
+//
+// It returns a pointer to a Verification structure.
+func (manager *Manager) VerifyAlignment(linker string) *Verification {
+
+	verification := &Verification{Unikernels: make([]*UnikernelCheck, 0)}
+
+	for _, uk := range manager.Unikernels {
+
+		check := &UnikernelCheck{
+			Name:       uk.BuildPath,
+			Misaligned: make([]*Misalignment, 0),
+			Missing:    make([]string, 0),
+		}
+		verification.Unikernels = append(verification.Unikernels, check)
+
+		// Images of different groups are saved beside their linker script
+		folder := uk.BuildPath
+		if len(manager.LdsDir) > 0 {
+			folder = filepath.Dir(uk.ldsFile)
+		}
+
+		output, err := uk.relink(linker, folder)
+		if err != nil {
+			check.Error = err.Error()
+			u.PrintWarning(err)
+			continue
+		}
+		check.Image = output
+		u.PrintOk("Unikernel relinked into: " + output)
+
+		image, err := parseFile(filepath.Dir(output)+u.SEP, filepath.Base(output))
+		if err != nil {
+			check.Error = err.Error()
+			u.PrintWarning(err)
+			continue
+		}
+
+		uk.checkAddresses(image, check)
+		check.pages = memoryPages(image)
+		check.TotalPages = len(check.pages)
+	}
+
+	// Count the number of images which contain each page
+	nbImages := make(map[[32]byte]int)
+	for _, check := range verification.Unikernels {
+		seen := make(map[[32]byte]bool)
+		for _, hash := range check.pages {
+			if !seen[hash] {
+				nbImages[hash]++
+				seen[hash] = true
+			}
+		}
+	}
+
+	frames := make(map[[32]byte]bool)
+	for _, check := range verification.Unikernels {
+		check.IdenticalPages = make([]uint64, 0)
+		for addr, hash := range check.pages {
+			if nbImages[hash] > 1 {
+				check.SharedPages++
+				check.IdenticalPages = append(check.IdenticalPages, addr)
+			}
+			frames[hash] = true
+		}
+		sort.Slice(check.IdenticalPages, func(i, j int) bool {
+			return check.IdenticalPages[i] < check.IdenticalPages[j]
+		})
+		verification.TotalPages += check.TotalPages
+		verification.SharedPages += check.SharedPages
+	}
+	verification.TotalFrames = len(frames)
+
+	return verification
+}
+
+func (verification *Verification) DisplayVerification() {
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 8, 1, '\t', 0)
+	fmt.Println("-----------------------------------------------------------------------")
+	_, _ = fmt.Fprintln(w, "Unikernel\tAligned libs\tMisaligned libs\tMissing libs\tPages\tShared pages")
+	for _, check := range verification.Unikernels {
+		if len(check.Error) > 0 {
+			_, _ = fmt.Fprintf(w, "%s\t-\t-\t-\t-\t-\n", check.Name)
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\n", check.Name, check.Aligned,
+			len(check.Misaligned), len(check.Missing), check.TotalPages, check.SharedPages)
+	}
+	_ = w.Flush()
+
+	for _, check := range verification.Unikernels {
+		for _, m := range check.Misaligned {
+			u.PrintWarning(fmt.Sprintf("%s: %s is at 0x%x instead of 0x%x", check.Name,
+				m.Lib, m.Actual, m.Planned))
+		}
+		for _, lib := range check.Missing {
+			u.PrintWarning(fmt.Sprintf("%s: %s is not found in the relinked image",
+				check.Name, lib))
+		}
+	}
+
+	fmt.Printf("- Total pages: %d\n", verification.TotalPages)
+	fmt.Printf("- Pages identical across images: %d\n", verification.SharedPages)
+	fmt.Printf("- Physical pages required with page sharing: %d\n", verification.TotalFrames)
+}
@@ -13,9 +13,15 @@ import (
 )
 
 const (
-	filesArg   = "file"
-	mappingArg = "mapping"
-	rootArg    = "root"
+	filesArg     = "file"
+	mappingArg   = "mapping"
+	rootArg      = "root"
+	formatArg    = "format"
+	outputArg    = "output"
+	diffArg      = "diff"
+	callGraphArg = "callgraph"
+	deadArg      = "dead"
+	sourcesArg   = "sources"
 )
 
 // ParseArguments parses arguments of the application.
@@ -26,11 +32,31 @@ func parseLocalArguments(p *argparse.Parser, args *u.Arguments) error {
 	args.InitArgParse(p, args, u.BOOL, "m", mappingArg,
 		&argparse.Options{Required: false, Default: false,
 			Help: "Display libraries mapping (required -l argument)"})
+	args.InitArgParse(p, args, u.BOOL, "s", sourcesArg,
+		&argparse.Options{Required: false, Default: false,
+			Help: "Display the size of each source file and directory " +
+				"(requires debug information)"})
 	args.InitArgParse(p, args, u.STRING, "r", rootArg,
 		&argparse.Options{Required: false, Help: "The root folder which contains build directories "})
 	args.InitArgParse(p, args, u.STRING, "f", filesArg,
 		&argparse.Options{Required: false, Help: "Json file that contains " +
 			"the information for the binary analyser"})
+	args.InitArgParse(p, args, u.BOOL, "c", callGraphArg,
+		&argparse.Options{Required: false, Default: false,
+			Help: "Generate the call graph of each unikernel"})
+	args.InitArgParse(p, args, u.BOOL, "", deadArg,
+		&argparse.Options{Required: false, Default: false,
+			Help: "Find the functions of each unikernel which are unreachable " +
+				"from the entry points"})
+	args.InitArgParse(p, args, u.STRINGLIST, "d", diffArg,
+		&argparse.Options{Required: false, Help: "Compare two kernel images " +
+			"or build directories (-d first -d second)"})
+	args.InitArgParse(p, args, u.STRING, "", formatArg,
+		&argparse.Options{Required: false, Help: "Save a report of each " +
+			"unikernel in the given format (json|csv)"})
+	args.InitArgParse(p, args, u.STRING, "o", outputArg,
+		&argparse.Options{Required: false, Default: ".",
+			Help: "The output folder of the reports"})
 
 	return u.ParserWrapper(p, os.Args)
 }
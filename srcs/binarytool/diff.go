@@ -0,0 +1,142 @@
+// Copyright 2019 The UNICORE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// Author: Gaulthier Gain <gaulthier.gain@uliege.be>
+
+package binarytool
+
+import (
+	"encoding/csv"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"tools/srcs/binarytool/elf64analyser"
+	"tools/srcs/binarytool/elf64core"
+	u "tools/srcs/common"
+)
+
+// newDiffUnikernel creates a unikernel from a path which is either a kernel
+// image or a build directory and parses its ELF file(s).
+//
+// It returns a pointer to a DiffTarget structure and an error if any,
+// otherwise it returns nil.
+func newDiffUnikernel(path string) (*elf64analyser.DiffTarget, error) {
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	uk := new(Unikernel)
+	if info.IsDir() {
+		uk.BuildPath = path
+	} else {
+		uk.Kernel = path
+	}
+
+	if err := uk.loadElfFiles(); err != nil {
+		return nil, err
+	}
+
+	return &elf64analyser.DiffTarget{
+		Name:     path,
+		ElfFile:  uk.ElfFile,
+		Analyser: uk.Analyser,
+	}, nil
+}
+
+// runDiff compares two unikernels (kernel images or build directories) and
+// displays their differences. The differences are also saved if a format is
+// given.
+//
+// It returns an error if any, otherwise it returns nil.
+func (manager *BinaryManager) runDiff(paths []string, format, outFolder string) error {
+
+	if len(paths) != 2 {
+		return errors.New("the diff mode requires exactly two kernel images " +
+			"or build directories")
+	}
+
+	if err := checkFormat(format); err != nil {
+		return err
+	}
+
+	targets := make([]*elf64analyser.DiffTarget, len(paths))
+	for i, path := range paths {
+		var err error
+		if targets[i], err = newDiffUnikernel(path); err != nil {
+			return err
+		}
+	}
+
+	u.PrintInfo("Computing differences between " + paths[0] + " and " + paths[1])
+	diff := elf64analyser.ComputeDiff(targets[0], targets[1],
+		[]string{elf64core.TextSection, elf64core.RodataSection,
+			elf64core.DataSection})
+	diff.DisplayDiff()
+
+	if len(format) == 0 {
+		return nil
+	}
+
+	if _, err := u.CreateFolder(outFolder); err != nil {
+		return err
+	}
+
+	filename := filepath.Join(outFolder, "diff_"+filepath.Base(paths[0])+"_"+
+		filepath.Base(paths[1]))
+	if format == jsonFormat {
+		if err := u.RecordDataJson(filename, diff); err != nil {
+			return err
+		}
+		filename += ".json"
+	} else {
+		filename += ".csv"
+		if err := writeDiffCsv(filename, diff); err != nil {
+			return err
+		}
+	}
+
+	u.PrintOk("Diff saved into " + filename)
+	return nil
+}
+
+// writeDiffCsv writes the differences between two unikernels into a csv file.
+// Each row describes one difference (lib, function, section or pages) with the
+// following columns: table, name, status, old, new, delta. For pages, the
+// delta column contains the number of changed pages.
+//
+// It returns an error if any, otherwise it returns nil.
+func writeDiffCsv(filename string, diff *elf64analyser.ElfDiff) error {
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	itoa := func(v uint64) string { return strconv.FormatUint(v, 10) }
+	dtoa := func(v int64) string { return strconv.FormatInt(v, 10) }
+
+	records := [][]string{{"table", "name", "status", "old", "new", "delta"}}
+	for _, l := range diff.Libs {
+		records = append(records, []string{"lib", l.Name, l.Status,
+			itoa(l.OldSize), itoa(l.NewSize), dtoa(l.Delta)})
+	}
+	for _, f := range diff.Functions {
+		records = append(records, []string{"function", f.Name, f.Status,
+			itoa(f.OldSize), itoa(f.NewSize), dtoa(f.Delta)})
+	}
+	for _, s := range diff.Sections {
+		records = append(records, []string{"section", s.Name, s.Status,
+			itoa(s.OldSize), itoa(s.NewSize), dtoa(s.Delta)})
+	}
+	for _, p := range diff.Pages {
+		records = append(records, []string{"pages", p.Section, "",
+			strconv.Itoa(p.OldPages), strconv.Itoa(p.NewPages), strconv.Itoa(p.Changed)})
+	}
+
+	return csv.NewWriter(file).WriteAll(records)
+}
@@ -8,6 +8,7 @@ package elf64analyser
 
 import (
 	"crypto/sha256"
+	"debug/elf"
 	"encoding/hex"
 	"fmt"
 	"os"
@@ -19,21 +20,22 @@ import (
 )
 
 type ElfAnalyser struct {
-	ElfLibs    []ElfLibs
-	ElfPage    []*ElfPage
-	MapElfLibs map[string]*ElfLibs
+	ElfLibs        []ElfLibs
+	ElfPage        []*ElfPage
+	MapElfLibs     map[string]*ElfLibs
+	SourcesMapping *SourcesMapping
 }
 
 type ElfLibs struct {
-	Name      string
-	StartAddr uint64
-	EndAddr   uint64
-	Size      uint64
-	NbSymbols int
-
-	RodataSize uint64
-	DataSize   uint64
-	BssSize    uint64
+	Name      string `json:"name"`
+	StartAddr uint64 `json:"startAddr"`
+	EndAddr   uint64 `json:"endAddr"`
+	Size      uint64 `json:"size"`
+	NbSymbols int    `json:"nbSymbols"`
+
+	RodataSize uint64 `json:"rodataSize"`
+	DataSize   uint64 `json:"dataSize"`
+	BssSize    uint64 `json:"bssSize"`
 }
 
 func (analyser *ElfAnalyser) DisplayMapping() {
@@ -229,6 +231,11 @@ func CreateNewPage(startAddress uint64, k int, raw []byte) *ElfPage {
 		startAddress:     startAddress,
 		contentByteArray: byteArray,
 	}
+	for _, b := range byteArray {
+		if b != 0 {
+			page.noNullValues++
+		}
+	}
 	h := sha256.New()
 	h.Write(page.contentByteArray)
 	page.hash = hex.EncodeToString(h.Sum(nil))
@@ -236,13 +243,23 @@ func CreateNewPage(startAddress uint64, k int, raw []byte) *ElfPage {
 }
 
 func (analyser *ElfAnalyser) computePage(elfFile *elf64core.ELF64File, section string, indexSection int) {
+	if elfFile.SectionsTable.DataSect[indexSection].Elf64section.Type == uint32(elf.SHT_NOBITS) {
+		u.PrintWarning(fmt.Sprintf("Section %s has no content in file, skip it", section))
+		return
+	}
+
 	offsetTextSection := elfFile.SectionsTable.DataSect[indexSection].Elf64section.FileOffset
+	endSection := offsetTextSection + elfFile.SectionsTable.DataSect[indexSection].Elf64section.Size
+	if endSection > uint64(len(elfFile.Raw)) {
+		endSection = uint64(len(elfFile.Raw))
+	}
 	k := 0
-	for i := offsetTextSection; i < offsetTextSection+elfFile.SectionsTable.DataSect[indexSection].Elf64section.Size; i += PageSize {
+	for i := offsetTextSection; i < endSection; i += PageSize {
 
+		// Only keep the content of the section (the remaining is filled with 0)
 		end := i + PageSize
-		if end >= uint64(len(elfFile.Raw)) {
-			end = uint64(len(elfFile.Raw) - 1)
+		if end > endSection {
+			end = endSection
 		}
 		page := CreateNewPage(i, k, elfFile.Raw[i:end])
 		page.sectionName = section
@@ -0,0 +1,391 @@
+// Copyright 2019 The UNICORE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// Author: Gaulthier Gain <gaulthier.gain@uliege.be>
+
+package elf64analyser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"tools/srcs/binarytool/elf64core"
+)
+
+const (
+	added   = "added"
+	removed = "removed"
+	changed = "changed"
+)
+
+type DiffTarget struct {
+	Name     string
+	ElfFile  *elf64core.ELF64File
+	Analyser *ElfAnalyser
+}
+
+type ElfDiff struct {
+	First     string `json:"first"`
+	Second    string `json:"second"`
+	SizeDelta int64  `json:"sizeDelta"`
+	TextDelta int64  `json:"textDelta"`
+
+	Libs      []*LibDiff      `json:"libs"`
+	Functions []*FunctionDiff `json:"functions"`
+	Sections  []*SectionDiff  `json:"sections"`
+	Pages     []*PagesDiff    `json:"pages"`
+}
+
+type LibDiff struct {
+	Name        string `json:"name"`
+	Status      string `json:"status"`
+	OldSize     uint64 `json:"oldSize"`
+	NewSize     uint64 `json:"newSize"`
+	Delta       int64  `json:"delta"`
+	RodataDelta int64  `json:"rodataDelta"`
+	DataDelta   int64  `json:"dataDelta"`
+	BssDelta    int64  `json:"bssDelta"`
+}
+
+type FunctionDiff struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	OldSize uint64 `json:"oldSize"`
+	NewSize uint64 `json:"newSize"`
+	Delta   int64  `json:"delta"`
+}
+
+type SectionDiff struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	OldSize uint64 `json:"oldSize"`
+	NewSize uint64 `json:"newSize"`
+	Delta   int64  `json:"delta"`
+}
+
+type PagesDiff struct {
+	Section      string         `json:"section"`
+	OldPages     int            `json:"oldPages"`
+	NewPages     int            `json:"newPages"`
+	Identical    int            `json:"identical"`
+	Changed      int            `json:"changed"`
+	ChangedPages []uint64       `json:"changedPages"`
+	ChangedLibs  map[string]int `json:"changedLibs"`
+}
+
+func delta(oldSize, newSize uint64) int64 {
+	return int64(newSize) - int64(oldSize)
+}
+
+func abs(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// status returns the status of an entry depending on its presence in the
+// first and in the second unikernel. An empty string is returned if the entry
+// has not changed.
+func status(inFirst, inSecond bool, oldSize, newSize uint64) string {
+	if !inFirst {
+		return added
+	} else if !inSecond {
+		return removed
+	} else if oldSize != newSize {
+		return changed
+	}
+	return ""
+}
+
+// sortedKeys returns the union of the keys of two maps sorted by name.
+func sortedKeys(first, second map[string]uint64) []string {
+	keys := make([]string, 0, len(first)+len(second))
+	for k := range first {
+		keys = append(keys, k)
+	}
+	for k := range second {
+		if _, ok := first[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func mapLibs(analyser *ElfAnalyser) map[string]ElfLibs {
+	libs := make(map[string]ElfLibs, len(analyser.ElfLibs))
+	for _, lib := range analyser.ElfLibs {
+		libs[filepath.Base(lib.Name)] = lib
+	}
+	return libs
+}
+
+func (diff *ElfDiff) diffLibs(first, second *ElfAnalyser) {
+
+	firstLibs, secondLibs := mapLibs(first), mapLibs(second)
+	firstSizes := make(map[string]uint64, len(firstLibs))
+	for name, lib := range firstLibs {
+		firstSizes[name] = lib.Size
+	}
+	secondSizes := make(map[string]uint64, len(secondLibs))
+	for name, lib := range secondLibs {
+		secondSizes[name] = lib.Size
+	}
+
+	for _, name := range sortedKeys(firstSizes, secondSizes) {
+		oldLib, inFirst := firstLibs[name]
+		newLib, inSecond := secondLibs[name]
+		s := status(inFirst, inSecond, oldLib.Size, newLib.Size)
+		if len(s) == 0 && oldLib.RodataSize == newLib.RodataSize &&
+			oldLib.DataSize == newLib.DataSize && oldLib.BssSize == newLib.BssSize {
+			continue
+		} else if len(s) == 0 {
+			s = changed
+		}
+		diff.Libs = append(diff.Libs, &LibDiff{
+			Name:        name,
+			Status:      s,
+			OldSize:     oldLib.Size,
+			NewSize:     newLib.Size,
+			Delta:       delta(oldLib.Size, newLib.Size),
+			RodataDelta: delta(oldLib.RodataSize, newLib.RodataSize),
+			DataDelta:   delta(oldLib.DataSize, newLib.DataSize),
+			BssDelta:    delta(oldLib.BssSize, newLib.BssSize),
+		})
+	}
+
+	sort.SliceStable(diff.Libs, func(i, j int) bool {
+		return abs(diff.Libs[i].Delta) > abs(diff.Libs[j].Delta)
+	})
+}
+
+// mapFunctions returns the size of each function of an ELF file. Functions
+// which share the same name (e.g., static functions) are accumulated.
+func mapFunctions(elfFile *elf64core.ELF64File) map[string]uint64 {
+	functions := make(map[string]uint64)
+	for _, table := range elfFile.FunctionsTables {
+		for _, f := range table.Functions {
+			functions[f.Name] += f.Size
+		}
+	}
+	return functions
+}
+
+func (diff *ElfDiff) diffFunctions(first, second *elf64core.ELF64File) {
+
+	firstFcts, secondFcts := mapFunctions(first), mapFunctions(second)
+	for _, name := range sortedKeys(firstFcts, secondFcts) {
+		oldSize, inFirst := firstFcts[name]
+		newSize, inSecond := secondFcts[name]
+		if s := status(inFirst, inSecond, oldSize, newSize); len(s) > 0 {
+			diff.Functions = append(diff.Functions, &FunctionDiff{
+				Name:    name,
+				Status:  s,
+				OldSize: oldSize,
+				NewSize: newSize,
+				Delta:   delta(oldSize, newSize),
+			})
+		}
+	}
+
+	sort.SliceStable(diff.Functions, func(i, j int) bool {
+		return abs(diff.Functions[i].Delta) > abs(diff.Functions[j].Delta)
+	})
+}
+
+func mapSections(elfFile *elf64core.ELF64File) map[string]uint64 {
+	sections := make(map[string]uint64, len(elfFile.SectionsTable.DataSect))
+	for _, s := range elfFile.SectionsTable.DataSect {
+		if len(s.Name) > 0 {
+			sections[s.Name] = s.Elf64section.Size
+		}
+	}
+	return sections
+}
+
+func (diff *ElfDiff) diffSections(first, second *elf64core.ELF64File) {
+
+	firstSects, secondSects := mapSections(first), mapSections(second)
+	for _, name := range sortedKeys(firstSects, secondSects) {
+		oldSize, inFirst := firstSects[name]
+		newSize, inSecond := secondSects[name]
+		if s := status(inFirst, inSecond, oldSize, newSize); len(s) > 0 {
+			diff.Sections = append(diff.Sections, &SectionDiff{
+				Name:    name,
+				Status:  s,
+				OldSize: oldSize,
+				NewSize: newSize,
+				Delta:   delta(oldSize, newSize),
+			})
+		}
+	}
+}
+
+// splitPages splits the given sections of an ELF file into pages and returns
+// them grouped by section name.
+func splitPages(elfFile *elf64core.ELF64File, sections []string) map[string][]*ElfPage {
+	analyser := new(ElfAnalyser)
+	for _, name := range sections {
+		analyser.SplitIntoPagesBySection(elfFile, name)
+	}
+
+	pages := make(map[string][]*ElfPage)
+	for _, p := range analyser.ElfPage {
+		pages[p.sectionName] = append(pages[p.sectionName], p)
+	}
+	return pages
+}
+
+// virtualAddress converts the file offset of a page into its virtual address.
+func virtualAddress(elfFile *elf64core.ELF64File, p *ElfPage) uint64 {
+	if index, ok := elfFile.IndexSections[p.sectionName]; ok {
+		s := elfFile.SectionsTable.DataSect[index].Elf64section
+		return s.VirtualAddress + p.startAddress - s.FileOffset
+	}
+	return p.startAddress
+}
+
+func (diff *ElfDiff) diffPages(first, second *DiffTarget, sections []string) {
+
+	firstPages := splitPages(first.ElfFile, sections)
+	secondPages := splitPages(second.ElfFile, sections)
+
+	names := make([]string, 0, len(firstPages)+len(secondPages))
+	for name := range firstPages {
+		names = append(names, name)
+	}
+	for name := range secondPages {
+		if _, ok := firstPages[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		oldPages, newPages := firstPages[name], secondPages[name]
+		pagesDiff := &PagesDiff{
+			Section:      name,
+			OldPages:     len(oldPages),
+			NewPages:     len(newPages),
+			ChangedPages: make([]uint64, 0),
+			ChangedLibs:  make(map[string]int),
+		}
+
+		// A page is identical if its content is found in the old section
+		// (whatever its position)
+		hashes := make(map[string]bool, len(oldPages))
+		for _, p := range oldPages {
+			hashes[p.hash] = true
+		}
+
+		for _, p := range newPages {
+			if hashes[p.hash] {
+				pagesDiff.Identical++
+				continue
+			}
+
+			pagesDiff.Changed++
+			addr := virtualAddress(second.ElfFile, p)
+			pagesDiff.ChangedPages = append(pagesDiff.ChangedPages, addr)
+
+			// Attribute the changed page to the micro-libs it contains
+			for _, lib := range second.Analyser.ElfLibs {
+				if lib.StartAddr < addr+PageSize && addr < lib.EndAddr {
+					pagesDiff.ChangedLibs[filepath.Base(lib.Name)]++
+				}
+			}
+		}
+		diff.Pages = append(diff.Pages, pagesDiff)
+	}
+}
+
+// ComputeDiff computes the differences between two unikernels: micro-libs
+// added, removed or resized, functions added, removed or resized, section
+// size changes and pages which have changed for the given sections.
+//
+// It returns a pointer to an ElfDiff structure.
+func ComputeDiff(first, second *DiffTarget, sections []string) *ElfDiff {
+
+	diff := &ElfDiff{
+		First:     first.Name,
+		Second:    second.Name,
+		Libs:      make([]*LibDiff, 0),
+		Functions: make([]*FunctionDiff, 0),
+		Sections:  make([]*SectionDiff, 0),
+		Pages:     make([]*PagesDiff, 0),
+	}
+
+	firstStat := first.Analyser.ComputeStatSize(first.ElfFile)
+	secondStat := second.Analyser.ComputeStatSize(second.ElfFile)
+	diff.SizeDelta = delta(firstStat.TotalSizeElf, secondStat.TotalSizeElf)
+	diff.TextDelta = delta(firstStat.TotalSizeText, secondStat.TotalSizeText)
+
+	diff.diffLibs(first.Analyser, second.Analyser)
+	diff.diffFunctions(first.ElfFile, second.ElfFile)
+	diff.diffSections(first.ElfFile, second.ElfFile)
+	diff.diffPages(first, second, sections)
+
+	return diff
+}
+
+func (diff *ElfDiff) DisplayDiff() {
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 8, 1, '\t', 0)
+	fmt.Println("-----------------------------------------------------------------------")
+	fmt.Printf("Differences between %s and %s:\n", diff.First, diff.Second)
+	fmt.Printf("- Size delta (all sections): %+d bytes\n", diff.SizeDelta)
+	fmt.Printf("- Size delta (.text): %+d bytes\n", diff.TextDelta)
+
+	if len(diff.Libs) > 0 {
+		_, _ = fmt.Fprintln(w, "\nMicro-lib\tStatus\tOld size\tNew size\tDelta\tRodata\tData\tBss")
+		for _, l := range diff.Libs {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t0x%x\t0x%x\t%+d\t%+d\t%+d\t%+d\n", l.Name, l.Status,
+				l.OldSize, l.NewSize, l.Delta, l.RodataDelta, l.DataDelta, l.BssDelta)
+		}
+	}
+
+	if len(diff.Functions) > 0 {
+		_, _ = fmt.Fprintln(w, "\nFunction\tStatus\tOld size\tNew size\tDelta")
+		for _, f := range diff.Functions {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t0x%x\t0x%x\t%+d\n", f.Name, f.Status,
+				f.OldSize, f.NewSize, f.Delta)
+		}
+	}
+
+	if len(diff.Sections) > 0 {
+		_, _ = fmt.Fprintln(w, "\nSection\tStatus\tOld size\tNew size\tDelta")
+		for _, s := range diff.Sections {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t0x%x\t0x%x\t%+d\n", s.Name, s.Status,
+				s.OldSize, s.NewSize, s.Delta)
+		}
+	}
+
+	if len(diff.Pages) > 0 {
+		_, _ = fmt.Fprintln(w, "\nSection\tOld pages\tNew pages\tIdentical\tChanged\tMicro-libs (changed pages)")
+		for _, p := range diff.Pages {
+			libs := make([]string, 0, len(p.ChangedLibs))
+			for name := range p.ChangedLibs {
+				libs = append(libs, name)
+			}
+			sort.Slice(libs, func(i, j int) bool {
+				return p.ChangedLibs[libs[i]] > p.ChangedLibs[libs[j]]
+			})
+
+			str := ""
+			for i, name := range libs {
+				if i > 0 {
+					str += ", "
+				}
+				str += fmt.Sprintf("%s (%d)", name, p.ChangedLibs[name])
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\n", p.Section, p.OldPages,
+				p.NewPages, p.Identical, p.Changed, str)
+		}
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,178 @@
+// Copyright 2019 The UNICORE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// Author: Gaulthier Gain <gaulthier.gain@uliege.be>
+
+package elf64analyser
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+type SharingStats struct {
+	Group      int                      `json:"group"`
+	Unikernels []string                 `json:"unikernels"`
+	Total      *PagesSharing            `json:"total"`
+	Sections   map[string]*PagesSharing `json:"sections"`
+	Pairs      []*PairSharing           `json:"pairs"`
+}
+
+type PagesSharing struct {
+	TotalPages   int     `json:"totalPages"`
+	SharedPages  int     `json:"sharedPages"`
+	SharingPages int     `json:"sharingPages"`
+	ZeroPages    int     `json:"zeroPages"`
+	TotalFrames  int     `json:"totalFrames"`
+	Ratio        float64 `json:"ratio"`
+}
+
+type PairSharing struct {
+	First       string  `json:"first"`
+	Second      string  `json:"second"`
+	FirstPages  int     `json:"firstPages"`
+	SecondPages int     `json:"secondPages"`
+	SharedPages int     `json:"sharedPages"`
+	Ratio       float64 `json:"ratio"`
+}
+
+// computePagesSharing computes the sharing stats of a set of pages. A page is
+// considered as sharing if its content (hash) is found at least twice within
+// the whole group (dictSamePage).
+func (comparison *ComparisonElf) computePagesSharing(pages []*ElfPage) *PagesSharing {
+
+	stats := &PagesSharing{TotalPages: len(pages)}
+	frames := make(map[string]bool)
+	for _, p := range pages {
+		if comparison.dictSamePage[p.hash] > 1 {
+			if _, ok := frames[p.hash]; !ok {
+				stats.SharedPages++
+			}
+			stats.SharingPages++
+		}
+		if p.noNullValues == 0 {
+			stats.ZeroPages++
+		}
+		frames[p.hash] = true
+	}
+
+	stats.TotalFrames = len(frames)
+	if stats.TotalPages > 0 {
+		stats.Ratio = (float64(stats.SharingPages) / float64(stats.TotalPages)) * 100
+	}
+
+	return stats
+}
+
+// computePairSharing computes the number of distinct pages that are shared
+// between two unikernels.
+func computePairSharing(first, second *ElfFileSegment) *PairSharing {
+
+	pair := &PairSharing{
+		First:       first.Filename,
+		Second:      second.Filename,
+		FirstPages:  len(first.Pages),
+		SecondPages: len(second.Pages),
+	}
+
+	hashes := make(map[string]bool, len(first.Pages))
+	for _, p := range first.Pages {
+		hashes[p.hash] = true
+	}
+
+	for _, p := range second.Pages {
+		if shared, ok := hashes[p.hash]; ok && shared {
+			pair.SharedPages++
+			// Count each distinct page only once
+			hashes[p.hash] = false
+		}
+	}
+
+	if pair.FirstPages+pair.SecondPages > 0 {
+		pair.Ratio = (float64(2*pair.SharedPages) /
+			float64(pair.FirstPages+pair.SecondPages)) * 100
+	}
+
+	return pair
+}
+
+// ComputeSharingStats computes the pages sharing stats of the group in total,
+// per section and per pair of unikernels. ComparePageTables must be called
+// before.
+//
+// It returns a pointer to a SharingStats structure.
+func (comparison *ComparisonElf) ComputeSharingStats(group int) *SharingStats {
+
+	if comparison.dictSamePage == nil {
+		comparison.ComparePageTables()
+	}
+
+	stats := &SharingStats{
+		Group:      group,
+		Unikernels: make([]string, 0, len(comparison.GroupFileSegment)),
+		Sections:   make(map[string]*PagesSharing),
+		Pairs:      make([]*PairSharing, 0),
+	}
+
+	allPages := make([]*ElfPage, 0)
+	sectionPages := make(map[string][]*ElfPage)
+	for _, file := range comparison.GroupFileSegment {
+		stats.Unikernels = append(stats.Unikernels, file.Filename)
+		allPages = append(allPages, file.Pages...)
+		for _, p := range file.Pages {
+			sectionPages[p.sectionName] = append(sectionPages[p.sectionName], p)
+		}
+	}
+
+	stats.Total = comparison.computePagesSharing(allPages)
+	for name, pages := range sectionPages {
+		stats.Sections[name] = comparison.computePagesSharing(pages)
+	}
+
+	for i := 0; i < len(comparison.GroupFileSegment); i++ {
+		for j := i + 1; j < len(comparison.GroupFileSegment); j++ {
+			stats.Pairs = append(stats.Pairs, computePairSharing(
+				comparison.GroupFileSegment[i], comparison.GroupFileSegment[j]))
+		}
+	}
+
+	return stats
+}
+
+func (stats *SharingStats) DisplaySharingStats() {
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 8, 1, '\t', 0)
+	fmt.Println("-----------------------------------------------------------------------")
+	fmt.Printf("Pages sharing of group %d (%d unikernels):\n", stats.Group, len(stats.Unikernels))
+
+	_, _ = fmt.Fprintln(w, "\nSection\tPages\tShared\tSharing\tZeroes\tFrames\tRatio (%)")
+	sections := make([]string, 0, len(stats.Sections))
+	for name := range stats.Sections {
+		sections = append(sections, name)
+	}
+	sort.Strings(sections)
+	for _, name := range sections {
+		s := stats.Sections[name]
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%.2f\n", name, s.TotalPages,
+			s.SharedPages, s.SharingPages, s.ZeroPages, s.TotalFrames, s.Ratio)
+	}
+	_, _ = fmt.Fprintf(w, "Total\t%d\t%d\t%d\t%d\t%d\t%.2f\n", stats.Total.TotalPages,
+		stats.Total.SharedPages, stats.Total.SharingPages, stats.Total.ZeroPages,
+		stats.Total.TotalFrames, stats.Total.Ratio)
+
+	if len(stats.Pairs) > 0 {
+		_, _ = fmt.Fprintln(w, "\nFirst\tSecond\tPages\tShared\tRatio (%)")
+		for _, p := range stats.Pairs {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%d/%d\t%d\t%.2f\n", p.First, p.Second,
+				p.FirstPages, p.SecondPages, p.SharedPages, p.Ratio)
+		}
+	}
+	_ = w.Flush()
+
+	fmt.Printf("- Total MB (after deduplication): %.2f\n",
+		float64(stats.Total.TotalFrames*PageSize)/(1024*1024))
+}
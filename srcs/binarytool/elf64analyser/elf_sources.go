@@ -0,0 +1,136 @@
+// Copyright 2019 The UNICORE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// Author: Gaulthier Gain <gaulthier.gain@uliege.be>
+
+package elf64analyser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"tools/srcs/binarytool/elf64core"
+	u "tools/srcs/common"
+)
+
+type SourcesMapping struct {
+	Files []*SourceSize `json:"files"`
+	Dirs  []*SourceSize `json:"dirs"`
+}
+
+type SourceSize struct {
+	Name        string `json:"name"`
+	TextSize    uint64 `json:"textSize"`
+	DataSize    uint64 `json:"dataSize"`
+	NbFunctions int    `json:"nbFunctions"`
+	NbVariables int    `json:"nbVariables"`
+}
+
+func (s *SourceSize) total() uint64 {
+	return s.TextSize + s.DataSize
+}
+
+func sortSources(sources map[string]*SourceSize) []*SourceSize {
+	sorted := make([]*SourceSize, 0, len(sources))
+	for _, s := range sources {
+		sorted = append(sorted, s)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].total() != sorted[j].total() {
+			return sorted[i].total() > sorted[j].total()
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+// InspectSourcesMapping parses the debug information of an ELF file and
+// attributes the size of each function and global variable to its source file
+// and directory. Entries without declaration file are attributed to the source
+// file of their compilation unit.
+//
+// It returns an error if any, otherwise it returns nil.
+func (analyser *ElfAnalyser) InspectSourcesMapping(elfFile *elf64core.ELF64File) error {
+
+	if elfFile.DwarfUnits == nil {
+		if err := elfFile.ParseDwarf(); err != nil {
+			return err
+		}
+	}
+
+	files := make(map[string]*SourceSize)
+	dirs := make(map[string]*SourceSize)
+	// Functions and variables can be described by several units (e.g.,
+	// inlined functions), only count each address once.
+	seen := make(map[uint64]bool)
+
+	add := func(unit *elf64core.DwarfUnit, e elf64core.DwarfEntry, isFunction bool) {
+		if seen[e.Addr] {
+			return
+		}
+		seen[e.Addr] = true
+
+		file := e.File
+		if len(file) == 0 {
+			file = unit.SourceFile()
+		}
+		for _, name := range []string{file, filepath.Dir(file)} {
+			sources := files
+			if name != file {
+				sources = dirs
+			}
+			if _, ok := sources[name]; !ok {
+				sources[name] = &SourceSize{Name: name}
+			}
+			if isFunction {
+				sources[name].TextSize += e.Size
+				sources[name].NbFunctions++
+			} else {
+				sources[name].DataSize += e.Size
+				sources[name].NbVariables++
+			}
+		}
+	}
+
+	for _, unit := range elfFile.DwarfUnits {
+		for _, f := range unit.Functions {
+			add(unit, f, true)
+		}
+		for _, v := range unit.Variables {
+			add(unit, v, false)
+		}
+	}
+
+	analyser.SourcesMapping = &SourcesMapping{
+		Files: sortSources(files),
+		Dirs:  sortSources(dirs),
+	}
+
+	return nil
+}
+
+func displaySources(w *tabwriter.Writer, title string, sources []*SourceSize) {
+	_, _ = fmt.Fprintf(w, "\n%s\tText\tData\tTotal\t#Functions\t#Variables\n", title)
+	for _, s := range sources {
+		_, _ = fmt.Fprintf(w, "%s\t0x%x\t0x%x\t0x%x\t%d\t%d\n", s.Name, s.TextSize,
+			s.DataSize, s.total(), s.NbFunctions, s.NbVariables)
+	}
+}
+
+func (analyser *ElfAnalyser) DisplaySourcesMapping() {
+
+	if analyser.SourcesMapping == nil || len(analyser.SourcesMapping.Files) == 0 {
+		u.PrintWarning("Sources mapping is empty")
+		return
+	}
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 8, 1, '\t', 0)
+	fmt.Println("-----------------------------------------------------------------------")
+	displaySources(w, "Directory", analyser.SourcesMapping.Dirs)
+	displaySources(w, "File", analyser.SourcesMapping.Files)
+	_ = w.Flush()
+}
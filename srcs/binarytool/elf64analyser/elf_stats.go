@@ -174,83 +174,141 @@ func (analyser *ElfAnalyser) DisplaySectionInfo(elfFile *elf64core.ELF64File, in
 	_ = w.Flush()
 }
 
-func (analyser *ElfAnalyser) FindSectionByAddress(elfFile *elf64core.ELF64File, addresses []string) {
-	if len(elfFile.SectionsTable.DataSect) == 0 {
-		u.PrintWarning("Sections table is empty")
-		return
-	}
+type AddressSection struct {
+	Address  string   `json:"address"`
+	Sections []string `json:"sections"`
+}
+
+// ResolveAddresses finds the section(s) which contain each given address
+// (hexadecimal string). Addresses which cannot be converted are skipped.
+//
+// It returns a slice of AddressSection structures.
+func (analyser *ElfAnalyser) ResolveAddresses(elfFile *elf64core.ELF64File, addresses []string) []AddressSection {
+
+	resolved := make([]AddressSection, 0, len(addresses))
 	for _, addr := range addresses {
 		hexStr := strings.Replace(addr, "0x", "", -1)
 		intAddr, err := strconv.ParseUint(hexStr, 16, 64)
 		if err != nil {
 			u.PrintWarning(fmt.Sprintf("Error %s: Cannot convert %s to integer. Skip.", err, addr))
-		} else {
-			found := false
-			for _, s := range elfFile.SectionsTable.DataSect {
-				if s.Elf64section.VirtualAddress <= intAddr && intAddr < s.Elf64section.VirtualAddress+s.Elf64section.Size {
-					fmt.Printf("Address %s is in section %s\n", addr, s.Name)
-					found = true
-				}
-			}
-			if !found {
-				u.PrintWarning(fmt.Sprintf("Cannot find a section for address: %s", addr))
+			continue
+		}
+
+		addrSection := AddressSection{Address: addr, Sections: make([]string, 0)}
+		for _, s := range elfFile.SectionsTable.DataSect {
+			if s.Elf64section.VirtualAddress <= intAddr && intAddr < s.Elf64section.VirtualAddress+s.Elf64section.Size {
+				addrSection.Sections = append(addrSection.Sections, s.Name)
 			}
 		}
+		resolved = append(resolved, addrSection)
 	}
+
+	return resolved
 }
 
-func (analyser *ElfAnalyser) DisplayStatSize(elfFile *elf64core.ELF64File) {
+func (analyser *ElfAnalyser) FindSectionByAddress(elfFile *elf64core.ELF64File, addresses []string) {
 	if len(elfFile.SectionsTable.DataSect) == 0 {
 		u.PrintWarning("Sections table is empty")
 		return
 	}
-	w := new(tabwriter.Writer)
-	w.Init(os.Stdout, 0, 8, 0, '\t', 0)
+	for _, r := range analyser.ResolveAddresses(elfFile, addresses) {
+		for _, name := range r.Sections {
+			fmt.Printf("Address %s is in section %s\n", r.Address, name)
+		}
+		if len(r.Sections) == 0 {
+			u.PrintWarning(fmt.Sprintf("Cannot find a section for address: %s", r.Address))
+		}
+	}
+}
 
-	var totalSizeText uint64
-	var totalSizeElf uint64
-	_, _ = fmt.Fprintf(w, "-----------------------------------------------------------------------\n")
-	_, _ = fmt.Fprintf(w, "Name\tVirtual Size (Bytes/Hex) \t#pages\tInfos:\n")
+type StatSize struct {
+	Sections      []SectionSize `json:"sections"`
+	TotalSizeText uint64        `json:"totalSizeText"`
+	TotalSizeElf  uint64        `json:"totalSizeElf"`
+}
+
+type SectionSize struct {
+	Name    string  `json:"name"`
+	Address uint64  `json:"address"`
+	Size    uint64  `json:"size"`
+	Pages   float32 `json:"pages"`
+	Next    string  `json:"next,omitempty"`
+}
 
-	// Sort by addresses
-	dataSec := elfFile.SectionsTable.DataSect
-	sort.Slice(dataSec, func(i, j int) bool {
+// ComputeStatSize computes the virtual size of each loaded section of an ELF
+// file (from its address to the address of the next section) and the total
+// sizes of the unikernel.
+//
+// It returns a pointer to a StatSize structure.
+func (analyser *ElfAnalyser) ComputeStatSize(elfFile *elf64core.ELF64File) *StatSize {
+
+	stats := &StatSize{Sections: make([]SectionSize, 0)}
+
+	// Sort by addresses (use a copy to keep the indexes of the sections table)
+	dataSec := make([]*elf64core.DataSections, len(elfFile.SectionsTable.DataSect))
+	copy(dataSec, elfFile.SectionsTable.DataSect)
+	sort.SliceStable(dataSec, func(i, j int) bool {
 		return dataSec[i].Elf64section.VirtualAddress < dataSec[j].Elf64section.VirtualAddress
 	})
 
-	for i, s := range elfFile.SectionsTable.DataSect { //&uint64(elf.SHF_WRITE)
-		if s.Elf64section.VirtualAddress > 0 {
-
-			var size uint64
-			var currNext = ""
-			if strings.Contains(s.Name, elf64core.IntrstackSection) || strings.Contains(s.Name, elf64core.TbssSection) {
-				size = s.Elf64section.Size
-				totalSizeElf += size
+	for i, s := range dataSec {
+		if s.Elf64section.VirtualAddress == 0 {
+			continue
+		}
 
-			} else {
-				size = dataSec[i+1].Elf64section.VirtualAddress -
-					dataSec[i].Elf64section.VirtualAddress
-				totalSizeElf += size
-				currNext = fmt.Sprintf("0x%x -> 0x%x : (%s)-> (%s)", dataSec[i].Elf64section.VirtualAddress,
-					dataSec[i+1].Elf64section.VirtualAddress, dataSec[i].Name, dataSec[i+1].Name)
-			}
+		var size uint64
+		var currNext = ""
+		last := i+1 >= len(dataSec)
+		if last || strings.Contains(s.Name, elf64core.IntrstackSection) ||
+			strings.Contains(s.Name, elf64core.TbssSection) {
+			size = s.Elf64section.Size
+		} else {
+			size = dataSec[i+1].Elf64section.VirtualAddress - s.Elf64section.VirtualAddress
+			currNext = fmt.Sprintf("0x%x -> 0x%x : (%s)-> (%s)", s.Elf64section.VirtualAddress,
+				dataSec[i+1].Elf64section.VirtualAddress, s.Name, dataSec[i+1].Name)
+		}
+		stats.TotalSizeElf += size
 
-			if strings.Contains(s.Name, elf64core.TextSection) && strings.Contains(dataSec[i+1].Name, elf64core.TextSection) {
-				totalSizeText += size
-			} else if strings.Contains(s.Name, elf64core.TextSection) {
+		if strings.Contains(s.Name, elf64core.TextSection) {
+			if last || !strings.Contains(dataSec[i+1].Name, elf64core.TextSection) {
 				// Main application code
 				size = s.Elf64section.Size
-				totalSizeText += size
 			}
-			_, _ = fmt.Fprintf(w, "%s\t%d (0x%x)\t%.2f\t%s\n", s.Name, size, size, float32(size)/float32(PageSize), currNext)
-
+			stats.TotalSizeText += size
 		}
+
+		stats.Sections = append(stats.Sections, SectionSize{
+			Name:    s.Name,
+			Address: s.Elf64section.VirtualAddress,
+			Size:    size,
+			Pages:   float32(size) / float32(PageSize),
+			Next:    currNext,
+		})
+	}
+
+	return stats
+}
+
+func (analyser *ElfAnalyser) DisplayStatSize(elfFile *elf64core.ELF64File) {
+	if len(elfFile.SectionsTable.DataSect) == 0 {
+		u.PrintWarning("Sections table is empty")
+		return
+	}
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 8, 0, '\t', 0)
+
+	_, _ = fmt.Fprintf(w, "-----------------------------------------------------------------------\n")
+	_, _ = fmt.Fprintf(w, "Name\tVirtual Size (Bytes/Hex) \t#pages\tInfos:\n")
+
+	stats := analyser.ComputeStatSize(elfFile)
+	for _, s := range stats.Sections {
+		_, _ = fmt.Fprintf(w, "%s\t%d (0x%x)\t%.2f\t%s\n", s.Name, s.Size, s.Size, s.Pages, s.Next)
 	}
 
 	_, _ = fmt.Fprintf(w, "----------------------\t----------------------\t------\t----------------------------\n")
 	_, _ = fmt.Fprintf(w, "Total Size of this unikernel:\n")
-	_, _ = fmt.Fprintf(w, "Section .text:\t%d (0x%x)\n", totalSizeText, totalSizeText)
-	_, _ = fmt.Fprintf(w, "All sections of this unikernel:\t%d (0x%x)\n", totalSizeElf, totalSizeElf)
+	_, _ = fmt.Fprintf(w, "Section .text:\t%d (0x%x)\n", stats.TotalSizeText, stats.TotalSizeText)
+	_, _ = fmt.Fprintf(w, "All sections of this unikernel:\t%d (0x%x)\n", stats.TotalSizeElf, stats.TotalSizeElf)
 
 	/*_, _ = fmt.Fprintf(w, "#Pages (.text) of this unikernel:\t%d\n", roundPage(float64(totalSizeText)/float64(PageSize)))
 	_, _ = fmt.Fprintf(w, "#Pages (all sections) of this unikernel:\t%d\n", roundPage(float64(totalSizeElf)/float64(PageSize)))*/
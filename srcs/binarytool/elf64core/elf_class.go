@@ -0,0 +1,261 @@
+// Copyright 2019 The UNICORE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// Author: Gaulthier Gain <gaulthier.gain@uliege.be>
+
+package elf64core
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"strconv"
+)
+
+// The ELF64 structures are used internally for both classes. The following
+// structures are only used to read ELF32 files and are then converted.
+
+type ELF32Header struct {
+	Ident                  [identLength]byte
+	Type                   uint16
+	Machine                uint16
+	Version                uint32
+	EntryPoint             uint32
+	ProgramHeaderOffset    uint32
+	SectionHeaderOffset    uint32
+	Flags                  uint32
+	HeaderSize             uint16
+	ProgramHeaderEntrySize uint16
+	ProgramHeaderEntries   uint16
+	SectionHeaderEntrySize uint16
+	SectionHeaderEntries   uint16
+	SectionNamesTable      uint16
+}
+
+type ELF32SectionHeader struct {
+	Name           uint32
+	Type           uint32
+	Flags          uint32
+	VirtualAddress uint32
+	FileOffset     uint32
+	Size           uint32
+	LinkedIndex    uint32
+	Info           uint32
+	Align          uint32
+	EntrySize      uint32
+}
+
+type ELF32ProgramHeader struct {
+	Type            uint32
+	FileOffset      uint32
+	VirtualAddress  uint32
+	PhysicalAddress uint32
+	FileSize        uint32
+	MemorySize      uint32
+	Flags           uint32
+	Align           uint32
+}
+
+type ELF32Symbols struct {
+	Name  uint32
+	Value uint32
+	Size  uint32
+	Info  byte
+	Other byte
+	Shndx uint16
+}
+
+type Elf32Dynamic struct {
+	Tag   uint32
+	Value uint32
+}
+
+// Raw relocation entries (r_info is split according to the class)
+type elf32Rel struct {
+	Offset uint32
+	Info   uint32
+}
+
+type elf32Rela struct {
+	Offset uint32
+	Info   uint32
+	Addend int32
+}
+
+type elf64Rel struct {
+	Offset uint64
+	Info   uint64
+}
+
+type elf64Rela struct {
+	Offset uint64
+	Info   uint64
+	Addend int64
+}
+
+func (h ELF32Header) toElf64() *ELF64Header {
+	return &ELF64Header{
+		Ident:                  h.Ident,
+		Type:                   h.Type,
+		Machine:                h.Machine,
+		Version:                h.Version,
+		EntryPoint:             uint64(h.EntryPoint),
+		ProgramHeaderOffset:    uint64(h.ProgramHeaderOffset),
+		SectionHeaderOffset:    uint64(h.SectionHeaderOffset),
+		Flags:                  h.Flags,
+		HeaderSize:             h.HeaderSize,
+		ProgramHeaderEntrySize: h.ProgramHeaderEntrySize,
+		ProgramHeaderEntries:   h.ProgramHeaderEntries,
+		SectionHeaderEntrySize: h.SectionHeaderEntrySize,
+		SectionHeaderEntries:   h.SectionHeaderEntries,
+		SectionNamesTable:      h.SectionNamesTable,
+	}
+}
+
+func (s ELF32SectionHeader) toElf64() ELF64SectionHeader {
+	return ELF64SectionHeader{
+		Name:           s.Name,
+		Type:           s.Type,
+		Flags:          uint64(s.Flags),
+		VirtualAddress: uint64(s.VirtualAddress),
+		FileOffset:     uint64(s.FileOffset),
+		Size:           uint64(s.Size),
+		LinkedIndex:    s.LinkedIndex,
+		Info:           s.Info,
+		Align:          uint64(s.Align),
+		EntrySize:      uint64(s.EntrySize),
+	}
+}
+
+func (p ELF32ProgramHeader) toElf64() ELF64ProgramHeader {
+	return ELF64ProgramHeader{
+		Type:            p.Type,
+		Flags:           p.Flags,
+		FileOffset:      uint64(p.FileOffset),
+		VirtualAddress:  uint64(p.VirtualAddress),
+		PhysicalAddress: uint64(p.PhysicalAddress),
+		FileSize:        uint64(p.FileSize),
+		MemorySize:      uint64(p.MemorySize),
+		Align:           uint64(p.Align),
+	}
+}
+
+func (s ELF32Symbols) toElf64() ELF64Symbols {
+	return ELF64Symbols{
+		Name:  s.Name,
+		Info:  s.Info,
+		Other: s.Other,
+		Shndx: s.Shndx,
+		Value: uint64(s.Value),
+		Size:  uint64(s.Size),
+	}
+}
+
+// Is32 returns true if the ELF file is an ELF32 file.
+func (elfFile *ELF64File) Is32() bool {
+	return elfFile.Class == elf.ELFCLASS32
+}
+
+// AddrSize returns the size (in bytes) of an address of the ELF file.
+func (elfFile *ELF64File) AddrSize() int {
+	if elfFile.Is32() {
+		return 4
+	}
+	return 8
+}
+
+// Machine returns the architecture of the ELF file.
+func (elfFile *ELF64File) Machine() elf.Machine {
+	return elf.Machine(elfFile.Header.Machine)
+}
+
+// ReadAddr reads an address (4 or 8 bytes according to the class) at the
+// beginning of the given buffer.
+func (elfFile *ELF64File) ReadAddr(b []byte) uint64 {
+	if elfFile.Is32() {
+		return uint64(elfFile.Endianness.Uint32(b))
+	}
+	return elfFile.Endianness.Uint64(b)
+}
+
+// readRelocations reads a SHT_REL or SHT_RELA table and splits the r_info
+// field according to the class of the ELF file.
+func (elfFile *ELF64File) readRelocations(content []byte, withAddend bool) ([]Elf64Rela, error) {
+
+	var entries interface{}
+	switch {
+	case elfFile.Is32() && withAddend:
+		entries = make([]elf32Rela, len(content)/binary.Size(elf32Rela{}))
+	case elfFile.Is32():
+		entries = make([]elf32Rel, len(content)/binary.Size(elf32Rel{}))
+	case withAddend:
+		entries = make([]elf64Rela, len(content)/binary.Size(elf64Rela{}))
+	default:
+		entries = make([]elf64Rel, len(content)/binary.Size(elf64Rel{}))
+	}
+
+	if err := binary.Read(bytes.NewReader(content),
+		elfFile.Endianness, entries); err != nil {
+		return nil, err
+	}
+
+	relas := make([]Elf64Rela, 0)
+	add := func(offset, info uint64, addend int64) {
+		rela := Elf64Rela{Offset: offset, Addend: addend}
+		if elfFile.Is32() {
+			rela.SymbolIndex = uint32(info >> 8)
+			rela.Type = uint32(info & 0xff)
+		} else {
+			rela.SymbolIndex = uint32(info >> 32)
+			rela.Type = uint32(info)
+		}
+		relas = append(relas, rela)
+	}
+
+	switch table := entries.(type) {
+	case []elf32Rela:
+		for _, r := range table {
+			add(uint64(r.Offset), uint64(r.Info), int64(r.Addend))
+		}
+	case []elf32Rel:
+		for _, r := range table {
+			add(uint64(r.Offset), uint64(r.Info), 0)
+		}
+	case []elf64Rela:
+		for _, r := range table {
+			add(r.Offset, r.Info, r.Addend)
+		}
+	case []elf64Rel:
+		for _, r := range table {
+			add(r.Offset, r.Info, 0)
+		}
+	}
+
+	return relas, nil
+}
+
+// relocationType returns the name of a relocation type according to the
+// architecture.
+func relocationType(machine elf.Machine, t uint32) string {
+
+	switch machine {
+	case elf.EM_X86_64:
+		return rx86_64Strings[t]
+	case elf.EM_386:
+		return elf.R_386(t).String()
+	case elf.EM_AARCH64:
+		return elf.R_AARCH64(t).String()
+	case elf.EM_ARM:
+		return elf.R_ARM(t).String()
+	case elf.EM_PPC64:
+		return elf.R_PPC64(t).String()
+	case elf.EM_PPC:
+		return elf.R_PPC(t).String()
+	case elf.EM_MIPS:
+		return elf.R_MIPS(t).String()
+	case elf.EM_RISCV:
+		return elf.R_RISCV(t).String()
+	}
+	return strconv.FormatUint(uint64(t), 10)
+}
@@ -0,0 +1,335 @@
+// Copyright 2019 The UNICORE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// Author: Gaulthier Gain <gaulthier.gain@uliege.be>
+
+package elf64core
+
+import (
+	"bytes"
+	"compress/zlib"
+	"debug/dwarf"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	u "tools/srcs/common"
+)
+
+const debugPrefix = ".debug_"
+
+type DwarfUnit struct {
+	Name      string       `json:"name"`
+	CompDir   string       `json:"compDir"`
+	Functions []DwarfEntry `json:"functions"`
+	Variables []DwarfEntry `json:"variables"`
+}
+
+type DwarfEntry struct {
+	Name string `json:"name"`
+	Addr uint64 `json:"addr"`
+	Size uint64 `json:"size"`
+	File string `json:"file"`
+	Line int64  `json:"line"`
+}
+
+// getDebugSection returns the (uncompressed) content of a debug section or
+// nil if the section does not exist.
+func (elfFile *ELF64File) getDebugSection(name string) ([]byte, error) {
+
+	index, ok := elfFile.IndexSections[debugPrefix+name]
+	if !ok {
+		return nil, nil
+	}
+
+	content, err := elfFile.GetSectionContent(uint16(index))
+	if err != nil {
+		return nil, err
+	}
+
+	if elfFile.SectionsTable.DataSect[index].Elf64section.Flags&uint64(elf.SHF_COMPRESSED) == 0 {
+		return content, nil
+	}
+
+	var compression uint32
+	reader := bytes.NewReader(content)
+	if elfFile.Is32() {
+		var header elf.Chdr32
+		if err := binary.Read(reader, elfFile.Endianness, &header); err != nil {
+			return nil, err
+		}
+		compression = header.Type
+	} else {
+		var header elf.Chdr64
+		if err := binary.Read(reader, elfFile.Endianness, &header); err != nil {
+			return nil, err
+		}
+		compression = header.Type
+	}
+	if elf.CompressionType(compression) != elf.COMPRESS_ZLIB {
+		return nil, fmt.Errorf("unsupported compression of section %s", debugPrefix+name)
+	}
+
+	zr, err := zlib.NewReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	return ioutil.ReadAll(zr)
+}
+
+// dwarfData creates a dwarf.Data structure from the debug sections.
+func (elfFile *ELF64File) dwarfData() (*dwarf.Data, error) {
+
+	names := []string{"abbrev", "aranges", "frame", "info", "line", "pubnames",
+		"ranges", "str"}
+	sections := make(map[string][]byte, len(names))
+	for _, name := range names {
+		content, err := elfFile.getDebugSection(name)
+		if err != nil {
+			return nil, err
+		}
+		sections[name] = content
+	}
+
+	if sections["info"] == nil {
+		return nil, errors.New("no debug information found in " + elfFile.Name)
+	}
+
+	data, err := dwarf.New(sections["abbrev"], sections["aranges"], sections["frame"],
+		sections["info"], sections["line"], sections["pubnames"], sections["ranges"],
+		sections["str"])
+	if err != nil {
+		return nil, err
+	}
+
+	// Sections used by DWARF 4 type units and DWARF 5
+	for _, name := range []string{"types", "addr", "line_str", "loclists",
+		"rnglists", "str_offsets"} {
+		content, err := elfFile.getDebugSection(name)
+		if err != nil {
+			return nil, err
+		}
+		if content == nil {
+			continue
+		}
+		if name == "types" {
+			err = data.AddTypes(debugPrefix+name, content)
+		} else {
+			err = data.AddSection(debugPrefix+name, content)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+// entryName returns the name of an entry. If the entry has no name, the name
+// of its specification or abstract origin is used.
+func entryName(data *dwarf.Data, entry *dwarf.Entry) string {
+
+	if name, ok := entry.Val(dwarf.AttrName).(string); ok {
+		return name
+	}
+
+	for _, attr := range []dwarf.Attr{dwarf.AttrSpecification, dwarf.AttrAbstractOrigin} {
+		if offset, ok := entry.Val(attr).(dwarf.Offset); ok {
+			reader := data.Reader()
+			reader.Seek(offset)
+			if origin, err := reader.Next(); err == nil && origin != nil {
+				return entryName(data, origin)
+			}
+		}
+	}
+	return ""
+}
+
+// entryFile returns the file and the line where an entry is declared.
+func entryFile(data *dwarf.Data, entry *dwarf.Entry, files []*dwarf.LineFile) (string, int64) {
+
+	index, ok := entry.Val(dwarf.AttrDeclFile).(int64)
+	if !ok {
+		for _, attr := range []dwarf.Attr{dwarf.AttrSpecification, dwarf.AttrAbstractOrigin} {
+			if offset, ok := entry.Val(attr).(dwarf.Offset); ok {
+				reader := data.Reader()
+				reader.Seek(offset)
+				if origin, err := reader.Next(); err == nil && origin != nil {
+					return entryFile(data, origin, files)
+				}
+			}
+		}
+		return "", 0
+	}
+
+	line, _ := entry.Val(dwarf.AttrDeclLine).(int64)
+	if index < 0 || int(index) >= len(files) || files[index] == nil {
+		return "", line
+	}
+	return files[index].Name, line
+}
+
+// functionRange returns the address and the size of a function or false if
+// the function has no code (e.g., declaration).
+func functionRange(entry *dwarf.Entry) (uint64, uint64, bool) {
+
+	low, ok := entry.Val(dwarf.AttrLowpc).(uint64)
+	if !ok {
+		return 0, 0, false
+	}
+
+	switch high := entry.Val(dwarf.AttrHighpc).(type) {
+	case uint64:
+		// Address class
+		return low, high - low, true
+	case int64:
+		// Constant class: offset from low pc
+		return low, uint64(high), true
+	}
+	return low, 0, true
+}
+
+// variableAddress returns the address of a global variable or false if the
+// location of the variable is not a static address.
+func (elfFile *ELF64File) variableAddress(entry *dwarf.Entry) (uint64, bool) {
+
+	location, ok := entry.Val(dwarf.AttrLocation).([]byte)
+	if !ok || len(location) != 1+elfFile.AddrSize() || location[0] != 0x03 {
+		// Only DW_OP_addr is supported
+		return 0, false
+	}
+	return elfFile.ReadAddr(location[1:]), true
+}
+
+// ParseDwarf parses the debug information of the ELF file and maps functions
+// and global variables to their compilation units and source files.
+//
+// It returns an error if any, otherwise it returns nil.
+func (elfFile *ELF64File) ParseDwarf() error {
+
+	data, err := elfFile.dwarfData()
+	if err != nil {
+		return err
+	}
+
+	elfFile.DwarfUnits = make([]*DwarfUnit, 0)
+
+	var unit *DwarfUnit
+	var files []*dwarf.LineFile
+	reader := data.Reader()
+	for {
+		entry, err := reader.Next()
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			break
+		}
+
+		switch entry.Tag {
+		case dwarf.TagCompileUnit:
+			name, _ := entry.Val(dwarf.AttrName).(string)
+			compDir, _ := entry.Val(dwarf.AttrCompDir).(string)
+			unit = &DwarfUnit{
+				Name:      name,
+				CompDir:   compDir,
+				Functions: make([]DwarfEntry, 0),
+				Variables: make([]DwarfEntry, 0),
+			}
+			elfFile.DwarfUnits = append(elfFile.DwarfUnits, unit)
+
+			files = nil
+			if lr, err := data.LineReader(entry); err == nil && lr != nil {
+				files = lr.Files()
+			}
+		case dwarf.TagSubprogram:
+			if unit == nil {
+				continue
+			}
+			addr, size, ok := functionRange(entry)
+			if !ok || addr == 0 {
+				continue
+			}
+			file, line := entryFile(data, entry, files)
+			unit.Functions = append(unit.Functions, DwarfEntry{
+				Name: entryName(data, entry),
+				Addr: addr,
+				Size: size,
+				File: unit.absPath(file),
+				Line: line,
+			})
+		case dwarf.TagVariable:
+			if unit == nil {
+				continue
+			}
+			addr, ok := elfFile.variableAddress(entry)
+			if !ok || addr == 0 {
+				continue
+			}
+			var size int64
+			if offset, ok := entry.Val(dwarf.AttrType).(dwarf.Offset); ok {
+				if t, err := data.Type(offset); err == nil {
+					size = t.Size()
+				}
+			}
+			if size < 0 {
+				size = 0
+			}
+			file, line := entryFile(data, entry, files)
+			unit.Variables = append(unit.Variables, DwarfEntry{
+				Name: entryName(data, entry),
+				Addr: addr,
+				Size: uint64(size),
+				File: unit.absPath(file),
+				Line: line,
+			})
+		}
+	}
+
+	return nil
+}
+
+// absPath returns the absolute path of a file of a compilation unit.
+func (unit *DwarfUnit) absPath(file string) string {
+	if len(file) == 0 || filepath.IsAbs(file) || len(unit.CompDir) == 0 {
+		return file
+	}
+	return filepath.Join(unit.CompDir, file)
+}
+
+// SourceFile returns the path of the source file of a compilation unit.
+func (unit *DwarfUnit) SourceFile() string {
+	return unit.absPath(unit.Name)
+}
+
+func (elfFile *ELF64File) DisplayDwarfUnits() {
+
+	if len(elfFile.DwarfUnits) == 0 {
+		u.PrintWarning("Dwarf units are empty")
+		return
+	}
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 8, 0, '\t', 0)
+	fmt.Println("-----------------------------------------------------------------------")
+	for _, unit := range elfFile.DwarfUnits {
+		_, _ = fmt.Fprintf(w, "\nCompilation unit '%s' contains %d functions and %d variables:\n",
+			unit.SourceFile(), len(unit.Functions), len(unit.Variables))
+		_, _ = fmt.Fprintln(w, "Name:\tAddr:\tSize:\tFile:")
+		for _, entries := range [][]DwarfEntry{unit.Functions, unit.Variables} {
+			for _, e := range entries {
+				_, _ = fmt.Fprintf(w, "%s\t%6.x\t%6.x\t%s:%d\n", e.Name, e.Addr, e.Size,
+					strings.TrimPrefix(e.File, unit.CompDir+u.SEP), e.Line)
+			}
+		}
+	}
+	_ = w.Flush()
+}
@@ -51,10 +51,23 @@ func (elfFile *ELF64File) parseDynamic(index int) error {
 		return fmt.Errorf("failed reading relocation table: %s", err)
 	}
 
-	dynEntries := make([]Elf64Dynamic, len(content)/binary.Size(Elf64Dynamic{}))
-	if err := binary.Read(bytes.NewReader(content),
-		elfFile.Endianness, dynEntries); err != nil {
-		return err
+	var dynEntries []Elf64Dynamic
+	if elfFile.Is32() {
+		dynEntries32 := make([]Elf32Dynamic, len(content)/binary.Size(Elf32Dynamic{}))
+		if err := binary.Read(bytes.NewReader(content),
+			elfFile.Endianness, dynEntries32); err != nil {
+			return err
+		}
+		dynEntries = make([]Elf64Dynamic, len(dynEntries32))
+		for i, d := range dynEntries32 {
+			dynEntries[i] = Elf64Dynamic{Tag: uint64(d.Tag), Value: uint64(d.Value)}
+		}
+	} else {
+		dynEntries = make([]Elf64Dynamic, len(content)/binary.Size(Elf64Dynamic{}))
+		if err := binary.Read(bytes.NewReader(content),
+			elfFile.Endianness, dynEntries); err != nil {
+			return err
+		}
 	}
 
 	if err := elfFile.addDynamicEntry(index, dynEntries); err != nil {
@@ -8,6 +8,7 @@ package elf64core
 
 import (
 	"bufio"
+	"debug/elf"
 	"encoding/binary"
 	"os"
 )
@@ -26,7 +27,9 @@ type ELF64File struct {
 	MapFctAddrName   map[uint64]string
 	Name             string
 	Endianness       binary.ByteOrder
+	Class            elf.Class
 	TextSectionIndex []int // slice since we can have several
+	DwarfUnits       []*DwarfUnit
 }
 
 func (elfFile *ELF64File) ReadElfBinaryFile(filename string) error {
@@ -45,13 +45,19 @@ func (elfFile *ELF64File) getIndexFctTable(ndx uint16) int {
 	return -1
 }
 
-func (elfFile *ELF64File) detectSizeSymbol(symbolsTable []ELF64Function, index int) uint64 {
+func (elfFile *ELF64File) detectSizeSymbol(sectionName string, symbolsTable []ELF64Function, index int) uint64 {
 
 	if index+1 == len(symbolsTable) {
-		textIndex := elfFile.IndexSections[TextSection]
+		textIndex, ok := elfFile.IndexSections[sectionName]
+		if !ok {
+			return 0
+		}
 		textSection := elfFile.SectionsTable.DataSect[textIndex]
-		size := textSection.Elf64section.FileOffset + textSection.Elf64section.Size
-		return size - symbolsTable[index].Addr
+		end := textSection.Elf64section.VirtualAddress + textSection.Elf64section.Size
+		if end < symbolsTable[index].Addr {
+			return 0
+		}
+		return end - symbolsTable[index].Addr
 	}
 	return symbolsTable[index+1].Addr - symbolsTable[index].Addr
 }
@@ -76,7 +82,7 @@ func (elfFile *ELF64File) inspectFunctions() error {
 					elfFile.FunctionsTables[k].Functions =
 						append(elfFile.FunctionsTables[k].Functions, function)
 				}
-			} else if s.TypeSymbol == byte(elf.STT_FUNC) {
+			} else if k != -1 && s.TypeSymbol == byte(elf.STT_FUNC) {
 				// If it is a func where the start address starts at 0
 				function := ELF64Function{Name: s.name, Addr: s.elf64sym.Value,
 					Size: s.elf64sym.Size}
@@ -92,7 +98,8 @@ func (elfFile *ELF64File) inspectFunctions() error {
 
 func (elfFile *ELF64File) parseFunctions() error {
 
-	if _, ok := elfFile.IndexSections[TextSection]; ok {
+	// An ELF file may only contain '.text.*' sections (e.g., aligned images)
+	if len(elfFile.TextSectionIndex) > 0 {
 
 		if err := elfFile.inspectFunctions(); err != nil {
 			return err
@@ -111,7 +118,7 @@ func (elfFile *ELF64File) parseFunctions() error {
 
 		for i, f := range table.Functions {
 			if f.Size == 0 {
-				f.Size = elfFile.detectSizeSymbol(table.Functions, i)
+				table.Functions[i].Size = elfFile.detectSizeSymbol(table.Name, table.Functions, i)
 			}
 
 			// Special case where symbol of same address can be in different order
@@ -135,9 +142,9 @@ func (table *FunctionTables) displayFunctions(w *tabwriter.Writer, fullDisplay b
 
 	_, _ = fmt.Fprintf(w, "\nTable section '%s' contains %d entries:\n",
 		table.Name, table.NbEntries)
-	_, _ = fmt.Fprintf(w, "Name:\tAddr:\tSize\tRaw:\n")
+	_, _ = fmt.Fprintf(w, "Name:\tAddr:\tSize\n")
 	for _, f := range table.Functions {
-		_, _ = fmt.Fprintf(w, "%s\t%6.x\t%6.x\t%s\n", f.Name, f.Addr, f.Size)
+		_, _ = fmt.Fprintf(w, "%s\t%6.x\t%6.x\n", f.Name, f.Addr, f.Size)
 
 	}
 }
@@ -8,6 +8,7 @@ package elf64core
 
 import (
 	"bytes"
+	"debug/elf"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -47,14 +48,15 @@ func (elfFile *ELF64File) ParseElfHeader() error {
 	}
 
 	// Check the magic number
-	if elfFile.Raw[0] != 0x7f && elfFile.Raw[1] != 0x45 &&
-		elfFile.Raw[2] != 0x4c && elfFile.Raw[3] != 0x46 {
+	if elfFile.Raw[0] != 0x7f || elfFile.Raw[1] != 0x45 ||
+		elfFile.Raw[2] != 0x4c || elfFile.Raw[3] != 0x46 {
 		return errors.New("invalid ELF file")
 	}
 
-	// Check the type
-	if elfFile.Raw[4] != 0x02 {
-		return errors.New("elf32 is not supported")
+	// Check the class
+	elfFile.Class = elf.Class(elfFile.Raw[4])
+	if elfFile.Class != elf.ELFCLASS32 && elfFile.Class != elf.ELFCLASS64 {
+		return fmt.Errorf("invalid ELF class: %d", elfFile.Raw[4])
 	}
 
 	if elfFile.Raw[5] == littleEndian {
@@ -64,6 +66,15 @@ func (elfFile *ELF64File) ParseElfHeader() error {
 	}
 
 	data := bytes.NewReader(elfFile.Raw)
+	if elfFile.Is32() {
+		header := new(ELF32Header)
+		if err := binary.Read(data, elfFile.Endianness, header); err != nil {
+			return err
+		}
+		elfFile.Header = header.toElf64()
+		return nil
+	}
+
 	err := binary.Read(data, elfFile.Endianness, elfFile.Header)
 	if err != nil {
 		return err
@@ -83,7 +94,9 @@ func (elfHeader ELF64Header) DisplayHeader() {
 	}
 
 	_, _ = fmt.Fprintf(w, "\nType:\t%x\n", elfHeader.Type)
-	_, _ = fmt.Fprintf(w, "Machine:\t%d\n", elfHeader.Machine)
+	_, _ = fmt.Fprintf(w, "Class:\t%s\n", elf.Class(elfHeader.Ident[elf.EI_CLASS]))
+	_, _ = fmt.Fprintf(w, "Data:\t%s\n", elf.Data(elfHeader.Ident[elf.EI_DATA]))
+	_, _ = fmt.Fprintf(w, "Machine:\t%s (%d)\n", elf.Machine(elfHeader.Machine), elfHeader.Machine)
 	_, _ = fmt.Fprintf(w, "Version:\t0x%x\n", elfHeader.Version)
 	_, _ = fmt.Fprintf(w, "EntryPoint:\t0x%x\n", elfHeader.EntryPoint)
 	_, _ = fmt.Fprintf(w, "ProgramHeaderOffset:\t%d\n", elfHeader.ProgramHeaderOffset)
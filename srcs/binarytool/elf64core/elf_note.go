@@ -9,15 +9,25 @@ package elf64core
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
 	"text/tabwriter"
 	u "tools/srcs/common"
 )
 
+type NoteInfo struct {
+	Section     string `json:"section"`
+	Owner       string `json:"owner"`
+	Type        uint32 `json:"type"`
+	Size        uint32 `json:"size"`
+	Description string `json:"description"`
+}
+
 type NotesTables struct {
 	name     string
-	dataNote dataNote
+	dataNote []dataNote
 }
 
 type dataNote struct {
@@ -44,14 +54,57 @@ func (elfFile *ELF64File) parseNote(index int) error {
 	}
 
 	data := bytes.NewReader(content)
-	err = binary.Read(data, elfFile.Endianness, &notesTable.dataNote.elf64note)
-	if err != nil {
-		return fmt.Errorf("failed reading elf64note: %s", err)
+	for data.Len() > 0 {
+		var note dataNote
+		err = binary.Read(data, elfFile.Endianness, &note.elf64note)
+		if err != nil {
+			return fmt.Errorf("failed reading elf64note: %s", err)
+		}
+
+		// Name and description are padded to 4 bytes
+		name := make([]byte, align4(note.elf64note.Namesz))
+		if _, err := io.ReadFull(data, name); err != nil {
+			return fmt.Errorf("failed reading note name: %s", err)
+		}
+		note.name = string(bytes.TrimRight(name[:note.elf64note.Namesz], "\x00"))
+
+		desc := make([]byte, align4(note.elf64note.Descsz))
+		if _, err := io.ReadFull(data, desc); err != nil {
+			return fmt.Errorf("failed reading note description: %s", err)
+		}
+		note.description = string(desc[:note.elf64note.Descsz])
+
+		notesTable.dataNote = append(notesTable.dataNote, note)
 	}
 
+	elfFile.NotesTables = append(elfFile.NotesTables, notesTable)
+
 	return nil
 }
 
+func align4(size uint32) uint32 {
+	return (size + 3) &^ 3
+}
+
+// GetNotes returns the notes of all the notes sections of the ELF file. The
+// description of each note is hex encoded.
+func (elfFile *ELF64File) GetNotes() []NoteInfo {
+
+	notes := make([]NoteInfo, 0)
+	for _, t := range elfFile.NotesTables {
+		for _, n := range t.dataNote {
+			notes = append(notes, NoteInfo{
+				Section:     t.name,
+				Owner:       n.name,
+				Type:        n.elf64note.TypeNote,
+				Size:        n.elf64note.Descsz,
+				Description: hex.EncodeToString([]byte(n.description)),
+			})
+		}
+	}
+	return notes
+}
+
 func (elfFile *ELF64File) DisplayNotes() {
 
 	if len(elfFile.NotesTables) == 0 {
@@ -65,8 +118,10 @@ func (elfFile *ELF64File) DisplayNotes() {
 	for _, t := range elfFile.NotesTables {
 		_, _ = fmt.Fprintf(w, "\nDisplaying notes found in: %s\n", t.name)
 		_, _ = fmt.Fprintln(w, " Owner\tData size\tDescription")
-		_, _ = fmt.Fprintf(w, " %s\t0x%.6x\t%x\n", t.dataNote.name,
-			t.dataNote.elf64note.Descsz, t.dataNote.description)
+		for _, n := range t.dataNote {
+			_, _ = fmt.Fprintf(w, " %s\t0x%.6x\t%x\n", n.name,
+				n.elf64note.Descsz, n.description)
+		}
 	}
 
 	_ = w.Flush()
@@ -7,9 +7,7 @@
 package elf64core
 
 import (
-	"bytes"
 	"debug/elf"
-	"encoding/binary"
 	"fmt"
 	"os"
 	"text/tabwriter"
@@ -19,6 +17,7 @@ import (
 type RelaTables struct {
 	nbEntries int
 	name      string
+	machine   elf.Machine
 	dataRela  []*dataRela
 }
 
@@ -27,6 +26,16 @@ type dataRela struct {
 	elf64Rela Elf64Rela
 }
 
+type RelocationInfo struct {
+	Table  string `json:"table"`
+	Offset uint64 `json:"offset"`
+	Type   string `json:"type"`
+	Symbol string `json:"symbol"`
+	Addend int64  `json:"addend"`
+}
+
+// Elf64Rela is a relocation entry (SHT_REL or SHT_RELA) whose r_info field
+// has been split into a type and a symbol index.
 type Elf64Rela struct {
 	Offset      uint64
 	Type        uint32
@@ -39,6 +48,7 @@ func (elfFile *ELF64File) addRela(index int, relas []Elf64Rela) error {
 	var relocationTables RelaTables
 	relocationTables.nbEntries = len(relas)
 	relocationTables.name = elfFile.SectionsTable.DataSect[index].Name
+	relocationTables.machine = elfFile.Machine()
 	relocationTables.dataRela = make([]*dataRela, relocationTables.nbEntries)
 
 	for i := range relas {
@@ -62,9 +72,9 @@ func (elfFile *ELF64File) parseRelocations(index int) error {
 		return fmt.Errorf("failed reading relocation table: %s", err)
 	}
 
-	rela := make([]Elf64Rela, len(content)/binary.Size(Elf64Rela{}))
-	if err := binary.Read(bytes.NewReader(content),
-		elfFile.Endianness, rela); err != nil {
+	withAddend := elfFile.SectionsTable.DataSect[index].Elf64section.Type == uint32(elf.SHT_RELA)
+	rela, err := elfFile.readRelocations(content, withAddend)
+	if err != nil {
 		return err
 	}
 
@@ -75,15 +85,42 @@ func (elfFile *ELF64File) parseRelocations(index int) error {
 	return nil
 }
 
+// linkedSymbolsTable returns the index of the symbols table which is linked to
+// a relocation table (sh_link) or -1 if it cannot be found.
+func (elfFile *ELF64File) linkedSymbolsTable(table *RelaTables) int {
+
+	index, ok := elfFile.IndexSections[table.name]
+	if !ok {
+		return -1
+	}
+
+	link := elfFile.SectionsTable.DataSect[index].Elf64section.LinkedIndex
+	if int(link) >= len(elfFile.SectionsTable.DataSect) {
+		return -1
+	}
+
+	linkedName := elfFile.SectionsTable.DataSect[link].Name
+	for i, t := range elfFile.SymbolsTables {
+		if t.name == linkedName {
+			return i
+		}
+	}
+	return -1
+}
+
 func (elfFile *ELF64File) resolveRelocSymbolsName() error {
-	for _, table := range elfFile.RelaTables {
+	for i, table := range elfFile.RelaTables {
+		linked := elfFile.linkedSymbolsTable(&elfFile.RelaTables[i])
 		for _, s := range table.dataRela {
-			t := 0
-			if s.elf64Rela.Type == uint32(elf.R_X86_64_JMP_SLOT) ||
-				s.elf64Rela.Type == uint32(elf.R_X86_64_GLOB_DAT) ||
-				s.elf64Rela.Type == uint32(elf.R_X86_64_COPY) &&
-					len(elfFile.SymbolsTables) > 1 {
-				t++
+			t := linked
+			if t == -1 {
+				t = 0
+				if table.machine == elf.EM_X86_64 && len(elfFile.SymbolsTables) > 1 &&
+					(s.elf64Rela.Type == uint32(elf.R_X86_64_JMP_SLOT) ||
+						s.elf64Rela.Type == uint32(elf.R_X86_64_GLOB_DAT) ||
+						s.elf64Rela.Type == uint32(elf.R_X86_64_COPY)) {
+					t++
+				}
 			}
 
 			symName, err := elfFile.SymbolsTables[t].getSymbolName(s.elf64Rela.SymbolIndex)
@@ -103,7 +140,7 @@ func (table *RelaTables) displayRelocations(w *tabwriter.Writer) {
 	for _, r := range table.dataRela {
 		_, _ = fmt.Fprintf(w, "%.6x\t%.6d\t%s\t%s %x\n",
 			r.elf64Rela.Offset, r.elf64Rela.SymbolIndex,
-			rx86_64Strings[r.elf64Rela.Type], *r.name,
+			relocationType(table.machine, r.elf64Rela.Type), *r.name,
 			r.elf64Rela.Addend)
 	}
 }
@@ -124,3 +161,26 @@ func (elfFile *ELF64File) DisplayRelocationTables() {
 	}
 	_ = w.Flush()
 }
+
+// GetRelocations returns the entries of all the relocation tables of the ELF
+// file.
+func (elfFile *ELF64File) GetRelocations() []RelocationInfo {
+
+	relocations := make([]RelocationInfo, 0)
+	for _, table := range elfFile.RelaTables {
+		for _, r := range table.dataRela {
+			var name string
+			if r.name != nil {
+				name = *r.name
+			}
+			relocations = append(relocations, RelocationInfo{
+				Table:  table.name,
+				Offset: r.elf64Rela.Offset,
+				Type:   relocationType(table.machine, r.elf64Rela.Type),
+				Symbol: name,
+				Addend: r.elf64Rela.Addend,
+			})
+		}
+	}
+	return relocations
+}
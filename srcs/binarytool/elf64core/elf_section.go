@@ -28,6 +28,14 @@ type DataSections struct {
 	Elf64section ELF64SectionHeader
 }
 
+type SectionInfo struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Address uint64 `json:"address"`
+	Offset  uint64 `json:"offset"`
+	Size    uint64 `json:"size"`
+}
+
 type ELF64SectionHeader struct {
 	Name           uint32
 	Type           uint32
@@ -72,8 +80,15 @@ func (elfFile *ELF64File) ParseSectionHeaders() error {
 	data := bytes.NewReader(elfFile.Raw[offset:])
 
 	sections := make([]ELF64SectionHeader, elfFile.Header.SectionHeaderEntries)
-	err := binary.Read(data, elfFile.Endianness, sections)
-	if err != nil {
+	if elfFile.Is32() {
+		sections32 := make([]ELF32SectionHeader, elfFile.Header.SectionHeaderEntries)
+		if err := binary.Read(data, elfFile.Endianness, sections32); err != nil {
+			return fmt.Errorf("failed reading elf32section header table: %s", err)
+		}
+		for i, s := range sections32 {
+			sections[i] = s.toElf64()
+		}
+	} else if err := binary.Read(data, elfFile.Endianness, sections); err != nil {
 		return fmt.Errorf("failed reading elf64section header table: %s", err)
 	}
 
@@ -121,7 +136,7 @@ func (elfFile *ELF64File) ParseSections() error {
 	elfFile.FunctionsTables = make([]FunctionTables, 0)
 	elfFile.TextSectionIndex = make([]int, 0)
 
-	for i := 0; i < len(elfFile.SectionsTable.DataSect)-1; i++ {
+	for i := 0; i < len(elfFile.SectionsTable.DataSect); i++ {
 		sectionName := elfFile.SectionsTable.DataSect[i].Name
 		elfFile.IndexSections[sectionName] = i
 		typeSection := elfFile.SectionsTable.DataSect[i].Elf64section.Type
@@ -130,7 +145,7 @@ func (elfFile *ELF64File) ParseSections() error {
 			if err := elfFile.parseSymbolsTable(i); err != nil {
 				return err
 			}
-		case uint32(elf.SHT_RELA):
+		case uint32(elf.SHT_RELA), uint32(elf.SHT_REL):
 			if err := elfFile.parseRelocations(i); err != nil {
 				return err
 			}
@@ -157,7 +172,7 @@ func (elfFile *ELF64File) ParseSections() error {
 				Functions: nil,
 			})
 
-			if typeSection != uint32(elf.SHT_RELA) {
+			if typeSection != uint32(elf.SHT_RELA) && typeSection != uint32(elf.SHT_REL) {
 				elfFile.TextSectionIndex = append(elfFile.TextSectionIndex, i)
 			}
 		}
@@ -185,3 +200,19 @@ func (table *SectionsTable) DisplaySections() {
 	}
 	_ = w.Flush()
 }
+
+// GetSections returns the headers of all the sections of the ELF file.
+func (table *SectionsTable) GetSections() []SectionInfo {
+
+	sections := make([]SectionInfo, 0, len(table.DataSect))
+	for _, s := range table.DataSect {
+		sections = append(sections, SectionInfo{
+			Name:    s.Name,
+			Type:    shtStrings[s.Elf64section.Type],
+			Address: s.Elf64section.VirtualAddress,
+			Offset:  s.Elf64section.FileOffset,
+			Size:    s.Elf64section.Size,
+		})
+	}
+	return sections
+}
@@ -78,8 +78,15 @@ func (elfFile *ELF64File) ParseProgramHeaders() error {
 	data := bytes.NewReader(elfFile.Raw[offset:])
 
 	programs := make([]ELF64ProgramHeader, elfFile.Header.ProgramHeaderEntries)
-	err := binary.Read(data, elfFile.Endianness, programs)
-	if err != nil {
+	if elfFile.Is32() {
+		programs32 := make([]ELF32ProgramHeader, elfFile.Header.ProgramHeaderEntries)
+		if err := binary.Read(data, elfFile.Endianness, programs32); err != nil {
+			return fmt.Errorf("failed reading elf32program header table: %s", err)
+		}
+		for i, p := range programs32 {
+			programs[i] = p.toElf64()
+		}
+	} else if err := binary.Read(data, elfFile.Endianness, programs); err != nil {
 		return fmt.Errorf("failed reading elf64section header table: %s", err)
 	}
 
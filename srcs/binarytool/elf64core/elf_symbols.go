@@ -28,6 +28,14 @@ type dataSymbols struct {
 	TypeSymbol byte
 }
 
+type SymbolInfo struct {
+	Table string `json:"table"`
+	Name  string `json:"name"`
+	Value uint64 `json:"value"`
+	Size  uint64 `json:"size"`
+	Type  string `json:"type"`
+}
+
 type ELF64Symbols struct {
 	Name  uint32
 	Info  byte
@@ -44,15 +52,21 @@ func (elfFile *ELF64File) addSymbols(index int, symbols []ELF64Symbols) error {
 	symbolsTables.name = elfFile.SectionsTable.DataSect[index].Name
 	symbolsTables.dataSymbols = make([]*dataSymbols, symbolsTables.nbEntries)
 
+	// The string table is given by the linked section (sh_link)
+	stringTable := uint16(elfFile.SectionsTable.DataSect[index].Elf64section.LinkedIndex)
+
 	var nameString string
 	var err error
 	for j, s := range symbols {
 
-		if s.Info == byte(elf.STT_SECTION) {
+		if s.Info&0xf == byte(elf.STT_SECTION) {
 			// This is a section, save its name
-			nameString = elfFile.SectionsTable.DataSect[s.Shndx].Name
+			nameString = ""
+			if int(s.Shndx) < len(elfFile.SectionsTable.DataSect) {
+				nameString = elfFile.SectionsTable.DataSect[s.Shndx].Name
+			}
 		} else {
-			nameString, err = elfFile.GetSectionName(s.Name, uint16(index+1))
+			nameString, err = elfFile.GetSectionName(s.Name, stringTable)
 			if err != nil {
 				return err
 			}
@@ -78,14 +92,32 @@ func (elfFile *ELF64File) parseSymbolsTable(index int) error {
 		return fmt.Errorf("failed reading string table: %s", err)
 	}
 
-	if content[len(content)-1] != 0 {
-		return fmt.Errorf("the string table isn't null-terminated")
+	entrySize := binary.Size(ELF64Symbols{})
+	if elfFile.Is32() {
+		entrySize = binary.Size(ELF32Symbols{})
+	}
+	if len(content)%entrySize != 0 {
+		return fmt.Errorf("invalid size of symbols table %s",
+			elfFile.SectionsTable.DataSect[index].Name)
 	}
 
-	symbols := make([]ELF64Symbols, len(content)/binary.Size(ELF64Symbols{}))
-	if err := binary.Read(bytes.NewReader(content),
-		elfFile.Endianness, symbols); err != nil {
-		return err
+	var symbols []ELF64Symbols
+	if elfFile.Is32() {
+		symbols32 := make([]ELF32Symbols, len(content)/entrySize)
+		if err := binary.Read(bytes.NewReader(content),
+			elfFile.Endianness, symbols32); err != nil {
+			return err
+		}
+		symbols = make([]ELF64Symbols, len(symbols32))
+		for i, s := range symbols32 {
+			symbols[i] = s.toElf64()
+		}
+	} else {
+		symbols = make([]ELF64Symbols, len(content)/entrySize)
+		if err := binary.Read(bytes.NewReader(content),
+			elfFile.Endianness, symbols); err != nil {
+			return err
+		}
 	}
 
 	if err := elfFile.addSymbols(index, symbols); err != nil {
@@ -125,6 +157,24 @@ func (elfFile *ELF64File) DisplaySymbolsTables() {
 	_ = w.Flush()
 }
 
+// GetSymbols returns the symbols of all the symbols tables of the ELF file.
+func (elfFile *ELF64File) GetSymbols() []SymbolInfo {
+
+	symbols := make([]SymbolInfo, 0)
+	for _, table := range elfFile.SymbolsTables {
+		for _, s := range table.dataSymbols {
+			symbols = append(symbols, SymbolInfo{
+				Table: table.name,
+				Name:  s.name,
+				Value: s.elf64sym.Value,
+				Size:  s.elf64sym.Size,
+				Type:  sttStrings[s.TypeSymbol],
+			})
+		}
+	}
+	return symbols
+}
+
 func (table *SymbolsTables) getSymbolName(index uint32) (string, error) {
 	if table.dataSymbols == nil {
 		return "", fmt.Errorf("symbol table is empty")
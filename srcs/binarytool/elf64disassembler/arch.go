@@ -0,0 +1,199 @@
+// Copyright 2019 The UNICORE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// Author: Gaulthier Gain <gaulthier.gain@uliege.be>
+
+package elf64disassembler
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"github.com/knightsc/gapstone"
+	"strings"
+	"tools/srcs/binarytool/elf64core"
+)
+
+type archInfo struct {
+	arch int
+	mode int
+	// Instructions used to call functions
+	calls []string
+	// Unconditional jumps (used for tail calls and PLT entries)
+	jumps []string
+}
+
+var archs = map[elf.Machine]*archInfo{
+	elf.EM_X86_64: {
+		arch:  gapstone.CS_ARCH_X86,
+		mode:  gapstone.CS_MODE_64,
+		calls: []string{"call", "callq"},
+		jumps: []string{"jmp", "jmpq", "bnd jmp", "notrack jmp"},
+	},
+	elf.EM_386: {
+		arch:  gapstone.CS_ARCH_X86,
+		mode:  gapstone.CS_MODE_32,
+		calls: []string{"call", "calll"},
+		jumps: []string{"jmp", "jmpl", "bnd jmp", "notrack jmp"},
+	},
+	elf.EM_AARCH64: {
+		arch:  gapstone.CS_ARCH_ARM64,
+		mode:  gapstone.CS_MODE_ARM,
+		calls: []string{"bl", "blr"},
+		jumps: []string{"b", "br"},
+	},
+	elf.EM_ARM: {
+		arch:  gapstone.CS_ARCH_ARM,
+		mode:  gapstone.CS_MODE_ARM,
+		calls: []string{"bl", "blx"},
+		jumps: []string{"b", "bx"},
+	},
+}
+
+// getArch returns the architecture information of an ELF file.
+//
+// It returns a pointer to an archInfo structure and an error if any,
+// otherwise it returns nil.
+func getArch(elfFile *elf64core.ELF64File) (*archInfo, error) {
+	a, ok := archs[elfFile.Machine()]
+	if !ok {
+		return nil, fmt.Errorf("disassembly of %s binaries is not supported",
+			elfFile.Machine())
+	}
+	return a, nil
+}
+
+func (a *archInfo) isCall(insn gapstone.Instruction) bool {
+	for _, m := range a.calls {
+		if insn.Mnemonic == m {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *archInfo) isJump(insn gapstone.Instruction) bool {
+	for _, m := range a.jumps {
+		if insn.Mnemonic == m {
+			return true
+		}
+	}
+	return false
+}
+
+func newEngine(elfFile *elf64core.ELF64File) (*gapstone.Engine, error) {
+
+	a, err := getArch(elfFile)
+	if err != nil {
+		return nil, err
+	}
+
+	mode := a.mode
+	if elfFile.Endianness == binary.BigEndian {
+		mode |= gapstone.CS_MODE_BIG_ENDIAN
+	}
+
+	engine, err := gapstone.New(a.arch, mode)
+	if err != nil {
+		return nil, fmt.Errorf("disassembly error: %v", err)
+	}
+	return &engine, nil
+}
+
+// operands splits the operands of an instruction.
+func operands(insn gapstone.Instruction) []string {
+	if len(insn.OpStr) == 0 {
+		return nil
+	}
+	return strings.Split(insn.OpStr, ", ")
+}
+
+// immediate returns the value of an immediate operand (e.g., "0x401000" or
+// "#0x401000").
+func immediate(operand string) (uint64, bool) {
+	operand = strings.TrimPrefix(strings.TrimSpace(operand), "#")
+	if !strings.HasPrefix(operand, "0x") {
+		return 0, false
+	}
+	value, err := hex2int(operand)
+	if err != nil {
+		return 0, false
+	}
+	return value, true
+}
+
+// registers keeps the addresses loaded into registers by adrp/adr
+// instructions (AArch64) in order to resolve page-relative accesses.
+type registers map[string]uint64
+
+// update updates the registers with the result of an instruction.
+func (regs registers) update(insn gapstone.Instruction) {
+
+	ops := operands(insn)
+	if len(ops) == 0 {
+		return
+	}
+
+	switch insn.Mnemonic {
+	case "adrp", "adr":
+		if value, ok := immediate(ops[len(ops)-1]); ok {
+			regs[ops[0]] = value
+			return
+		}
+	case "add":
+		if len(ops) == 3 {
+			base, ok := regs[ops[1]]
+			if value, isImm := immediate(ops[2]); ok && isImm {
+				regs[ops[0]] = base + value
+				return
+			}
+		}
+	case "str", "stp", "strb", "strh", "stur", "cmp", "cmn", "tst":
+		// The first operand is not written
+		return
+	}
+	delete(regs, ops[0])
+}
+
+// memTarget computes the address targeted by a memory operand of an
+// instruction: rip-relative (x86-64), absolute (x86) or relative to a
+// register loaded by adrp (AArch64).
+func memTarget(insn gapstone.Instruction, regs registers) (uint64, bool) {
+
+	if addr, ok := ripTarget(insn); ok {
+		return addr, true
+	}
+
+	start := strings.Index(insn.OpStr, "[")
+	if start == -1 {
+		return 0, false
+	}
+	end := strings.Index(insn.OpStr[start:], "]")
+	if end == -1 {
+		return 0, false
+	}
+
+	operand := strings.TrimSpace(insn.OpStr[start+1 : start+end])
+	if value, ok := immediate(operand); ok {
+		// Absolute address (e.g., "dword ptr [0x804a00c]")
+		return value, true
+	}
+
+	parts := strings.Split(operand, ", ")
+	if len(parts) == 1 {
+		// Register with displacement (e.g., "dword ptr [ebx + 0xc]")
+		parts = strings.Split(operand, " + ")
+	}
+	base, ok := regs[parts[0]]
+	if !ok {
+		return 0, false
+	}
+	if len(parts) == 1 {
+		return base, true
+	}
+	if value, ok := immediate(parts[1]); ok && len(parts) == 2 {
+		return base + value, true
+	}
+	return 0, false
+}
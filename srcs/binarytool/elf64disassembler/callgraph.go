@@ -0,0 +1,503 @@
+// Copyright 2019 The UNICORE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// Author: Gaulthier Gain <gaulthier.gain@uliege.be>
+
+package elf64disassembler
+
+import (
+	"debug/elf"
+	"fmt"
+	"github.com/knightsc/gapstone"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"tools/srcs/binarytool/elf64analyser"
+	"tools/srcs/binarytool/elf64core"
+	u "tools/srcs/common"
+)
+
+const (
+	directCall = "direct"
+	tailCall   = "tail"
+	pltCall    = "plt"
+	gotCall    = "got"
+	relocCall  = "reloc"
+	refCall    = "ref"
+)
+
+var pltSections = []string{".plt", ".plt.sec", ".plt.got"}
+
+type CallGraph struct {
+	Name       string               `json:"name"`
+	Nodes      map[string]*CallNode `json:"nodes"`
+	Edges      []*CallEdge          `json:"edges"`
+	LibCalls   []*LibCall           `json:"libCalls"`
+	Unresolved int                  `json:"unresolved"`
+
+	addrNodes map[uint64]*CallNode
+	mapEdges  map[string]*CallEdge
+}
+
+type CallNode struct {
+	Name    string `json:"name"`
+	Addr    uint64 `json:"addr"`
+	Size    uint64 `json:"size"`
+	Section string `json:"section"`
+	Lib     string `json:"lib"`
+}
+
+type CallEdge struct {
+	Caller   string `json:"caller"`
+	Callee   string `json:"callee"`
+	Type     string `json:"type"`
+	Count    int    `json:"count"`
+	CrossLib bool   `json:"crossLib"`
+}
+
+type LibCall struct {
+	From      string   `json:"from"`
+	To        string   `json:"to"`
+	Count     int      `json:"count"`
+	Functions []string `json:"functions"`
+}
+
+// resolver contains the information required to resolve call targets.
+type resolver struct {
+	elfFile *elf64core.ELF64File
+	arch    *archInfo
+	// GOT address -> symbol name (from dynamic relocations)
+	gotSymbols map[uint64]string
+	// PLT entry address -> symbol name
+	pltSymbols map[uint64]string
+	// relocation table name -> offset -> symbol name (relocatable files)
+	relocSymbols map[string]map[uint64]string
+}
+
+// ripTarget computes the address targeted by a rip-relative memory operand
+// (e.g., "qword ptr [rip + 0x2fe2]").
+func ripTarget(insn gapstone.Instruction) (uint64, bool) {
+
+	start := strings.Index(insn.OpStr, "[rip")
+	if start == -1 {
+		return 0, false
+	}
+	end := strings.Index(insn.OpStr[start:], "]")
+	if end == -1 {
+		return 0, false
+	}
+
+	next := uint64(insn.Address + insn.Size)
+	operand := strings.TrimSpace(insn.OpStr[start+len("[rip") : start+end])
+	if len(operand) == 0 {
+		return next, true
+	}
+
+	disp, err := hex2int(strings.TrimSpace(operand[1:]))
+	if err != nil {
+		return 0, false
+	}
+	if operand[0] == '-' {
+		return next - disp, true
+	}
+	return next + disp, true
+}
+
+func newResolver(elfFile *elf64core.ELF64File, engine *gapstone.Engine, a *archInfo) *resolver {
+
+	r := &resolver{
+		elfFile:      elfFile,
+		arch:         a,
+		gotSymbols:   make(map[uint64]string),
+		pltSymbols:   make(map[uint64]string),
+		relocSymbols: make(map[string]map[uint64]string),
+	}
+
+	for _, rela := range elfFile.GetRelocations() {
+		if len(rela.Symbol) == 0 {
+			continue
+		}
+		if _, ok := r.relocSymbols[rela.Table]; !ok {
+			r.relocSymbols[rela.Table] = make(map[uint64]string)
+		}
+		r.relocSymbols[rela.Table][rela.Offset] = rela.Symbol
+		r.gotSymbols[rela.Offset] = rela.Symbol
+	}
+
+	// Resolve PLT entries: each entry jumps through a GOT slot
+	for _, name := range pltSections {
+		index, ok := elfFile.IndexSections[name]
+		if !ok {
+			continue
+		}
+		section := elfFile.SectionsTable.DataSect[index]
+		content, err := sectionContent(elfFile, section,
+			section.Elf64section.VirtualAddress, section.Elf64section.Size)
+		if err != nil {
+			u.PrintWarning(err)
+			continue
+		}
+		insns, err := engine.Disasm(content, section.Elf64section.VirtualAddress, 0)
+		if err != nil {
+			u.PrintWarning(fmt.Sprintf("Cannot disassemble %s: %v", name, err))
+			continue
+		}
+
+		regs := make(registers)
+		if index, ok := elfFile.IndexSections[".got.plt"]; ok && elfFile.Machine() == elf.EM_386 {
+			// PIC PLT entries (i386) jump relative to the GOT kept into ebx
+			regs["ebx"] = elfFile.SectionsTable.DataSect[index].Elf64section.VirtualAddress
+		}
+		for _, insn := range insns {
+			// Each entry loads the address of the function from a GOT slot
+			if got, ok := memTarget(insn, regs); ok {
+				if symbol, ok := r.gotSymbols[got]; ok {
+					// A PLT entry is 16 bytes long
+					r.pltSymbols[uint64(insn.Address)&^0xf] = symbol
+				}
+			}
+			regs.update(insn)
+		}
+	}
+
+	return r
+}
+
+// resolve resolves the target of a call (or jmp) instruction.
+//
+// It returns the target address (0 if unknown), the target symbol name (empty
+// if unknown) and the type of the call.
+func (r *resolver) resolve(insn gapstone.Instruction, section string,
+	regs registers) (uint64, string, string) {
+
+	// Relocatable file: the target is given by the relocation of the operand
+	for _, prefix := range []string{".rela", ".rel"} {
+		symbols, ok := r.relocSymbols[prefix+section]
+		if !ok {
+			continue
+		}
+		index := r.elfFile.IndexSections[section]
+		base := r.elfFile.SectionsTable.DataSect[index].Elf64section.VirtualAddress
+		for offset := uint64(insn.Address); offset < uint64(insn.Address+insn.Size); offset++ {
+			if symbol, ok := symbols[offset-base]; ok {
+				return 0, symbol, relocCall
+			}
+		}
+	}
+
+	if got, ok := memTarget(insn, regs); ok {
+		if symbol, ok := r.gotSymbols[got]; ok {
+			return 0, symbol, gotCall
+		}
+		return 0, "", ""
+	}
+
+	ops := operands(insn)
+	if len(ops) != 1 {
+		return 0, "", ""
+	}
+	addr, ok := immediate(ops[0])
+	if !ok {
+		// Indirect call through a register or a memory operand
+		return 0, "", ""
+	}
+
+	if symbol, ok := r.pltSymbols[addr&^0xf]; ok {
+		return addr, symbol, pltCall
+	}
+	return addr, r.elfFile.MapFctAddrName[addr], directCall
+}
+
+// referencedFunction returns the function whose address is used by an
+// instruction (memory, immediate or adrp/add operands) or nil if there is
+// none.
+func (graph *CallGraph) referencedFunction(insn gapstone.Instruction, regs registers) *CallNode {
+
+	if addr, ok := memTarget(insn, regs); ok {
+		return graph.addrNodes[addr]
+	}
+
+	ops := operands(insn)
+	if insn.Mnemonic == "add" && len(ops) == 3 {
+		if base, ok := regs[ops[1]]; ok {
+			if value, ok := immediate(ops[2]); ok {
+				return graph.addrNodes[base+value]
+			}
+		}
+	}
+
+	for _, operand := range ops {
+		if addr, ok := immediate(operand); ok {
+			if node, ok := graph.addrNodes[addr]; ok {
+				return node
+			}
+		}
+	}
+	return nil
+}
+
+// libOf returns the name of the micro-lib which contains the given address.
+func libOf(libs []elf64analyser.ElfLibs, addr uint64) string {
+	i := sort.Search(len(libs), func(i int) bool {
+		return libs[i].StartAddr > addr
+	})
+	if i > 0 && addr < libs[i-1].EndAddr {
+		return filepath.Base(libs[i-1].Name)
+	}
+	return ""
+}
+
+func (graph *CallGraph) addNode(node *CallNode) *CallNode {
+
+	if n, ok := graph.addrNodes[node.Addr]; ok && node.Addr > 0 {
+		return n
+	}
+
+	// Functions may share the same name (e.g., static functions)
+	if n, ok := graph.Nodes[node.Name]; ok {
+		if node.Addr == 0 || n.Addr == node.Addr {
+			return n
+		}
+		node.Name = fmt.Sprintf("%s@0x%x", node.Name, node.Addr)
+	}
+
+	graph.Nodes[node.Name] = node
+	if node.Addr > 0 {
+		graph.addrNodes[node.Addr] = node
+	}
+	return node
+}
+
+func (graph *CallGraph) addEdge(caller, callee *CallNode, typeCall string) {
+
+	key := caller.Name + "->" + callee.Name
+	if e, ok := graph.mapEdges[key]; ok {
+		e.Count++
+		return
+	}
+
+	edge := &CallEdge{
+		Caller:   caller.Name,
+		Callee:   callee.Name,
+		Type:     typeCall,
+		Count:    1,
+		CrossLib: len(caller.Lib) > 0 && len(callee.Lib) > 0 && caller.Lib != callee.Lib,
+	}
+	graph.mapEdges[key] = edge
+	graph.Edges = append(graph.Edges, edge)
+}
+
+// computeLibCalls aggregates the cross micro-libs edges.
+func (graph *CallGraph) computeLibCalls() {
+
+	mapLibCalls := make(map[string]*LibCall)
+	for _, e := range graph.Edges {
+		if !e.CrossLib {
+			continue
+		}
+		from, to := graph.Nodes[e.Caller].Lib, graph.Nodes[e.Callee].Lib
+		key := from + "->" + to
+		if _, ok := mapLibCalls[key]; !ok {
+			mapLibCalls[key] = &LibCall{From: from, To: to, Functions: make([]string, 0)}
+			graph.LibCalls = append(graph.LibCalls, mapLibCalls[key])
+		}
+		mapLibCalls[key].Count += e.Count
+		if !u.Contains(mapLibCalls[key].Functions, e.Callee) {
+			mapLibCalls[key].Functions = append(mapLibCalls[key].Functions, e.Callee)
+		}
+	}
+
+	sort.SliceStable(graph.LibCalls, func(i, j int) bool {
+		if graph.LibCalls[i].From != graph.LibCalls[j].From {
+			return graph.LibCalls[i].From < graph.LibCalls[j].From
+		}
+		return graph.LibCalls[i].To < graph.LibCalls[j].To
+	})
+}
+
+// BuildCallGraph disassembles all the functions of the text sections of an ELF
+// file and resolves direct, PLT and relocation-based call targets. Each node
+// is attributed to a micro-lib using the given libs address ranges (can be
+// nil). Functions which cannot be disassembled are skipped.
+//
+// It returns a pointer to a CallGraph structure and an error if any, otherwise
+// it returns nil.
+func BuildCallGraph(elfFile *elf64core.ELF64File, libs []elf64analyser.ElfLibs) (*CallGraph, error) {
+
+	a, err := getArch(elfFile)
+	if err != nil {
+		return nil, err
+	}
+
+	engine, err := newEngine(elfFile)
+	if err != nil {
+		return nil, err
+	}
+	defer engine.Close()
+
+	sortedLibs := make([]elf64analyser.ElfLibs, 0, len(libs))
+	for _, lib := range libs {
+		if lib.EndAddr > lib.StartAddr {
+			sortedLibs = append(sortedLibs, lib)
+		}
+	}
+	sort.Slice(sortedLibs, func(i, j int) bool {
+		return sortedLibs[i].StartAddr < sortedLibs[j].StartAddr
+	})
+
+	graph := &CallGraph{
+		Name:      elfFile.Name,
+		Nodes:     make(map[string]*CallNode),
+		Edges:     make([]*CallEdge, 0),
+		LibCalls:  make([]*LibCall, 0),
+		addrNodes: make(map[uint64]*CallNode),
+		mapEdges:  make(map[string]*CallEdge),
+	}
+
+	// Create a node per function
+	type function struct {
+		node    *CallNode
+		section *elf64core.DataSections
+	}
+	functions := make([]function, 0)
+	for _, table := range elfFile.FunctionsTables {
+		index, ok := elfFile.IndexSections[table.Name]
+		if !ok {
+			continue
+		}
+		section := elfFile.SectionsTable.DataSect[index]
+		for _, f := range table.Functions {
+			node := graph.addNode(&CallNode{
+				Name:    f.Name,
+				Addr:    f.Addr,
+				Size:    f.Size,
+				Section: table.Name,
+				Lib:     libOf(sortedLibs, f.Addr),
+			})
+			functions = append(functions, function{node: node, section: section})
+		}
+	}
+
+	r := newResolver(elfFile, engine, a)
+	for _, f := range functions {
+		if f.node.Size == 0 {
+			continue
+		}
+
+		content, err := sectionContent(elfFile, f.section, f.node.Addr, f.node.Size)
+		if err != nil {
+			u.PrintWarning(fmt.Sprintf("Skip function %s: %s", f.node.Name, err))
+			continue
+		}
+
+		insns, err := engine.Disasm(content, f.node.Addr, 0)
+		if err != nil {
+			u.PrintWarning(fmt.Sprintf("Cannot disassemble function %s: %v", f.node.Name, err))
+			continue
+		}
+
+		regs := make(registers)
+		for _, insn := range insns {
+			graph.addInsnEdges(r, f.node, f.section.Name, insn, regs)
+			regs.update(insn)
+		}
+	}
+
+	graph.computeLibCalls()
+
+	return graph, nil
+}
+
+// addInsnEdges adds the edge(s) corresponding to an instruction of a function.
+func (graph *CallGraph) addInsnEdges(r *resolver, caller *CallNode, section string,
+	insn gapstone.Instruction, regs registers) {
+
+	isCall := r.arch.isCall(insn)
+	isJmp := r.arch.isJump(insn)
+	if !isCall && !isJmp {
+		// Keep references to functions (e.g., callbacks)
+		if callee := graph.referencedFunction(insn, regs); callee != nil && callee != caller {
+			graph.addEdge(caller, callee, refCall)
+		}
+		return
+	}
+
+	addr, symbol, typeCall := r.resolve(insn, section, regs)
+	if isJmp {
+		// Only keep jumps to the beginning of another function (tail calls)
+		callee, ok := graph.addrNodes[addr]
+		if ok && callee != caller {
+			graph.addEdge(caller, callee, tailCall)
+		}
+		return
+	}
+
+	if len(symbol) == 0 {
+		// Indirect call (register or memory operand) or unknown target
+		graph.Unresolved++
+		return
+	}
+
+	callee, ok := graph.addrNodes[addr]
+	if !ok || typeCall == pltCall {
+		callee = graph.addNode(&CallNode{Name: symbol})
+	}
+	graph.addEdge(caller, callee, typeCall)
+}
+
+// nodeName returns the name of a node used in the DOT graph.
+func (graph *CallGraph) nodeName(name string) string {
+	if n, ok := graph.Nodes[name]; ok && len(n.Lib) > 0 {
+		return n.Lib + ":" + name
+	}
+	return name
+}
+
+// GenerateGraphs saves the function-level call graph and the micro-libs call
+// graph as '.dot' and '.png' files.
+func (graph *CallGraph) GenerateGraphs(programName, fullPathName string) {
+
+	fctGraph := make(map[string][]string, len(graph.Nodes))
+	for _, e := range graph.Edges {
+		caller := graph.nodeName(e.Caller)
+		fctGraph[caller] = append(fctGraph[caller], graph.nodeName(e.Callee))
+	}
+	u.GenerateGraph(programName, fullPathName+"_callgraph", fctGraph, nil)
+
+	libGraph := make(map[string][]string)
+	for _, l := range graph.LibCalls {
+		libGraph[l.From] = append(libGraph[l.From], l.To)
+	}
+	u.GenerateGraph(programName, fullPathName+"_libgraph", libGraph, nil)
+}
+
+// SaveJson saves the call graph into a json file.
+//
+// It returns an error if any, otherwise it returns nil.
+func (graph *CallGraph) SaveJson(fullPathName string) error {
+	return u.RecordDataJson(fullPathName+"_callgraph", graph)
+}
+
+func (graph *CallGraph) DisplayLibCalls() {
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 8, 1, '\t', 0)
+	fmt.Println("-----------------------------------------------------------------------")
+	fmt.Printf("Call graph of %s: %d functions, %d edges, %d unresolved calls\n",
+		graph.Name, len(graph.Nodes), len(graph.Edges), graph.Unresolved)
+
+	if len(graph.LibCalls) == 0 {
+		_ = w.Flush()
+		return
+	}
+
+	_, _ = fmt.Fprintln(w, "\nFrom\tTo\tCalls\tFunctions")
+	for _, l := range graph.LibCalls {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", l.From, l.To, l.Count,
+			strings.Join(l.Functions, ", "))
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,244 @@
+// Copyright 2019 The UNICORE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// Author: Gaulthier Gain <gaulthier.gain@uliege.be>
+
+package elf64disassembler
+
+import (
+	"debug/elf"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"tools/srcs/binarytool/elf64analyser"
+	"tools/srcs/binarytool/elf64core"
+	u "tools/srcs/common"
+)
+
+const unknownLib = "(unknown)"
+
+// Functions used as entry points of a unikernel
+var entryFunctions = []string{"_libkvmplat_entry"}
+
+// Sections which contain pointers to functions called at boot
+var entrySections = []string{"uk_inittab", "uk_ctortab", ".init_array"}
+
+type DeadFunctions struct {
+	Name           string     `json:"name"`
+	EntryPoints    []string   `json:"entryPoints"`
+	AddressTaken   int        `json:"addressTaken"`
+	TotalFunctions int        `json:"totalFunctions"`
+	NbDead         int        `json:"nbDead"`
+	DeadBytes      uint64     `json:"deadBytes"`
+	Libs           []*DeadLib `json:"libs"`
+}
+
+type DeadLib struct {
+	Name      string      `json:"name"`
+	LibSize   uint64      `json:"libSize"`
+	DeadBytes uint64      `json:"deadBytes"`
+	Functions []*CallNode `json:"functions"`
+}
+
+// readPointers reads all the pointers (4 or 8 bytes according to the class)
+// contained in a section.
+func readPointers(elfFile *elf64core.ELF64File, index int) []uint64 {
+
+	content, err := elfFile.GetSectionContent(uint16(index))
+	if err != nil {
+		u.PrintWarning(err)
+		return nil
+	}
+
+	size := elfFile.AddrSize()
+	pointers := make([]uint64, 0, len(content)/size)
+	for i := 0; i+size <= len(content); i += size {
+		pointers = append(pointers, elfFile.ReadAddr(content[i:i+size]))
+	}
+	return pointers
+}
+
+// findRoots finds the entry points of the unikernel: the entry functions, the
+// ELF entry point and the functions referenced by the init/ctor tables. The
+// functions whose address is stored in data sections are also considered as
+// roots since they can be called indirectly.
+//
+// It returns the entry points and the address-taken functions.
+func (graph *CallGraph) findRoots(elfFile *elf64core.ELF64File) ([]*CallNode, []*CallNode) {
+
+	entries := make([]*CallNode, 0)
+	addEntry := func(node *CallNode) {
+		for _, e := range entries {
+			if e == node {
+				return
+			}
+		}
+		entries = append(entries, node)
+	}
+
+	for _, name := range entryFunctions {
+		if node, ok := graph.Nodes[name]; ok {
+			addEntry(node)
+		}
+	}
+
+	if node, ok := graph.addrNodes[elfFile.Header.EntryPoint]; ok {
+		addEntry(node)
+	}
+
+	addressTaken := make([]*CallNode, 0)
+	for i, s := range elfFile.SectionsTable.DataSect {
+		header := s.Elf64section
+		if header.Flags&uint64(elf.SHF_ALLOC) == 0 ||
+			header.Flags&uint64(elf.SHF_EXECINSTR) != 0 ||
+			header.Type == uint32(elf.SHT_NOBITS) {
+			continue
+		}
+
+		isEntrySection := false
+		for _, name := range entrySections {
+			if strings.Contains(s.Name, name) {
+				isEntrySection = true
+			}
+		}
+
+		for _, ptr := range readPointers(elfFile, i) {
+			node, ok := graph.addrNodes[ptr]
+			if !ok {
+				continue
+			}
+			if isEntrySection {
+				addEntry(node)
+			} else {
+				addressTaken = append(addressTaken, node)
+			}
+		}
+	}
+
+	return entries, addressTaken
+}
+
+// reachable returns the set of functions which are reachable from the given
+// roots.
+func (graph *CallGraph) reachable(roots []*CallNode) map[string]bool {
+
+	callees := make(map[string][]string, len(graph.Nodes))
+	for _, e := range graph.Edges {
+		callees[e.Caller] = append(callees[e.Caller], e.Callee)
+	}
+
+	visited := make(map[string]bool, len(graph.Nodes))
+	stack := make([]string, 0, len(roots))
+	for _, r := range roots {
+		stack = append(stack, r.Name)
+	}
+
+	for len(stack) > 0 {
+		name := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if visited[name] {
+			continue
+		}
+		visited[name] = true
+		stack = append(stack, callees[name]...)
+	}
+
+	return visited
+}
+
+// FindDeadFunctions finds the functions which are unreachable from the entry
+// points of the unikernel and groups them by micro-lib.
+//
+// It returns a pointer to a DeadFunctions structure.
+func (graph *CallGraph) FindDeadFunctions(elfFile *elf64core.ELF64File,
+	mapLibs map[string]*elf64analyser.ElfLibs) *DeadFunctions {
+
+	entries, addressTaken := graph.findRoots(elfFile)
+	visited := graph.reachable(append(entries, addressTaken...))
+
+	dead := &DeadFunctions{
+		Name:         graph.Name,
+		EntryPoints:  make([]string, 0, len(entries)),
+		AddressTaken: len(addressTaken),
+		Libs:         make([]*DeadLib, 0),
+	}
+	for _, e := range entries {
+		dead.EntryPoints = append(dead.EntryPoints, e.Name)
+	}
+
+	if len(entries) == 0 {
+		u.PrintWarning("No entry point found in " + graph.Name)
+	}
+
+	libs := make(map[string]*DeadLib)
+	for name, node := range graph.Nodes {
+		if node.Addr == 0 {
+			// External function (e.g., PLT)
+			continue
+		}
+		dead.TotalFunctions++
+		if visited[name] {
+			continue
+		}
+
+		libName := node.Lib
+		if len(libName) == 0 {
+			libName = unknownLib
+		}
+		if _, ok := libs[libName]; !ok {
+			libs[libName] = &DeadLib{Name: libName, Functions: make([]*CallNode, 0)}
+			if lib, ok := mapLibs[node.Lib]; ok {
+				libs[libName].LibSize = lib.Size
+			}
+			dead.Libs = append(dead.Libs, libs[libName])
+		}
+		libs[libName].Functions = append(libs[libName].Functions, node)
+		libs[libName].DeadBytes += node.Size
+		dead.DeadBytes += node.Size
+		dead.NbDead++
+	}
+
+	for _, lib := range dead.Libs {
+		sort.Slice(lib.Functions, func(i, j int) bool {
+			return lib.Functions[i].Addr < lib.Functions[j].Addr
+		})
+	}
+	sort.SliceStable(dead.Libs, func(i, j int) bool {
+		return dead.Libs[i].DeadBytes > dead.Libs[j].DeadBytes
+	})
+
+	return dead
+}
+
+// SaveJson saves the dead functions report into a json file.
+//
+// It returns an error if any, otherwise it returns nil.
+func (dead *DeadFunctions) SaveJson(fullPathName string) error {
+	return u.RecordDataJson(fullPathName+"_dead", dead)
+}
+
+func (dead *DeadFunctions) DisplayDeadFunctions() {
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 8, 1, '\t', 0)
+	fmt.Println("-----------------------------------------------------------------------")
+	fmt.Printf("Dead functions of %s (entry points: %s):\n", dead.Name,
+		strings.Join(dead.EntryPoints, ", "))
+
+	_, _ = fmt.Fprintln(w, "\nMicro-lib\tLib size\tDead functions\tDead bytes\tRatio (%)")
+	for _, lib := range dead.Libs {
+		ratio := 0.0
+		if lib.LibSize > 0 {
+			ratio = float64(lib.DeadBytes) / float64(lib.LibSize) * 100
+		}
+		_, _ = fmt.Fprintf(w, "%s\t0x%x\t%d\t%d (0x%x)\t%.2f\n", lib.Name, lib.LibSize,
+			len(lib.Functions), lib.DeadBytes, lib.DeadBytes, ratio)
+	}
+	_ = w.Flush()
+
+	fmt.Printf("- Dead functions: %d/%d\n", dead.NbDead, dead.TotalFunctions)
+	fmt.Printf("- Total bytes that could be dropped: %d (0x%x)\n", dead.DeadBytes, dead.DeadBytes)
+}
@@ -1,62 +1,66 @@
 package elf64disassembler
 
 import (
+	"errors"
 	"fmt"
 	"github.com/knightsc/gapstone"
-	"log"
 	"strconv"
 	"strings"
 	"tools/srcs/binarytool/elf64core"
 )
 
-//disassembler.Disass_section(elfFile, s)
-
-func hex2int(hexStr string) uint64 {
+func hex2int(hexStr string) (uint64, error) {
 	// remove 0x suffix if found in the input string
 	cleaned := strings.Replace(hexStr, "0x", "", -1)
 
 	// base 16 for hexadecimal
-	result, _ := strconv.ParseUint(cleaned, 16, 64)
-	return uint64(result)
+	return strconv.ParseUint(cleaned, 16, 64)
 }
 
-func Disass_section(elfFile *elf64core.ELF64File, sections *elf64core.DataSections) {
+// sectionContent returns the bytes of an ELF file between the given virtual
+// address and size of a section.
+func sectionContent(elfFile *elf64core.ELF64File, section *elf64core.DataSections,
+	addr, size uint64) ([]byte, error) {
 
-	sect_data := elfFile.Raw[sections.Elf64section.FileOffset : sections.Elf64section.FileOffset+sections.Elf64section.Size]
+	header := section.Elf64section
+	if addr < header.VirtualAddress || addr+size > header.VirtualAddress+header.Size {
+		return nil, fmt.Errorf("address 0x%x is out of section %s", addr, section.Name)
+	}
 
-	engine, err := gapstone.New(
-		gapstone.CS_ARCH_X86,
-		gapstone.CS_MODE_64,
-	)
+	start := header.FileOffset + addr - header.VirtualAddress
+	if start+size > uint64(len(elfFile.Raw)) {
+		return nil, errors.New("section " + section.Name + " is out of the ELF file")
+	}
+	return elfFile.Raw[start : start+size], nil
+}
+
+// DisassSection disassembles the whole content of a section.
+//
+// It returns a slice of instructions and an error if any, otherwise it returns
+// nil.
+func DisassSection(elfFile *elf64core.ELF64File, section *elf64core.DataSections) ([]gapstone.Instruction, error) {
+
+	engine, err := newEngine(elfFile)
 	if err != nil {
-		log.Fatalf("Disassembly error: %v", err)
+		return nil, err
 	}
+	defer engine.Close()
 
-	maj, min := engine.Version()
-	log.Printf("Hello Capstone! Version: %v.%v\n", maj, min)
+	content, err := sectionContent(elfFile, section,
+		section.Elf64section.VirtualAddress, section.Elf64section.Size)
+	if err != nil {
+		return nil, err
+	}
 
 	insns, err := engine.Disasm(
-		sect_data,                            // code buffer
-		sections.Elf64section.VirtualAddress, // starting address
+		content,                             // code buffer
+		section.Elf64section.VirtualAddress, // starting address
 		0,
 	)
 	if err != nil {
-		log.Fatalf("Disassembly error: %v", err)
-	}
-
-	log.Printf("Disasm: %d %d\n", len(sect_data), len(insns))
-	for _, insn := range insns {
-
-		callStr := ""
-		if insn.Mnemonic == "call" {
-			fmt.Printf("0x%x -> ", insn.Bytes)
-			fmt.Printf("0x%x:\t[%s]\t\t{%s}%s\n", insn.Address, insn.Mnemonic, insn.OpStr, callStr)
-			if val, ok := elfFile.MapFctAddrName[hex2int(insn.OpStr)]; ok {
-				println("\t" + val)
-			}
-
-		}
-
+		return nil, fmt.Errorf("disassembly error of section %s: %v",
+			section.Name, err)
 	}
 
+	return insns, nil
 }
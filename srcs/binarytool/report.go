@@ -0,0 +1,187 @@
+// Copyright 2019 The UNICORE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// Author: Gaulthier Gain <gaulthier.gain@uliege.be>
+
+package binarytool
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"tools/srcs/binarytool/elf64analyser"
+	"tools/srcs/binarytool/elf64core"
+	u "tools/srcs/common"
+)
+
+const (
+	jsonFormat = "json"
+	csvFormat  = "csv"
+)
+
+type Report struct {
+	Name      string `json:"name"`
+	BuildPath string `json:"buildPath,omitempty"`
+	Kernel    string `json:"kernel"`
+
+	Libs        []elf64analyser.ElfLibs        `json:"libs"`
+	Sources     *elf64analyser.SourcesMapping  `json:"sources,omitempty"`
+	StatSize    *elf64analyser.StatSize        `json:"statSize"`
+	Sections    []elf64core.SectionInfo        `json:"sections"`
+	Addresses   []elf64analyser.AddressSection `json:"addresses,omitempty"`
+	Symbols     []elf64core.SymbolInfo         `json:"symbols"`
+	Relocations []elf64core.RelocationInfo     `json:"relocations"`
+	Notes       []elf64core.NoteInfo           `json:"notes"`
+}
+
+// checkFormat checks that the given report format is supported.
+//
+// It returns an error if any, otherwise it returns nil.
+func checkFormat(format string) error {
+	if len(format) > 0 && format != jsonFormat && format != csvFormat {
+		return errors.New("unsupported report format: " + format +
+			" (must be " + jsonFormat + " or " + csvFormat + ")")
+	}
+	return nil
+}
+
+// createReport gathers all the information of a unikernel into a Report
+// structure.
+//
+// It returns a pointer to a Report structure.
+func (uk *Unikernel) createReport() *Report {
+
+	report := &Report{
+		Name:        uk.shortName(),
+		BuildPath:   uk.BuildPath,
+		Kernel:      uk.ElfFile.Name,
+		Libs:        uk.Analyser.ElfLibs,
+		Sources:     uk.Analyser.SourcesMapping,
+		StatSize:    uk.Analyser.ComputeStatSize(uk.ElfFile),
+		Sections:    uk.ElfFile.SectionsTable.GetSections(),
+		Symbols:     uk.ElfFile.GetSymbols(),
+		Relocations: uk.ElfFile.GetRelocations(),
+		Notes:       uk.ElfFile.GetNotes(),
+	}
+
+	if report.Libs == nil {
+		report.Libs = make([]elf64analyser.ElfLibs, 0)
+	}
+
+	if len(uk.FindSectionByAddress) > 0 {
+		report.Addresses = uk.Analyser.ResolveAddresses(uk.ElfFile,
+			uk.FindSectionByAddress)
+	}
+
+	return report
+}
+
+// saveReport saves the report of a unikernel into the given folder using the
+// given format (json|csv).
+//
+// It returns the path of the report and an error if any, otherwise it returns
+// nil.
+func (uk *Unikernel) saveReport(format, outFolder string) (string, error) {
+
+	if err := checkFormat(format); err != nil {
+		return "", err
+	}
+
+	if _, err := u.CreateFolder(outFolder); err != nil {
+		return "", err
+	}
+
+	report := uk.createReport()
+	filename := filepath.Join(outFolder, report.Name+"_report")
+	if format == jsonFormat {
+		return filename + ".json", u.RecordDataJson(filename, report)
+	}
+
+	return filename + ".csv", report.writeCsv(filename + ".csv")
+}
+
+func hexa(value uint64) string {
+	return fmt.Sprintf("0x%x", value)
+}
+
+// writeCsv writes a report into a csv file. Each row describes one entry of a
+// table of the report (lib, dir, file, stat, section, address, symbol,
+// relocation or note) with the following columns: table, name, address, size, type, info.
+//
+// It returns an error if any, otherwise it returns nil.
+func (report *Report) writeCsv(filename string) error {
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	records := [][]string{{"table", "name", "address", "size", "type", "info"}}
+
+	for _, lib := range report.Libs {
+		records = append(records, []string{"lib", lib.Name, hexa(lib.StartAddr),
+			strconv.FormatUint(lib.Size, 10), "",
+			fmt.Sprintf("nbSymbols=%d;rodataSize=%d;dataSize=%d;bssSize=%d",
+				lib.NbSymbols, lib.RodataSize, lib.DataSize, lib.BssSize)})
+	}
+
+	if report.Sources != nil {
+		for _, s := range report.Sources.Dirs {
+			records = append(records, []string{"dir", s.Name, "",
+				strconv.FormatUint(s.TextSize+s.DataSize, 10), "",
+				fmt.Sprintf("textSize=%d;dataSize=%d", s.TextSize, s.DataSize)})
+		}
+		for _, s := range report.Sources.Files {
+			records = append(records, []string{"file", s.Name, "",
+				strconv.FormatUint(s.TextSize+s.DataSize, 10), "",
+				fmt.Sprintf("textSize=%d;dataSize=%d", s.TextSize, s.DataSize)})
+		}
+	}
+
+	for _, s := range report.StatSize.Sections {
+		records = append(records, []string{"stat", s.Name, hexa(s.Address),
+			strconv.FormatUint(s.Size, 10), "", fmt.Sprintf("pages=%.2f", s.Pages)})
+	}
+	records = append(records,
+		[]string{"stat", "total.text", "", strconv.FormatUint(report.StatSize.TotalSizeText, 10), "", ""},
+		[]string{"stat", "total", "", strconv.FormatUint(report.StatSize.TotalSizeElf, 10), "", ""})
+
+	for _, s := range report.Sections {
+		records = append(records, []string{"section", s.Name, hexa(s.Address),
+			strconv.FormatUint(s.Size, 10), s.Type, "offset=" + hexa(s.Offset)})
+	}
+
+	for _, a := range report.Addresses {
+		for _, s := range a.Sections {
+			records = append(records, []string{"address", s, a.Address, "", "", ""})
+		}
+	}
+
+	for _, s := range report.Symbols {
+		records = append(records, []string{"symbol", s.Name, hexa(s.Value),
+			strconv.FormatUint(s.Size, 10), s.Type, "table=" + s.Table})
+	}
+
+	for _, r := range report.Relocations {
+		records = append(records, []string{"relocation", r.Symbol, hexa(r.Offset),
+			"", r.Type, fmt.Sprintf("table=%s;addend=%d", r.Table, r.Addend)})
+	}
+
+	for _, n := range report.Notes {
+		records = append(records, []string{"note", n.Owner, "",
+			strconv.FormatUint(uint64(n.Size), 10), strconv.FormatUint(uint64(n.Type), 10),
+			"section=" + n.Section + ";description=" + n.Description})
+	}
+
+	if err := w.WriteAll(records); err != nil {
+		return err
+	}
+
+	return nil
+}
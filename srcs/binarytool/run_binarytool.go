@@ -11,8 +11,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"tools/srcs/binarytool/elf64analyser"
+	"tools/srcs/binarytool/elf64core"
 	u "tools/srcs/common"
 )
 
@@ -41,7 +43,13 @@ func RunBinaryAnalyser(homeDir string) {
 	// Check if a json file is used or if it is via command line
 	manager := new(BinaryManager)
 
-	if len(*args.StringArg[rootArg]) > 0 {
+	if len(*args.StringListArg[diffArg]) > 0 {
+		if err := manager.runDiff(*args.StringListArg[diffArg],
+			*args.StringArg[formatArg], *args.StringArg[outputArg]); err != nil {
+			u.PrintErr(err)
+		}
+		return
+	} else if len(*args.StringArg[rootArg]) > 0 {
 		manager.Unikernels = make([]*Unikernel, 0)
 		mapping := false
 		if *args.BoolArg[mappingArg] {
@@ -63,6 +71,11 @@ func RunBinaryAnalyser(homeDir string) {
 				manager.Unikernels = append(manager.Unikernels, &Unikernel{
 					BuildPath:      basename,
 					DisplayMapping: mapping,
+					CompareGroup:   1,
+					Format:         *args.StringArg[formatArg],
+					CallGraph:      *args.BoolArg[callGraphArg],
+					DeadFunctions:  *args.BoolArg[deadArg],
+					DisplaySources: *args.BoolArg[sourcesArg],
 				})
 			}
 		}
@@ -73,45 +86,33 @@ func RunBinaryAnalyser(homeDir string) {
 			u.PrintErr(err)
 		}
 
+		// Command line arguments override the configuration of each unikernel
+		for _, uk := range manager.Unikernels {
+			if len(*args.StringArg[formatArg]) > 0 {
+				uk.Format = *args.StringArg[formatArg]
+			}
+			if *args.BoolArg[callGraphArg] {
+				uk.CallGraph = true
+			}
+			if *args.BoolArg[deadArg] {
+				uk.DeadFunctions = true
+			}
+			if *args.BoolArg[sourcesArg] {
+				uk.DisplaySources = true
+			}
+		}
+
 	} else {
 		u.PrintErr(errors.New("argument(s) must be provided"))
 	}
 
-	var comparison elf64analyser.ComparisonElf
-	comparison.GroupFileSegment = make([]*elf64analyser.ElfFileSegment, 0)
-
-	var UnikernelsPath []string
-	UnikernelsPath = append(UnikernelsPath, "-u")
-	var SplitSects []string
-	SplitSects = append(SplitSects, "-l")
+	// Group unikernels by comparison group
+	groups := make(map[int][]*Unikernel)
 
 	for i, uk := range manager.Unikernels {
 
-		uk.Analyser = new(elf64analyser.ElfAnalyser)
-
-		if len(uk.BuildPath) > 0 {
-
-			if _, err := os.Stat(filepath.Join(uk.BuildPath, "build")); !os.IsNotExist(err) {
-				// A build folder exist
-				uk.BuildPath = filepath.Join(uk.BuildPath, "build")
-			} else {
-				u.PrintWarning("Cannot find 'build/' folder, skip this configuration...")
-			}
-
-			if uk.BuildPath[len(uk.BuildPath)-1] != os.PathSeparator {
-				uk.BuildPath += u.SEP
-			}
-
-			if err := uk.GetFiles(); err != nil {
-				u.PrintErr(err)
-			}
-
-			// Perform the inspection of micro-libs since we have the buildPath
-			uk.Analyser.InspectMappingList(uk.ElfFile, uk.ListObjs)
-		} else {
-			if err := uk.GetKernel(); err != nil {
-				u.PrintErr(err)
-			}
+		if err := uk.loadElfFiles(); err != nil {
+			u.PrintErr(err)
 		}
 
 		if len(uk.DisplayElfFile) > 0 {
@@ -124,6 +125,14 @@ func RunBinaryAnalyser(homeDir string) {
 			fmt.Println("=====================================================")
 		}
 
+		if uk.DisplaySources {
+			if err := uk.Analyser.InspectSourcesMapping(uk.ElfFile); err != nil {
+				u.PrintWarning(err)
+			} else {
+				uk.Analyser.DisplaySourcesMapping()
+			}
+		}
+
 		if uk.DisplayStatSize {
 			uk.Analyser.DisplayStatSize(uk.ElfFile)
 		}
@@ -136,30 +145,72 @@ func RunBinaryAnalyser(homeDir string) {
 			uk.Analyser.FindSectionByAddress(uk.ElfFile, uk.FindSectionByAddress)
 		}
 
-		if uk.CompareGroup > 0 {
-			UnikernelsPath = append(UnikernelsPath, uk.BuildPath+uk.Kernel)
-			for _, sp := range uk.SplitSections {
-				SplitSects = append(SplitSects, sp)
+		if len(uk.Format) > 0 {
+			filename, err := uk.saveReport(uk.Format, *args.StringArg[outputArg])
+			if err != nil {
+				u.PrintErr(err)
 			}
+			u.PrintOk("Report saved into " + filename)
 		}
-	}
 
-	u.PrintInfo("Analysing the following sections of " + strconv.Itoa(len(UnikernelsPath)-1) + " unikernels. This may take some time...")
-	for i, sect := range SplitSects {
-		if i > 0 {
-			println("- " + sect)
+		if uk.CallGraph || uk.DeadFunctions {
+			if err := uk.analyseCallGraph(*args.StringArg[outputArg]); err != nil {
+				u.PrintErr(err)
+			}
+		}
+
+		if uk.CompareGroup > 0 {
+			groups[uk.CompareGroup] = append(groups[uk.CompareGroup], uk)
 		}
 	}
-	script := filepath.Join(os.Getenv("GOPATH"), "src", "tools", "srcs", "binarytool", "scripts", "uk_elf_sharing.py")
-	out, err := u.ExecuteCommand(script, append(UnikernelsPath, SplitSects...))
-	if err != nil {
-		u.PrintErr(err)
+
+	if len(groups) > 0 {
+		manager.comparePages(groups)
+	}
+}
+
+// comparePages splits the sections of each unikernel into pages and computes
+// the pages sharing stats per comparison group.
+func (manager *BinaryManager) comparePages(groups map[int][]*Unikernel) {
+
+	keys := make([]int, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
 	}
-	u.PrintOk("Finish to analyse " + strconv.Itoa(len(UnikernelsPath)-1) + " unikernels")
-	u.PrintInfo("Displaying stats")
+	sort.Ints(keys)
+
+	for _, k := range keys {
+		uks := groups[k]
+		if len(uks) < 2 {
+			u.PrintWarning(fmt.Sprintf("Group %d contains only one unikernel", k))
+		}
+
+		u.PrintInfo(fmt.Sprintf("Analysing pages of %d unikernels (group %d). "+
+			"This may take some time...", len(uks), k))
+
+		comparison := new(elf64analyser.ComparisonElf)
+		comparison.GroupFileSegment = make([]*elf64analyser.ElfFileSegment, 0, len(uks))
+		for _, uk := range uks {
+
+			if len(uk.SplitSections) == 0 {
+				uk.SplitSections = []string{elf64core.TextSection}
+			}
 
-	println(out)
+			for _, sect := range uk.SplitSections {
+				uk.Analyser.SplitIntoPagesBySection(uk.ElfFile, sect)
+			}
+
+			comparison.GroupFileSegment = append(comparison.GroupFileSegment,
+				&elf64analyser.ElfFileSegment{
+					Filename: uk.shortName(),
+					NbPages:  len(uk.Analyser.ElfPage),
+					Pages:    uk.Analyser.ElfPage,
+				})
+		}
+
+		comparison.ComparePageTables()
+		comparison.ComputeSharingStats(k).DisplaySharingStats()
+	}
 
-	u.PrintOk("Diff files have been saved to ./diff/")
-	u.PrintOk("Pages files have been saved to ./pages/")
+	u.PrintOk("Finish to analyse " + strconv.Itoa(len(keys)) + " group(s) of unikernels")
 }
@@ -15,6 +15,7 @@ import (
 	"strings"
 	"tools/srcs/binarytool/elf64analyser"
 	"tools/srcs/binarytool/elf64core"
+	"tools/srcs/binarytool/elf64disassembler"
 	u "tools/srcs/common"
 )
 
@@ -35,6 +36,7 @@ type Unikernel struct {
 	Kernel             string   `json:"kernel"`
 	SplitSections      []string `json:"splitSections"`
 	DisplayMapping     bool     `json:"displayMapping"`
+	DisplaySources     bool     `json:"displaySources"`
 	DisplayStatSize    bool     `json:"displayStatSize"`
 	ComputeLibsMapping bool     `json:"computeLibsMapping"`
 
@@ -45,6 +47,15 @@ type Unikernel struct {
 	FindSectionByAddress []string `json:"findSectionByAddress"`
 	CompareGroup         int      `json:"compareGroup"`
 
+	// Save a report of the unikernel (json|csv)
+	Format string `json:"format"`
+
+	// Generate the call graph of the unikernel (dot, png and json)
+	CallGraph bool `json:"callGraph"`
+
+	// Find the functions which are unreachable from the entry points
+	DeadFunctions bool `json:"deadFunctions"`
+
 	// Used to generate new link.lds file
 	ComputeTextAddr string   `json:"computeTextAddr"`
 	LibsMapping     []string `json:"LibsMapping"`
@@ -143,6 +154,81 @@ func (uk *Unikernel) GetFiles() error {
 	return nil
 }
 
+// loadElfFiles parses the ELF file(s) of a unikernel. If a build path is
+// given, object files are also parsed to inspect the micro-libs mapping.
+//
+// It returns an error if any, otherwise it returns nil.
+func (uk *Unikernel) loadElfFiles() error {
+
+	uk.Analyser = new(elf64analyser.ElfAnalyser)
+
+	if len(uk.BuildPath) == 0 {
+		return uk.GetKernel()
+	}
+
+	var found bool
+	if uk.BuildPath, found = u.BuildFolder(uk.BuildPath); !found {
+		u.PrintWarning("Cannot find 'build/' folder, skip this configuration...")
+	}
+
+	if err := uk.GetFiles(); err != nil {
+		return err
+	}
+
+	// Perform the inspection of micro-libs since we have the buildPath
+	uk.Analyser.InspectMappingList(uk.ElfFile, uk.ListObjs)
+
+	return nil
+}
+
+// shortName returns the name of the application folder of the unikernel if a
+// build path is used, otherwise it returns the name of the kernel file.
+func (uk *Unikernel) shortName() string {
+	if len(uk.BuildPath) > 0 {
+		appPath := strings.TrimSuffix(filepath.Clean(uk.BuildPath), u.SEP+"build")
+		return filepath.Base(appPath)
+	}
+	return filepath.Base(uk.Kernel)
+}
+
+// analyseCallGraph builds the call graph of the unikernel. Depending on the
+// configuration, the call graph is saved into the given folder (dot, png and
+// json files) and/or the dead functions are reported.
+//
+// It returns an error if any, otherwise it returns nil.
+func (uk *Unikernel) analyseCallGraph(outFolder string) error {
+
+	graph, err := elf64disassembler.BuildCallGraph(uk.ElfFile, uk.Analyser.ElfLibs)
+	if err != nil {
+		return err
+	}
+
+	if _, err := u.CreateFolder(outFolder); err != nil {
+		return err
+	}
+	fullPathName := filepath.Join(outFolder, uk.shortName())
+
+	if uk.CallGraph {
+		graph.DisplayLibCalls()
+		graph.GenerateGraphs(uk.shortName(), fullPathName)
+		if err := graph.SaveJson(fullPathName); err != nil {
+			return err
+		}
+		u.PrintOk("Call graph saved into " + fullPathName + "_callgraph.json")
+	}
+
+	if uk.DeadFunctions {
+		dead := graph.FindDeadFunctions(uk.ElfFile, uk.Analyser.MapElfLibs)
+		dead.DisplayDeadFunctions()
+		if err := dead.SaveJson(fullPathName); err != nil {
+			return err
+		}
+		u.PrintOk("Dead functions saved into " + fullPathName + "_dead.json")
+	}
+
+	return nil
+}
+
 func (uk *Unikernel) displayAllElfInfo() {
 	uk.ElfFile.Header.DisplayHeader()
 	uk.ElfFile.SectionsTable.DisplaySections()
@@ -155,6 +241,16 @@ func (uk *Unikernel) displayAllElfInfo() {
 	uk.ElfFile.DisplayFunctionsTables(false)
 }
 
+func (uk *Unikernel) displayDwarfUnits() {
+	if uk.ElfFile.DwarfUnits == nil {
+		if err := uk.ElfFile.ParseDwarf(); err != nil {
+			u.PrintWarning(err)
+			return
+		}
+	}
+	uk.ElfFile.DisplayDwarfUnits()
+}
+
 func (uk *Unikernel) DisplayElfInfo() {
 
 	if len(uk.DisplayElfFile) == 1 && uk.DisplayElfFile[0] == "all" {
@@ -179,6 +275,8 @@ func (uk *Unikernel) DisplayElfInfo() {
 				uk.ElfFile.DisplayNotes()
 			} else if d == "functions" {
 				uk.ElfFile.DisplayFunctionsTables(false)
+			} else if d == "dwarf" {
+				uk.displayDwarfUnits()
 			} else {
 				u.PrintWarning("No display configuration found for argument: " + d)
 			}
@@ -21,6 +21,8 @@ const (
 	makefileArg  = "makefile"
 	configArg    = "config"
 	patchArg     = "patch"
+	logStubsArg  = "logStubs"
+	libcArg      = "libc"
 )
 
 // ParseArguments parses arguments of the application.
@@ -46,6 +48,14 @@ func parseLocalArguments(p *argparse.Parser, args *u.Arguments) error {
 		&argparse.Options{Required: false, Help: "Add configuration files"})
 	args.InitArgParse(p, args, u.STRING, "", patchArg,
 		&argparse.Options{Required: false, Help: "Add patch files"})
+	args.InitArgParse(p, args, u.BOOL, "", logStubsArg,
+		&argparse.Options{Required: false, Default: false,
+			Help: "Log the calls to the system call stubs at runtime"})
+	args.InitArgParse(p, args, u.STRING, "", libcArg,
+		&argparse.Options{Required: false, Help: "libc micro-lib checked by the " +
+			"compatibility report (newlib, or musl if libs/external/lib-musl.json " +
+			"exists)"})
+	args.InitBatchArgParse(p)
 
 	return u.ParserWrapper(p, os.Args)
 }
@@ -172,6 +172,35 @@ func updateConfig(kConfigMap map[string]*KConfig,
 	return SetConfig(configs, kConfigMap, items)
 }
 
+// rootfsConfig returns the KConfig entries which mount the given root
+// filesystem (CPIO archive or 9pfs folder) at boot.
+//
+// It returns a list of KConfig.
+func rootfsConfig(rootfs string) []*KConfig {
+	v := "y"
+	if isCpioRootfs(rootfs) {
+		name := "\"initrd\""
+		return []*KConfig{
+			{"CONFIG_LIBRAMFS", &v, configLine},
+			{"CONFIG_LIBUKCPIO", &v, configLine},
+			{"CONFIG_LIBVFSCORE_AUTOMOUNT_ROOTFS", &v, configLine},
+			{"CONFIG_LIBVFSCORE_ROOTFS_INITRD", &v, configLine},
+			{"CONFIG_LIBVFSCORE_ROOTFS", &name, configLine},
+		}
+	}
+
+	name, dev := "\"9pfs\"", "\""+rootfsTag+"\""
+	return []*KConfig{
+		{"CONFIG_VIRTIO_9P", &v, configLine},
+		{"CONFIG_LIBUK9P", &v, configLine},
+		{"CONFIG_LIB9PFS", &v, configLine},
+		{"CONFIG_LIBVFSCORE_AUTOMOUNT_ROOTFS", &v, configLine},
+		{"CONFIG_LIBVFSCORE_ROOTFS_9PFS", &v, configLine},
+		{"CONFIG_LIBVFSCORE_ROOTFS", &name, configLine},
+		{"CONFIG_LIBVFSCORE_ROOTDEV", &dev, configLine},
+	}
+}
+
 // SetConfig updates a specific KConfig entry.
 //
 // It returns a list of KConfig.
@@ -0,0 +1,432 @@
+// Copyright 2019 The UNICORE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// Author: Gaulthier Gain <gaulthier.gain@uliege.be>
+
+package buildtool
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	u "tools/srcs/common"
+)
+
+// Report of the libc compatibility (within the app folder)
+const libcCompatFile = "libc_compat.txt"
+
+// libc micro-libs which can be used by an application (only the ones with a
+// symbols database in libs/ are checked)
+var libcVariants = []string{"lib-newlib", "lib-musl"}
+
+var (
+	// glibc libraries whose symbols are provided by the libc micro-lib
+	libcSharedLibRe = regexp.MustCompile(`^lib(c|m|pthread|dl|rt|util|crypt)\.so`)
+	// Loader and vDSO (they are not needed by a unikernel)
+	systemSharedLibRe = regexp.MustCompile(`^(ld-linux|ld64|linux-vdso|linux-gate)`)
+	// Pointer types (e.g., "char *", "char *const []", "__compar_fn_t")
+	pointerTypeRe = regexp.MustCompile(`[*\[]|_fn_t$|handler_t$`)
+	// Type qualifiers which do not change the compatibility of a prototype
+	qualifierRe = regexp.MustCompile(`\b(const|volatile|restrict|__restrict)\b`)
+)
+
+// Integer types (and typedefs) which are passed the same way
+var integerTypes = map[string]bool{
+	"char": true, "signed char": true, "unsigned char": true, "short": true,
+	"unsigned short": true, "int": true, "unsigned": true, "unsigned int": true,
+	"long": true, "long int": true, "unsigned long": true, "unsigned long int": true,
+	"long long": true, "unsigned long long": true, "_Bool": true, "bool": true,
+	"size_t": true, "ssize_t": true, "off_t": true, "pid_t": true, "uid_t": true,
+	"gid_t": true, "mode_t": true, "socklen_t": true, "time_t": true,
+	"wint_t": true, "wchar_t": true, "intmax_t": true, "uintmax_t": true,
+	"clockid_t": true, "dev_t": true, "ino_t": true, "key_t": true, "id_t": true,
+}
+
+// prototype represents the prototype of a function.
+type prototype struct {
+	returnType string
+	argsType   []string
+	variadic   bool
+}
+
+// libcMismatch represents a function whose prototype in the sources of the
+// application differs from its prototype in the libc micro-lib.
+type libcMismatch struct {
+	name     string
+	sources  string
+	microLib string
+}
+
+// libcCompat represents the compatibility between the libc functions of the
+// application and the libc micro-libs.
+type libcCompat struct {
+	chosen    string
+	suggested string
+	// libc micro-libs without symbols database
+	unchecked  []string
+	symbols    []string
+	coverage   map[string]int
+	missing    []string
+	mismatches []libcMismatch
+	providers  map[string][]string
+}
+
+// LibcCoverage represents the coverage of the libc functions of an
+// application by a libc micro-lib. Missing functions are provided neither by
+// the libc micro-lib nor by another micro-lib. Suggested is empty if a single
+// libc micro-lib is checked.
+type LibcCoverage struct {
+	Libc      string
+	Suggested string
+	Functions int
+	Missing   []string
+}
+
+// typeClass returns the class of a C type (void, pointer, integer, float,
+// double). Other types (e.g., structures, unknown typedefs) are returned as
+// is without their qualifiers.
+//
+// It returns the class of the type.
+func typeClass(t string) string {
+
+	t = strings.Join(strings.Fields(qualifierRe.ReplaceAllString(t, "")), " ")
+	t = strings.TrimPrefix(t, "signed ")
+	switch {
+	case pointerTypeRe.MatchString(t):
+		return "pointer"
+	case integerTypes[t]:
+		return "integer"
+	}
+	return t
+}
+
+// parsePrototype parses a function type as given by clang (e.g.,
+// "int (const char *, int, ...)").
+//
+// It returns the prototype and false if the type cannot be parsed (e.g.,
+// functions which return a function pointer).
+func parsePrototype(s string) (prototype, bool) {
+
+	start := strings.Index(s, "(")
+	if start <= 0 || !strings.HasSuffix(s, ")") {
+		return prototype{}, false
+	}
+
+	// Split the arguments at the top-level commas
+	var proto = prototype{returnType: strings.TrimSpace(s[:start])}
+	depth, last := 0, start+1
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 && i != len(s)-1 {
+				return prototype{}, false
+			}
+		case ',':
+			if depth == 1 {
+				proto.argsType = append(proto.argsType, strings.TrimSpace(s[last:i]))
+				last = i + 1
+			}
+		}
+	}
+	if arg := strings.TrimSpace(s[last : len(s)-1]); len(arg) > 0 {
+		proto.argsType = append(proto.argsType, arg)
+	}
+
+	if n := len(proto.argsType); n > 0 && proto.argsType[n-1] == "..." {
+		proto.argsType, proto.variadic = proto.argsType[:n-1], true
+	} else if n == 1 && proto.argsType[0] == "void" {
+		proto.argsType = nil
+	}
+	return proto, true
+}
+
+// microLibPrototype returns the prototype of a function of a micro-lib.
+//
+// It returns the prototype.
+func microLibPrototype(f MicroLibsFunction) prototype {
+	return prototype{
+		returnType: f.ReturnValue,
+		argsType:   f.ArgsType,
+		variadic:   strings.Contains(f.FullyQualified, "..."),
+	}
+}
+
+// String formats a prototype as a C function type.
+func (p prototype) String() string {
+	args := append([]string{}, p.argsType...)
+	if p.variadic {
+		args = append(args, "...")
+	}
+	return p.returnType + " (" + strings.Join(args, ", ") + ")"
+}
+
+// compatiblePrototypes checks if two prototypes are compatible: they must
+// have the same number of arguments (only the fixed ones if one of them is
+// variadic) and their types must belong to the same classes.
+//
+// It returns true if the prototypes are compatible, false otherwise.
+func compatiblePrototypes(a, b prototype) bool {
+
+	if typeClass(a.returnType) != typeClass(b.returnType) {
+		return false
+	}
+
+	nbArgs := len(a.argsType)
+	if a.variadic || b.variadic {
+		if len(b.argsType) < nbArgs {
+			nbArgs = len(b.argsType)
+		}
+	} else if len(a.argsType) != len(b.argsType) {
+		return false
+	}
+
+	for i := 0; i < nbArgs; i++ {
+		if typeClass(a.argsType[i]) != typeClass(b.argsType[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// libcSymbols gathers the libc functions used by the application: the
+// symbols imported from the glibc libraries found by the static analysis,
+// and the functions of the sources which are provided by a libc micro-lib.
+// The system calls are ignored since they also contain the raw system calls
+// of the binary (e.g., "rt_sigaction") which are not libc functions. glibc
+// internal symbols (e.g., "__libc_start_main") are ignored since they
+// disappear once the sources are built against another libc.
+//
+// It returns the sorted names of the functions.
+func libcSymbols(data *u.Data, libcFunctions map[string]bool) []string {
+
+	symbols := make(map[string]bool)
+	for name, lib := range data.StaticData.Symbols {
+		if libcSharedLibRe.MatchString(lib) {
+			symbols[name] = true
+		}
+	}
+	for name := range data.SourcesData.Symbols {
+		if libcFunctions[name] {
+			symbols[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(symbols))
+	for name := range symbols {
+		if !strings.HasPrefix(name, "_") && len(name) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkLibcCompat compares the libc functions used by the application with
+// the functions of the libc micro-libs. If chosen is empty, the libc
+// micro-lib matched by the build tool (or the suggested one) is checked.
+//
+// It returns a pointer to a libcCompat structure and an error if any,
+// otherwise it returns nil.
+func checkLibcCompat(data *u.Data, matchedLibs []string, chosen string) (*libcCompat,
+	error) {
+
+	compat := &libcCompat{
+		coverage:  make(map[string]int),
+		providers: make(map[string][]string),
+	}
+
+	// Read the functions of all micro-libs
+	libsFolder := filepath.Join(os.Getenv("GOPATH"), "src", "tools", "libs")
+	functions := make(map[string]map[string]MicroLibsFunction)
+	libcFunctions := make(map[string]bool)
+	for _, folder := range []string{"internal", "external"} {
+		files, err := ioutil.ReadDir(filepath.Join(libsFolder, folder))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if filepath.Ext(file.Name()) != JSON {
+				continue
+			}
+			microLibFile, err := readMicroLibJson(path.Join(libsFolder, folder, file.Name()))
+			if err != nil {
+				return nil, err
+			}
+			lib := microLibFile.Filename
+			functions[lib] = make(map[string]MicroLibsFunction)
+			for _, f := range microLibFile.Functions {
+				functions[lib][f.Name] = f
+				if u.Contains(libcVariants, lib) {
+					libcFunctions[f.Name] = true
+				} else if !u.Contains(compat.providers[f.Name], lib) {
+					compat.providers[f.Name] = append(compat.providers[f.Name], lib)
+				}
+			}
+		}
+	}
+
+	// Compute the coverage of each libc micro-lib
+	compat.symbols = libcSymbols(data, libcFunctions)
+	for _, lib := range libcVariants {
+		if _, ok := functions[lib]; !ok {
+			compat.unchecked = append(compat.unchecked, lib)
+			continue
+		}
+		compat.coverage[lib] = 0
+		for _, name := range compat.symbols {
+			if _, ok := functions[lib][name]; ok {
+				compat.coverage[lib]++
+			}
+		}
+		if len(compat.suggested) == 0 || compat.coverage[lib] > compat.coverage[compat.suggested] {
+			compat.suggested = lib
+		}
+	}
+	if len(compat.suggested) == 0 {
+		return nil, fmt.Errorf("no symbols database for the libc micro-libs")
+	}
+
+	// Select the libc micro-lib to check
+	if len(chosen) > 0 && !strings.HasPrefix(chosen, "lib-") {
+		chosen = "lib-" + chosen
+	}
+	for _, lib := range libcVariants {
+		if len(chosen) == 0 && u.Contains(matchedLibs, lib) {
+			chosen = lib
+		}
+	}
+	if len(chosen) == 0 {
+		chosen = compat.suggested
+	} else if u.Contains(compat.unchecked, chosen) {
+		return nil, fmt.Errorf("no symbols database for %s (libs/external/%s%s)", chosen,
+			chosen, JSON)
+	} else if _, ok := compat.coverage[chosen]; !ok {
+		return nil, fmt.Errorf("unknown libc micro-lib: %s (supported: %s)", chosen,
+			strings.Join(libcVariants, ", "))
+	}
+	compat.chosen = chosen
+
+	// List the missing functions and the prototype mismatches
+	for _, name := range compat.symbols {
+		f, ok := functions[chosen][name]
+		if !ok {
+			compat.missing = append(compat.missing, name)
+			continue
+		}
+
+		sources, ok := parsePrototype(data.SourcesData.Symbols[name])
+		if ok && !compatiblePrototypes(sources, microLibPrototype(f)) {
+			compat.mismatches = append(compat.mismatches, libcMismatch{
+				name:     name,
+				sources:  sources.String(),
+				microLib: microLibPrototype(f).String(),
+			})
+		}
+	}
+
+	return compat, nil
+}
+
+// compared checks if several libc micro-libs are compared (i.e., the
+// suggestion is meaningful).
+//
+// It returns true if several libc micro-libs are checked, false otherwise.
+func (compat *libcCompat) compared() bool {
+	return len(compat.coverage) > 1
+}
+
+// uncheckedNote explains which libc micro-libs are not checked.
+//
+// It returns the note or an empty string if all of them are checked.
+func (compat *libcCompat) uncheckedNote() string {
+	if len(compat.unchecked) == 0 {
+		return ""
+	}
+	checked := make([]string, 0, len(compat.coverage))
+	for _, lib := range libcVariants {
+		if _, ok := compat.coverage[lib]; ok {
+			checked = append(checked, lib)
+		}
+	}
+	return "Only " + strings.Join(checked, ", ") + " is checked (no symbols database " +
+		"for " + strings.Join(compat.unchecked, ", ") + ")"
+}
+
+// String formats the libc compatibility as a text report.
+func (compat *libcCompat) String() string {
+
+	var sb strings.Builder
+	sb.WriteString("Coverage of the " + fmt.Sprint(len(compat.symbols)) +
+		" libc functions of the application:\n")
+	for _, lib := range libcVariants {
+		if coverage, ok := compat.coverage[lib]; ok {
+			sb.WriteString(fmt.Sprintf("  %-16s %d/%d\n", lib, coverage, len(compat.symbols)))
+		}
+	}
+	if compat.compared() {
+		sb.WriteString("Suggested libc micro-lib: " + compat.suggested + "\n")
+	}
+	if note := compat.uncheckedNote(); len(note) > 0 {
+		sb.WriteString(note + "\n")
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("Functions missing from " + compat.chosen + ":\n")
+	for _, name := range compat.missing {
+		if libs, ok := compat.providers[name]; ok {
+			sb.WriteString("  " + name + " (provided by " + strings.Join(libs, ",") + ")\n")
+		} else {
+			sb.WriteString("  " + name + "\n")
+		}
+	}
+
+	sb.WriteString("\nPrototype mismatches with " + compat.chosen + ":\n")
+	for _, m := range compat.mismatches {
+		sb.WriteString(fmt.Sprintf("  %-24s sources: %s, %s: %s\n", m.name, m.sources,
+			compat.chosen, m.microLib))
+	}
+	return sb.String()
+}
+
+// runLibcCompat checks the compatibility of the application with the libc
+// micro-libs and saves the report into the app folder.
+func runLibcCompat(data *u.Data, matchedLibs []string, chosen, appFolder string) {
+
+	compat, err := checkLibcCompat(data, matchedLibs, chosen)
+	if err != nil {
+		u.PrintWarning("Cannot check the libc compatibility: " + err.Error())
+		return
+	}
+
+	for _, lib := range libcVariants {
+		if coverage, ok := compat.coverage[lib]; ok {
+			u.PrintInfo(fmt.Sprintf("libc coverage of %s: %d/%d", lib, coverage,
+				len(compat.symbols)))
+		}
+	}
+	if len(compat.missing) > 0 || len(compat.mismatches) > 0 {
+		u.PrintWarning(fmt.Sprintf("%d function(s) missing from %s and %d prototype "+
+			"mismatch(es)", len(compat.missing), compat.chosen, len(compat.mismatches)))
+	}
+	if note := compat.uncheckedNote(); len(note) > 0 {
+		u.PrintInfo(note)
+	}
+	if compat.compared() && compat.suggested != compat.chosen {
+		u.PrintWarning("Better libc coverage with " + compat.suggested)
+	}
+
+	if err := u.WriteToFile(appFolder+libcCompatFile, []byte(compat.String())); err != nil {
+		u.PrintWarning(err)
+	} else {
+		u.PrintOk("libc compatibility report saved into " + appFolder + libcCompatFile)
+	}
+}
@@ -85,7 +85,7 @@ func typeFile(filename string) string {
 //
 // It returns an error if any, otherwise it returns nil.
 func generateMakefileUK(filename, programName, filetype string,
-	makefileLines string, sourceFiles []string) error {
+	makefileLines string, sourceFiles, syscallStubs []string) error {
 
 	var sb strings.Builder
 
@@ -156,6 +156,22 @@ func generateMakefileUK(filename, programName, filetype string,
 			"_BASE)/" + s + "\n")
 	}
 
+	// Add the system call stubs (registered to the system call shim)
+	if len(syscallStubs) > 0 {
+		stubsLib := "APP" + strings.ToUpper(programName) + "STUBS"
+		sb.WriteString("\n########################################" +
+			"########################################\n" +
+			"# System call stubs\n" +
+			"########################################" +
+			"########################################\n" +
+			"$(eval $(call addlib,app" + strings.ToLower(programName) + "stubs))\n\n" +
+			stubsLib + "_SRCS-y += $(APP" + strings.ToUpper(programName) +
+			"_BASE)/" + syscallStubsFile + "\n\n")
+		for _, stub := range syscallStubs {
+			sb.WriteString("UK_PROVIDED_SYSCALLS-y += " + stub + "\n")
+		}
+	}
+
 	// Save the content to Makefile.uk
 	return u.WriteToFile(filename, []byte(sb.String()))
 }
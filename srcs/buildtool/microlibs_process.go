@@ -12,6 +12,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	u "tools/srcs/common"
 )
@@ -200,17 +201,15 @@ func matchSymbols(matchedLibs []string, data map[string]string,
 	return matchedLibs
 }
 
-// matchLibs performs the matching between Unikraft's micro-libs and
-// libraries used by a given application
+// fetchMicroLibsSymbols fetches the symbols of Unikraft's internal and external
+// micro-libs.
 //
-// It returns a list of micro-libs that are required by the application and an
-// error if any, otherwise it returns nil.
-func matchLibs(unikraftLibs string, data *u.Data) ([]string, map[string]string, error) {
+// It returns a map which associates each symbol to the micro-libs which provide
+// it, the external micro-libs and an error if any, otherwise it returns nil.
+func fetchMicroLibsSymbols() (map[string][]string, map[string]string, error) {
 
 	mapSymbols := make(map[string][]string)
 
-	matchedLibs := make([]string, 0)
-
 	folder := filepath.Join(os.Getenv("GOPATH"), "src", "tools", "libs", "internal")
 	if err := fetchSymbolsInternalLibs(folder, mapSymbols); err != nil {
 		return nil, nil, err
@@ -223,6 +222,121 @@ func matchLibs(unikraftLibs string, data *u.Data) ([]string, map[string]string,
 		return nil, nil, err
 	}
 
+	return mapSymbols, externalLibs, nil
+}
+
+// unmatchedSharedLibs returns the shared libraries of the application which
+// are not provided by any micro-lib. A shared library is matched if one of the
+// symbols imported from it is provided by a micro-lib or if a micro-lib has
+// its name (e.g., libz.so.1 and lib-zlib). The loader, the vDSO and the glibc
+// libraries (provided by the libc micro-lib) are ignored.
+//
+// It returns the sorted names of the unmatched shared libraries.
+func unmatchedSharedLibs(data *u.Data, mapSymbols map[string][]string) []string {
+
+	sharedLibs := make(map[string]bool)
+	for _, libs := range []map[string][]string{data.StaticData.SharedLibs,
+		data.DynamicData.SharedLibs} {
+		for lib := range libs {
+			sharedLibs[filepath.Base(lib)] = true
+		}
+	}
+
+	provided := make(map[string]bool)
+	for name, lib := range data.StaticData.Symbols {
+		if _, ok := mapSymbols[name]; ok {
+			provided[lib] = true
+		}
+	}
+
+	microLibs := make(map[string]bool)
+	for _, libs := range mapSymbols {
+		for _, lib := range libs {
+			microLibs[lib] = true
+		}
+	}
+
+	unmatched := make([]string, 0)
+	for lib := range sharedLibs {
+		if systemSharedLibRe.MatchString(lib) || libcSharedLibRe.MatchString(lib) ||
+			provided[lib] {
+			continue
+		}
+		stem := strings.TrimPrefix(strings.SplitN(lib, ".so", 2)[0], "lib")
+		if microLibs["lib-"+stem] || microLibs["lib-lib"+stem] || microLibs["lib-"+stem+"lib"] {
+			continue
+		}
+		unmatched = append(unmatched, lib)
+	}
+	sort.Strings(unmatched)
+	return unmatched
+}
+
+// MicroLibsMatching represents the micro-libs which are required by an
+// application.
+type MicroLibsMatching struct {
+	MicroLibs           []string
+	UnmatchedSharedLibs []string
+	Libc                LibcCoverage
+}
+
+// MatchMicroLibs performs the matching between Unikraft's micro-libs and the
+// libraries used by a given application without cloning them.
+//
+// It returns a pointer to a MicroLibsMatching structure and an error if any,
+// otherwise it returns nil.
+func MatchMicroLibs(data *u.Data) (*MicroLibsMatching, error) {
+
+	mapSymbols, _, err := fetchMicroLibsSymbols()
+	if err != nil {
+		return nil, err
+	}
+
+	matchedLibs := matchSymbols(make([]string, 0), putJsonSymbolsTogether(data), mapSymbols)
+	sort.Strings(matchedLibs)
+
+	compat, err := checkLibcCompat(data, matchedLibs, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the libc functions which are not provided by another micro-lib
+	missing := make([]string, 0, len(compat.missing))
+	for _, name := range compat.missing {
+		if _, ok := compat.providers[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+
+	matching := &MicroLibsMatching{
+		MicroLibs:           matchedLibs,
+		UnmatchedSharedLibs: unmatchedSharedLibs(data, mapSymbols),
+		Libc: LibcCoverage{
+			Libc:      compat.chosen,
+			Functions: len(compat.symbols),
+			Missing:   missing,
+		},
+	}
+	if compat.compared() {
+		matching.Libc.Suggested = compat.suggested
+	}
+	return matching, nil
+}
+
+// matchLibs performs the matching between Unikraft's micro-libs and
+// libraries used by a given application
+//
+// It returns a list of micro-libs that are required by the application and an
+// error if any, otherwise it returns nil.
+func matchLibs(unikraftLibs string, data *u.Data) ([]string, map[string]string, error) {
+
+	matchedLibs := make([]string, 0)
+
+	mapSymbols, externalLibs, err := fetchMicroLibsSymbols()
+	if err != nil {
+		return nil, nil, err
+	}
+
 	dataMap := putJsonSymbolsTogether(data)
 
 	// Perform the symbol matching
@@ -88,12 +88,24 @@ func checkMakeOutput(appFolder string, stderr *string) int {
 func parseMakeOutput(output string) string {
 
 	var sb strings.Builder
-	sb.WriteString("#include <stdio.h>\n")
+	sb.WriteString("#include <errno.h>\n#include <stdio.h>\n")
 
 	undefinedSymbols := make(map[string]*string)
 	var re = regexp.MustCompile(`(?mi).*undefined reference to\s\x60(.*)'`)
 	for _, match := range re.FindAllStringSubmatch(output, -1) {
 		if _, ok := undefinedSymbols[match[1]]; !ok {
+			if params, ok := syscallParams(match[1]); ok {
+				// System call wrappers follow the libc convention
+				args := strings.Join(params, ", ")
+				if len(args) == 0 {
+					args = "void"
+				}
+				sb.WriteString("long " + match[1] + "(" + args + "){\n" +
+					"\terrno = ENOSYS;\n\treturn -1;\n}\n\n")
+				undefinedSymbols[match[1]] = nil
+				u.PrintInfo("Add stub to system call: " + match[1])
+				continue
+			}
 			sb.WriteString("void ")
 			sb.WriteString(match[1])
 			sb.WriteString("(void){\n\tprintf(\"STUB\\n\");\n}\n\n")
@@ -150,6 +162,12 @@ func RunBuildTool(homeDir string, data *u.Data) {
 		u.PrintErr(err)
 	}
 
+	// Get the answers of the batch mode
+	answers, err := u.ReadAnswers(args)
+	if err != nil {
+		u.PrintErr(err)
+	}
+
 	// Get program Name
 	programName := *args.StringArg[programArg]
 
@@ -199,7 +217,7 @@ func RunBuildTool(homeDir string, data *u.Data) {
 	}
 
 	// Create unikraft application path
-	appFolderPtr, err := createUnikraftApp(programName, workspacePath)
+	appFolderPtr, err := createUnikraftApp(programName, workspacePath, answers)
 	if err != nil {
 		u.PrintErr(err)
 	}
@@ -223,21 +241,29 @@ func RunBuildTool(homeDir string, data *u.Data) {
 		u.PrintErr(err)
 	}
 
-	// Filter source files to limit build errors (e.g., remove test files,
-	// multiple main file, ...)
-	filterSourceFiles := filterSourcesFiles(sourceFiles)
-
-	// Prompt file selection
-	prompt := &survey.MultiSelect{
-		Message:  "Select the sources of the program",
-		Options:  sourceFiles,
-		Default:  filterSourceFiles,
-		PageSize: pageSize,
-	}
-
 	var selectedFiles []string
-	if err := survey.AskOne(prompt, &selectedFiles, nil); err != nil {
-		panic(err)
+	if answers.Batch {
+		// Select files from the answers file
+		selectedFiles = selectSourcesFiles(sourceFiles, answers)
+		for _, file := range selectedFiles {
+			u.PrintInfo("Select source: " + file)
+		}
+	} else {
+		// Filter source files to limit build errors (e.g., remove test files,
+		// multiple main file, ...)
+		filterSourceFiles := filterSourcesFiles(sourceFiles)
+
+		// Prompt file selection
+		prompt := &survey.MultiSelect{
+			Message:  "Select the sources of the program",
+			Options:  sourceFiles,
+			Default:  filterSourceFiles,
+			PageSize: pageSize,
+		}
+
+		if err := survey.AskOne(prompt, &selectedFiles, nil); err != nil {
+			panic(err)
+		}
 	}
 
 	// Copy, conform and apply user-provided patches to the new unikernel folder
@@ -267,6 +293,23 @@ func RunBuildTool(homeDir string, data *u.Data) {
 		u.PrintErr(err)
 	}
 
+	// Check the compatibility of the libc functions with the libc micro-libs
+	runLibcCompat(data, matchedLibs, *args.StringArg[libcArg], appFolder)
+
+	// Add the root filesystem generated by the dependency analysis
+	rootfs := ""
+	if len(data.DynamicData.Rootfs) > 0 {
+		if rootfs, err = addRootfs(data.DynamicData.Rootfs, appFolder,
+			&matchedLibs); err != nil {
+			u.PrintErr(err)
+		}
+		u.PrintOk("Add root filesystem: " + rootfs)
+	}
+
+	// Generate the stubs of the system calls which are not provided by Unikraft
+	syscallStubs := addSyscallStubs(workspacePath, appFolder, data,
+		*args.BoolArg[logStubsArg], &matchedLibs)
+
 	// Clone the external git repositories
 	cloneLibsFolders(workspacePath, matchedLibs, externalLibs)
 
@@ -285,7 +328,7 @@ func RunBuildTool(homeDir string, data *u.Data) {
 
 	// Generate Makefiles
 	if err := generateMake(programName, appFolder, workspacePath, *args.StringArg[makefileArg],
-		matchedLibs, selectedFiles, externalLibs); err != nil {
+		matchedLibs, selectedFiles, syscallStubs, externalLibs); err != nil {
 		u.PrintErr(err)
 	}
 
@@ -293,10 +336,18 @@ func RunBuildTool(homeDir string, data *u.Data) {
 	deleteBuildFolder(appFolder)
 
 	// Initialize config files
-	initConfig(appFolder, matchedLibs)
+	initConfig(appFolder, matchedLibs, rootfs)
 
 	// Run make
 	runMake(programName, appFolder)
+
+	// Display the arguments to provide the root filesystem to qemu
+	if isCpioRootfs(rootfs) {
+		u.PrintInfo("Run the unikernel with: -initrd " + rootfs)
+	} else if len(rootfs) > 0 {
+		u.PrintInfo("Run the unikernel with: -fsdev local,id=rootfs,path=" + rootfs +
+			",security_model=none -device virtio-9p-pci,fsdev=rootfs,mount_tag=" + rootfsTag)
+	}
 }
 
 // retFolderCompat modifies its string argument in order to replace its underscore by a dash when
@@ -355,7 +406,7 @@ func searchInternalDependencies(unikraftPath string, matchedLibs *[]string,
 }
 
 func generateMake(programName, appFolder, workspacePath, makefile string,
-	matchedLibs, sourceFiles []string, externalLibs map[string]string) error {
+	matchedLibs, sourceFiles, syscallStubs []string, externalLibs map[string]string) error {
 	// Generate Makefile
 	if err := generateMakefile(appFolder+"Makefile", workspacePath,
 		appFolder, matchedLibs, externalLibs); err != nil {
@@ -373,7 +424,7 @@ func generateMake(programName, appFolder, workspacePath, makefile string,
 
 	// Generate Makefile.uk
 	if err := generateMakefileUK(appFolder+"Makefile.uk", programName,
-		fileType, makefile, sourceFiles); err != nil {
+		fileType, makefile, sourceFiles, syscallStubs); err != nil {
 		return err
 	}
 
@@ -396,7 +447,7 @@ func deleteBuildFolder(appFolder string) {
 	}
 }
 
-func initConfig(appFolder string, matchedLibs []string) {
+func initConfig(appFolder string, matchedLibs []string, rootfs string) {
 
 	// Run make allNoConfig to generate a .config file
 	if strOut, strErr, err := u.ExecuteWaitCommand(appFolder, "make", "allnoconfig"); err != nil {
@@ -420,6 +471,11 @@ func initConfig(appFolder string, matchedLibs []string) {
 	// Update .config
 	items = updateConfig(kConfigMap, items)
 
+	// Mount the root filesystem at boot
+	if len(rootfs) > 0 {
+		items = SetConfig(rootfsConfig(rootfs), kConfigMap, items)
+	}
+
 	// Write .config
 	if err := writeConfig(appFolder+".config", items); err != nil {
 		u.PrintErr(err)
@@ -0,0 +1,112 @@
+// Copyright 2019 The UNICORE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// Author: Gaulthier Gain <gaulthier.gain@uliege.be>
+
+package buildtool
+
+import (
+	"strconv"
+	"strings"
+	"tools/srcs/dependtool"
+
+	u "tools/srcs/common"
+)
+
+// Source file of the system call stubs (within the app folder)
+const syscallStubsFile = "syscall_stubs.c"
+
+// C types of the arguments of system calls (see initSystemCallsArgs)
+var syscallArgTypes = map[rune]string{
+	'i': "int",
+	'u': "unsigned long",
+	's': "const char *",
+	'x': "long",
+}
+
+// syscallParams returns the C parameters of a system call.
+//
+// It returns a slice of string which represents the type and the name of each
+// parameter and false if the arguments of the system call are unknown.
+func syscallParams(name string) ([]string, bool) {
+
+	kinds, ok := dependtool.SystemCallArgs(name)
+	params := make([]string, len(kinds))
+	for i, kind := range kinds {
+		params[i] = syscallArgTypes[kind] + " arg" + strconv.Itoa(i)
+	}
+	return params, ok
+}
+
+// generateSyscallStubs generates the source file of the system call stubs.
+// Each stub is registered to the system call shim and returns -ENOSYS. If
+// logCalls is true, each call is logged at runtime. The system calls whose
+// arguments are unknown are skipped.
+//
+// It returns the registrations of the stubs (e.g., "fork-0") and an error if
+// any, otherwise it returns nil.
+func generateSyscallStubs(filename string, missing []u.SyscallSupport,
+	logCalls bool) ([]string, error) {
+
+	var sb strings.Builder
+	sb.WriteString("/* Stubs of the system calls which are not implemented by " +
+		"Unikraft */\n\n" +
+		"#include <errno.h>\n" +
+		"#include <uk/print.h>\n" +
+		"#include <uk/syscall.h>\n")
+
+	registrations := make([]string, 0, len(missing))
+	for _, s := range missing {
+
+		// UK_SYSCALL_R_DEFINE(rtype, name, type0, arg0, type1, arg1, ...)
+		params, ok := syscallParams(s.Name)
+		if !ok {
+			u.PrintWarning("Cannot stub system call " + s.Name + ": unknown arguments")
+			continue
+		}
+		sb.WriteString("\nUK_SYSCALL_R_DEFINE(long, " + s.Name)
+		for _, param := range params {
+			i := strings.LastIndex(param, " ")
+			sb.WriteString(", " + param[:i] + ", " + param[i+1:])
+		}
+		sb.WriteString(")\n{\n")
+		if logCalls {
+			sb.WriteString("\tuk_pr_warn(\"STUB: " + s.Name + " called\\n\");\n")
+		}
+		sb.WriteString("\treturn -ENOSYS;\n}\n")
+
+		registrations = append(registrations, s.Name+"-"+strconv.Itoa(len(params)))
+		u.PrintInfo("Add stub to system call: " + s.Name)
+	}
+
+	return registrations, u.WriteToFile(filename, []byte(sb.String()))
+}
+
+// addSyscallStubs generates the stubs of the system calls of the application
+// which are not provided by Unikraft and adds the system call shim to the
+// micro-libs.
+//
+// It returns the registrations of the stubs.
+func addSyscallStubs(workspacePath, appFolder string, data *u.Data, logCalls bool,
+	matchedLibs *[]string) []string {
+
+	missing, err := dependtool.MissingSystemCalls(workspacePath, data)
+	if err != nil {
+		u.PrintWarning("Cannot determine the missing system calls: " + err.Error())
+		return nil
+	} else if len(missing) == 0 {
+		return nil
+	}
+
+	registrations, err := generateSyscallStubs(appFolder+syscallStubsFile, missing,
+		logCalls)
+	if err != nil {
+		u.PrintErr(err)
+	}
+
+	if !u.Contains(*matchedLibs, SYSCALLSHIM) {
+		*matchedLibs = append(*matchedLibs, SYSCALLSHIM)
+	}
+	return registrations
+}
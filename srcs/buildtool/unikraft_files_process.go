@@ -106,7 +106,7 @@ func containsUnikraftFolders(files []os.FileInfo) bool {
 
 // ---------------------------UNIKRAFT APP FOLDER-------------------------------
 
-func createUnikraftApp(programName, workspacePath string) (*string, error) {
+func createUnikraftApp(programName, workspacePath string, answers *u.Answers) (*string, error) {
 
 	var appFolder string
 	if workspacePath[len(workspacePath)-1] != os.PathSeparator {
@@ -122,7 +122,11 @@ func createUnikraftApp(programName, workspacePath string) (*string, error) {
 
 	if !created {
 		u.PrintWarning(appFolder + " already exists.")
-		appFolder = handleCreationApp(appFolder)
+		if answers.Batch {
+			appFolder = handleCreationAppBatch(appFolder, answers.AppFolder)
+		} else {
+			appFolder = handleCreationApp(appFolder)
+		}
 		if _, err := u.CreateFolder(appFolder); err != nil {
 			return nil, err
 		}
@@ -160,6 +164,21 @@ func handleCreationApp(appFolder string) string {
 	}
 }
 
+// handleCreationAppBatch handles an existing app folder in batch mode
+// according to the answer (files are overwritten by default).
+func handleCreationAppBatch(appFolder, answer string) string {
+	switch answer {
+	case "", u.OVERWRITE:
+		u.PrintInfo("Copy and overwrite files of " + appFolder)
+		return appFolder
+	case u.EXIT:
+		u.PrintErr(appFolder + " already exists")
+	}
+
+	u.PrintInfo("Use the folder " + answer)
+	return strings.TrimSuffix(answer, u.SEP) + u.SEP
+}
+
 // -------------------------MOVE FILES TO APP FOLDER----------------------------
 
 var srcLanguages = map[string]int{
@@ -186,6 +205,44 @@ func filterSourcesFiles(sourceFiles []string) []string {
 	return filterSrcFiles
 }
 
+// matchGlobs returns true if a file name matches one of the globs.
+func matchGlobs(globs []string, file string) bool {
+	for _, glob := range globs {
+		if ok, err := filepath.Match(glob, file); err != nil {
+			u.PrintWarning(err)
+		} else if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// selectSourcesFiles selects the sources of the program in batch mode. The
+// sources matching the include globs (or the filtered sources if no include
+// glob is given) are selected, except those matching the exclude globs.
+//
+// It returns a slice of string which represents the selected sources.
+func selectSourcesFiles(sourceFiles []string, answers *u.Answers) []string {
+
+	selected := filterSourcesFiles(sourceFiles)
+	if len(answers.IncludeSources) > 0 {
+		selected = make([]string, 0)
+		for _, file := range sourceFiles {
+			if matchGlobs(answers.IncludeSources, file) {
+				selected = append(selected, file)
+			}
+		}
+	}
+
+	selectedFiles := make([]string, 0)
+	for _, file := range selected {
+		if !matchGlobs(answers.ExcludeSources, file) {
+			selectedFiles = append(selectedFiles, file)
+		}
+	}
+	return selectedFiles
+}
+
 // addAndApplyPatchFiles copies all the user-provided patch files to the unikernel directory,
 // conforms them to the unikernel directory format so that all paths in the patch files are paths
 // to source files located in the unikernel folder and applies the patches.
@@ -495,3 +552,64 @@ func languageUsed() string {
 
 	return mostUsedFiles
 }
+
+// ----------------------------ADD ROOT FILESYSTEM------------------------------
+
+// Mount tag of the 9pfs root filesystem
+const rootfsTag = "fs0"
+
+// isCpioRootfs checks if the root filesystem is a CPIO archive (initrd)
+// rather than a 9pfs folder.
+func isCpioRootfs(rootfs string) bool {
+	return strings.HasSuffix(rootfs, ".cpio")
+}
+
+// addRootfs copies the root filesystem generated by the dependency analysis
+// (CPIO archive or 9pfs folder) into the unikernel folder and adds the
+// micro-libs which are required to mount it.
+//
+// It returns the path of the root filesystem within the unikernel folder and
+// an error if any, otherwise it returns nil.
+func addRootfs(rootfs, appFolder string, matchedLibs *[]string) (string, error) {
+
+	libs := []string{VFSCORE, RAMFS}
+	dst := appFolder + "initrd.cpio"
+	if isCpioRootfs(rootfs) {
+		if err := u.CopyFileContents(rootfs, dst); err != nil {
+			return "", err
+		}
+	} else {
+		libs = []string{VFSCORE, PFS9}
+		dst = appFolder + rootfsTag + u.SEP
+		if err := os.RemoveAll(dst); err != nil {
+			return "", err
+		}
+
+		err := filepath.Walk(rootfs, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(rootfs, path)
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return os.MkdirAll(filepath.Join(dst, rel), info.Mode().Perm()|0700)
+			}
+			if err := u.CopyFileContents(path, filepath.Join(dst, rel)); err != nil {
+				return err
+			}
+			return os.Chmod(filepath.Join(dst, rel), info.Mode().Perm())
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	for _, lib := range libs {
+		if !u.Contains(*matchedLibs, lib) {
+			*matchedLibs = append(*matchedLibs, lib)
+		}
+	}
+	return dst, nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2019 The UNICORE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// Author: Gaulthier Gain <gaulthier.gain@uliege.be>
+
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/akamensky/argparse"
+	"gopkg.in/yaml.v2"
+)
+
+// Exported constants to represent the batch mode arguments.
+const (
+	BATCH   = "batch"
+	ANSWERS = "answers"
+)
+
+// Exported constants to represent the choices of the app folder answer.
+const (
+	OVERWRITE = "overwrite"
+	EXIT      = "exit"
+)
+
+// Answers represents the answers to the questions asked by the tools. In
+// batch mode, questions are never asked: the answers are read from an
+// answers file (YAML or JSON) or a default policy is applied.
+type Answers struct {
+	Batch bool `json:"-" yaml:"-"`
+
+	// Package of the program (dependency analyser)
+	PackageName string `json:"packageName" yaml:"packageName"`
+
+	// Existing app folder: "overwrite", "exit" or the path of a new folder
+	// (build tool)
+	AppFolder string `json:"appFolder" yaml:"appFolder"`
+	// Globs of the sources to include and to exclude (build tool)
+	IncludeSources []string `json:"includeSources" yaml:"includeSources"`
+	ExcludeSources []string `json:"excludeSources" yaml:"excludeSources"`
+
+	// Show the diff between the two outputs (verification tool)
+	ShowDiff *bool `json:"showDiff" yaml:"showDiff"`
+}
+
+// InitBatchArgParse initializes the arguments of the batch mode.
+func (args *Arguments) InitBatchArgParse(p *argparse.Parser) {
+	args.InitArgParse(p, args, BOOL, "", BATCH,
+		&argparse.Options{Required: false, Default: false,
+			Help: "Batch mode: never ask questions (use the answers file or the default " +
+				"policy)"})
+	args.InitArgParse(p, args, STRING, "", ANSWERS,
+		&argparse.Options{Required: false, Default: "",
+			Help: "Path of the answers file (YAML or JSON), implies --batch"})
+}
+
+// ReadAnswers reads the answers of the batch mode from the arguments.
+//
+// It returns a pointer to an Answers structure (with Batch set to false if
+// the batch mode is not used) and an error if any, otherwise it returns nil.
+func ReadAnswers(args *Arguments) (*Answers, error) {
+
+	answers := &Answers{}
+	filename := *args.StringArg[ANSWERS]
+	if len(filename) > 0 {
+		byteValue, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		unmarshal := json.Unmarshal
+		ext := strings.ToLower(filepath.Ext(filename))
+		if ext == ".yaml" || ext == ".yml" {
+			unmarshal = yaml.Unmarshal
+		}
+		if err := unmarshal(byteValue, answers); err != nil {
+			return nil, errors.New(filename + ": " + err.Error())
+		}
+	}
+
+	answers.Batch = *args.BoolArg[BATCH] || len(filename) > 0
+	return answers, nil
+}
+
+// DiffShown returns true if the diff between two outputs must be shown in
+// batch mode (it is shown by default).
+func (answers *Answers) DiffShown() bool {
+	return answers.ShowDiff == nil || *answers.ShowDiff
+}
@@ -32,6 +32,7 @@ const (
 	BINARY    = "binary"
 	ALIGNER   = "aligner"
 	EXTRACTER = "extracter"
+	REPORT    = "report"
 )
 
 const (
@@ -109,6 +110,9 @@ func (*Arguments) ParseMainArguments(p *argparse.Parser, args *Arguments) error
 	args.InitArgParse(p, args, BOOL, "", EXTRACTER,
 		&argparse.Options{Required: false, Default: false,
 			Help: "Execute only the symbols extracter tool"})
+	args.InitArgParse(p, args, BOOL, "", REPORT,
+		&argparse.Options{Required: false, Default: false,
+			Help: "Execute only the port-readiness report tool"})
 
 	// Parse only the two first arguments <program name, [tools]>
 	if len(os.Args) > 2 {
@@ -8,17 +8,35 @@ package common
 
 // Exported struct that represents static, dynamic and sources data.
 type Data struct {
-	StaticData  StaticData  `json:"static_data"`
-	DynamicData DynamicData `json:"dynamic_data"`
-	SourcesData SourcesData `json:"sources_data"`
+	StaticData     StaticData       `json:"static_data"`
+	DynamicData    DynamicData      `json:"dynamic_data"`
+	SourcesData    SourcesData      `json:"sources_data"`
+	SyscallSupport []SyscallSupport `json:"syscall_support,omitempty"`
+	// Patterns which are not supported by a single address space (e.g., fork)
+	ExecutionPatterns []ExecutionPattern `json:"execution_patterns,omitempty"`
 }
 
 // Exported struct that represents data for static dependency analysis.
 type StaticData struct {
-	SharedLibs   map[string][]string `json:"shared_libs"`
-	SystemCalls  map[string]int      `json:"system_calls"`
-	Symbols      map[string]string   `json:"symbols"`
-	Dependencies map[string][]string `json:"dependencies"`
+	SharedLibs      map[string][]string `json:"shared_libs"`
+	SystemCalls     map[string]int      `json:"system_calls"`
+	Symbols         map[string]string   `json:"symbols"`
+	Dependencies    map[string][]string `json:"dependencies"`
+	SystemCallSites []SystemCallSite    `json:"system_call_sites,omitempty"`
+	// Execution patterns of the program and their system call sites
+	ExecutionPatterns map[string][]string `json:"execution_patterns,omitempty"`
+}
+
+// Exported struct that represents a system call instruction found by
+// disassembling a binary.
+type SystemCallSite struct {
+	Binary   string `json:"binary"`
+	Address  uint64 `json:"address"`
+	Function string `json:"function,omitempty"`
+	// System call number (-1 if it cannot be resolved)
+	Number   int    `json:"number"`
+	Name     string `json:"name,omitempty"`
+	Resolved bool   `json:"resolved"`
 }
 
 // Exported struct that represents data for dynamic dependency analysis.
@@ -26,10 +44,101 @@ type DynamicData struct {
 	SharedLibs  map[string][]string `json:"shared_libs"`
 	SystemCalls map[string]int      `json:"system_calls"`
 	Symbols     map[string]string   `json:"symbols"`
+	Traces      []SystemCallTrace   `json:"traces,omitempty"`
+	Commands    []CommandData       `json:"commands,omitempty"`
+	FirstSeen   FirstSeen           `json:"first_seen"`
+	TestResults []TestResult        `json:"test_results,omitempty"`
+	// Files opened by the application and the first test command which
+	// opened them (empty if unknown)
+	Files map[string]string `json:"files,omitempty"`
+	// Root filesystem generated from the files (CPIO archive or 9pfs folder)
+	Rootfs string `json:"rootfs,omitempty"`
+	// Execution patterns and the test commands which triggered them
+	ExecutionPatterns map[string][]string `json:"execution_patterns,omitempty"`
+	// Test commands which created threads (clone with CLONE_THREAD)
+	Threads []string `json:"threads,omitempty"`
+}
+
+// Exported struct that represents data triggered by a test command.
+type CommandData struct {
+	Command     string            `json:"command"`
+	SharedLibs  map[string]string `json:"shared_libs"`
+	SystemCalls map[string]int    `json:"system_calls"`
+	Symbols     map[string]string `json:"symbols"`
+}
+
+// Exported struct that represents the first test command which triggered
+// each shared library, system call and symbol.
+type FirstSeen struct {
+	SharedLibs  map[string]string `json:"shared_libs"`
+	SystemCalls map[string]string `json:"system_calls"`
+	Symbols     map[string]string `json:"symbols"`
+}
+
+// Exported struct that represents a system call recorded by the tracer.
+type SystemCallTrace struct {
+	Pid    int      `json:"pid"`
+	Number int      `json:"number"`
+	Name   string   `json:"name"`
+	Args   []string `json:"args"`
+	Return int64    `json:"return"`
+	Error  string   `json:"error,omitempty"`
+	// Absolute path of a file opened with a relative path (resolved by the
+	// tracer)
+	Path string `json:"path,omitempty"`
+}
+
+// Exported struct that represents the result of a test command.
+type TestResult struct {
+	Command  string   `json:"command"`
+	Passed   bool     `json:"passed"`
+	Failures []string `json:"failures,omitempty"`
 }
 
 // Exported struct that represents data for sources dependency analysis.
 type SourcesData struct {
 	SystemCalls map[string]int    `json:"system_calls"`
 	Symbols     map[string]string `json:"symbols"`
+	// Execution patterns and their call sites (file:line)
+	ExecutionPatterns map[string][]string `json:"execution_patterns,omitempty"`
+}
+
+// Exported constants to represent the support of a system call by Unikraft.
+const (
+	SYSCALL_IMPLEMENTED = "implemented"
+	SYSCALL_STUBBED     = "stubbed"
+	SYSCALL_MISSING     = "missing"
+)
+
+// Exported struct that represents the support of a system call used by the
+// application in Unikraft.
+type SyscallSupport struct {
+	Name   string `json:"name"`
+	Number int    `json:"number"`
+	Status string `json:"status"`
+	// Micro-libs which implement (or stub) the system call
+	Libs []string `json:"libs,omitempty"`
+	// Analyses which found the system call (static, dynamic and sources)
+	Analyses []string `json:"analyses"`
+}
+
+// Exported constants to classify the execution patterns which are not
+// supported by a single address space.
+const (
+	PATTERN_PROCESS   = "process creation"
+	PATTERN_EXECUTION = "program execution"
+	PATTERN_SYSV_IPC  = "SysV IPC"
+)
+
+// Exported struct that represents an execution pattern (function or system
+// call) used by the application.
+type ExecutionPattern struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	// Analyses which found the pattern (static, dynamic and sources)
+	Analyses []string `json:"analyses"`
+	// Call sites: source locations (file:line) and disassembly addresses
+	Sites []string `json:"sites,omitempty"`
+	// Test commands which triggered the pattern at runtime
+	Commands []string `json:"commands,omitempty"`
 }
@@ -11,6 +11,8 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 // Exported constants for folder management
@@ -110,6 +112,24 @@ func CreateFolder(path string) (bool, error) {
 	return false, nil
 }
 
+// BuildFolder resolves the build folder of a unikernel: the 'build/' folder of
+// the given application folder if it exists, otherwise the given folder
+// itself.
+//
+// It returns the path of the build folder (with a trailing separator) and
+// true if the 'build/' folder exists, otherwise false.
+func BuildFolder(path string) (string, bool) {
+
+	found := false
+	if info, err := os.Stat(filepath.Join(path, "build")); err == nil && info.IsDir() {
+		path, found = filepath.Join(path, "build"), true
+	}
+	if !strings.HasSuffix(path, SEP) {
+		path += SEP
+	}
+	return path, found
+}
+
 // ReadLinesFile Reads a file line by line and saves its content into a slice.
 //
 // It returns a slice of string which represents each line of a file and an
@@ -9,6 +9,7 @@ package common
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
@@ -132,6 +133,18 @@ func WriteMapToFile(file *os.File, headerName string, in interface{}) error {
 	}
 
 	switch v := in.(type) {
+	case string:
+		if len(v) > 0 {
+			if _, err := file.WriteString(v + "\n"); err != nil {
+				return err
+			}
+		}
+	case []string:
+		for _, value := range v {
+			if _, err := file.WriteString(value + "\n"); err != nil {
+				return err
+			}
+		}
 	case map[string]string:
 		for key, value := range v {
 
@@ -156,6 +169,63 @@ func WriteMapToFile(file *os.File, headerName string, in interface{}) error {
 				str = key
 			}
 
+			if _, err := file.WriteString(str + "\n"); err != nil {
+				return err
+			}
+		}
+	case []CommandData:
+		for _, cmdData := range v {
+
+			str := fmt.Sprintf("%s: %d shared libs, %d system calls, %d symbols",
+				cmdData.Command, len(cmdData.SharedLibs), len(cmdData.SystemCalls),
+				len(cmdData.Symbols))
+
+			if _, err := file.WriteString(str + "\n"); err != nil {
+				return err
+			}
+		}
+	case FirstSeen:
+		for _, m := range []map[string]string{v.SharedLibs, v.SystemCalls, v.Symbols} {
+			for key, command := range m {
+				if _, err := file.WriteString(key + "\t" + command + "\n"); err != nil {
+					return err
+				}
+			}
+		}
+	case []SystemCallSite:
+		for _, site := range v {
+
+			str := fmt.Sprintf("%s 0x%x %s: %s", site.Binary, site.Address,
+				site.Function, site.Name)
+			if !site.Resolved {
+				str += "(unresolved)"
+			}
+
+			if _, err := file.WriteString(str + "\n"); err != nil {
+				return err
+			}
+		}
+	case []SystemCallTrace:
+		for _, trace := range v {
+
+			str := fmt.Sprintf("[%d] %s(%s) = %d", trace.Pid, trace.Name,
+				strings.Join(trace.Args, ", "), trace.Return)
+			if len(trace.Error) > 0 {
+				str += " (" + trace.Error + ")"
+			}
+
+			if _, err := file.WriteString(str + "\n"); err != nil {
+				return err
+			}
+		}
+	case []TestResult:
+		for _, result := range v {
+
+			str := "PASS " + result.Command
+			if !result.Passed {
+				str = "FAIL " + result.Command + ": " + strings.Join(result.Failures, "; ")
+			}
+
 			if _, err := file.WriteString(str + "\n"); err != nil {
 				return err
 			}
@@ -23,15 +23,31 @@ const (
 	fullDepsArg        = "fullDeps"
 	fullStaticAnalysis = "fullStaticAnalysis"
 	typeAnalysis       = "typeAnalysis"
+	straceArg          = "strace"
+	imageArg           = "image"
+	recordArg          = "record"
+	recordTargetArg    = "recordTarget"
+	recordTypeArg      = "recordType"
+	recordAssertArg    = "recordAssert"
+	rootfsArg          = "rootfs"
+	workspaceArg       = "workspace"
 )
 
 // parseLocalArguments parses arguments of the application.
 func parseLocalArguments(p *argparse.Parser, args *u.Arguments) error {
 
 	args.InitArgParse(p, args, u.STRING, "p", programArg,
-		&argparse.Options{Required: true, Help: "Program name"})
+		&argparse.Options{Required: false, Default: "", Help: "Program name (or " +
+			"program within the image)"})
+	args.InitArgParse(p, args, u.STRING, "i", imageArg,
+		&argparse.Options{Required: false, Default: "", Help: "Path of a container " +
+			"image ('docker save' tarball or OCI image layout) to analyse offline"})
+	args.InitArgParse(p, args, u.STRING, "u", workspaceArg,
+		&argparse.Options{Required: false, Default: "", Help: "Workspace folder " +
+			"containing Unikraft and its external libs (default: ~/workspace)"})
 	args.InitArgParse(p, args, u.STRING, "t", testFileArg,
-		&argparse.Options{Required: false, Help: "Path of the test file"})
+		&argparse.Options{Required: false, Help: "Path of the test file (generated " +
+			"in record mode)"})
 	args.InitArgParse(p, args, u.STRING, "c", configFileArg,
 		&argparse.Options{Required: false, Help: "Path of the config file"})
 	args.InitArgParse(p, args, u.STRING, "o", optionsArg,
@@ -51,10 +67,32 @@ func parseLocalArguments(p *argparse.Parser, args *u.Arguments) error {
 	args.InitArgParse(p, args, u.BOOL, "", fullStaticAnalysis,
 		&argparse.Options{Required: false, Default: false,
 			Help: "Full static analysis (analyse shared libraries too)"})
+	args.InitArgParse(p, args, u.BOOL, "", straceArg,
+		&argparse.Options{Required: false, Default: false,
+			Help: "Use strace instead of the native tracer to gather system calls"})
+	args.InitArgParse(p, args, u.STRING, "", rootfsArg,
+		&argparse.Options{Required: false, Default: "", Help: "Generate the root " +
+			"filesystem from the files opened during the dynamic analysis: cpio " +
+			"(initrd) or 9pfs (folder)"})
+	args.InitArgParse(p, args, u.STRING, "", recordArg,
+		&argparse.Options{Required: false, Default: "", Help: "Record mode: listen " +
+			"address (e.g., 127.0.0.1:8081) of a proxy recording a client session to " +
+			"generate the test file (during waitTime sec or until Ctrl-C)"})
+	args.InitArgParse(p, args, u.STRING, "", recordTargetArg,
+		&argparse.Options{Required: false, Default: "", Help: "Address (host:port) " +
+			"of the running application (record mode)"})
+	args.InitArgParse(p, args, u.STRING, "", recordTypeArg,
+		&argparse.Options{Required: false, Default: "", Help: "Type of the " +
+			"generated test: telnet or http (default: detected from the session)"})
+	args.InitArgParse(p, args, u.BOOL, "", recordAssertArg,
+		&argparse.Options{Required: false, Default: false,
+			Help: "Use the recorded responses as expected outputs (telnet tests)"})
 	args.InitArgParse(p, args, u.INT, "", typeAnalysis,
 		&argparse.Options{Required: false, Default: 0,
 			Help: "Kind of analysis (0: all; 1: static; 2: dynamic; 3: interdependence; 4: " +
-				"sources; 5: stripped-down app and json for buildtool)"})
+				"sources; 5: stripped-down app and json for buildtool; 6: system call support " +
+				"in Unikraft)"})
+	args.InitBatchArgParse(p)
 
 	return u.ParserWrapper(p, os.Args)
 }
@@ -0,0 +1,282 @@
+// Copyright 2019 The UNICORE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// Author: Gaulthier Gain <gaulthier.gain@uliege.be>
+
+package dependtool
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	u "tools/srcs/common"
+)
+
+const (
+	// Phase before the first test command
+	startupPhase = "startup"
+	// Phase after the last test command
+	shutdownPhase = "shutdown"
+)
+
+// testPhase represents a test command and the time at which it starts.
+type testPhase struct {
+	command string
+	start   time.Time
+}
+
+// testPhases records the test commands executed by the tester in order to
+// attribute system calls, shared libraries and symbols to them.
+type testPhases struct {
+	mutex  sync.Mutex
+	phases []testPhase
+}
+
+func newTestPhases() *testPhases {
+	return &testPhases{
+		phases: []testPhase{{command: startupPhase, start: time.Now()}},
+	}
+}
+
+// begin marks the beginning of a test command.
+func (t *testPhases) begin(command string) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	t.phases = append(t.phases, testPhase{command: command, start: time.Now()})
+	t.mutex.Unlock()
+}
+
+// current returns the test command which is currently executed.
+func (t *testPhases) current() string {
+	if t == nil {
+		return startupPhase
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.phases[len(t.phases)-1].command
+}
+
+// at returns the test command which was executed at the given time of day
+// (traces only contain the time of day).
+func (t *testPhases) at(hour, min, sec, nsec int) string {
+	if t == nil {
+		return startupPhase
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	command := startupPhase
+	for _, phase := range t.phases {
+		y, m, d := phase.start.Date()
+		ts := time.Date(y, m, d, hour, min, sec, nsec, phase.start.Location())
+		if ts.Before(phase.start) {
+			break
+		}
+		command = phase.command
+	}
+	return command
+}
+
+// initCommandsData creates the data of each test command in the order of
+// execution.
+func initCommandsData(data *u.DynamicData, testStruct *Testing) {
+	commandData(data, startupPhase)
+	if testStruct != nil && checkTypeTest(testStruct) != stdinTest {
+		for _, cmd := range testCommands(testStruct) {
+			if len(cmd) > 0 {
+				commandData(data, cmd)
+			}
+		}
+	}
+	commandData(data, shutdownPhase)
+}
+
+// commandData returns the data of a test command (it is created if it does
+// not exist yet).
+//
+// It returns a pointer to a CommandData structure.
+func commandData(data *u.DynamicData, command string) *u.CommandData {
+	for i := range data.Commands {
+		if data.Commands[i].Command == command {
+			return &data.Commands[i]
+		}
+	}
+
+	data.Commands = append(data.Commands, u.CommandData{
+		Command:     command,
+		SharedLibs:  make(map[string]string),
+		SystemCalls: make(map[string]int),
+		Symbols:     make(map[string]string),
+	})
+	return &data.Commands[len(data.Commands)-1]
+}
+
+// attributeSystemCall attributes a system call to a test command. Shared
+// libraries and files are detected from the files which are opened.
+//
+// It returns the number of files opened with a relative path which is not
+// resolved (see SystemCallTrace.Path).
+func attributeSystemCall(data *u.DynamicData, command string,
+	trace u.SystemCallTrace) int {
+
+	cmdData := commandData(data, command)
+	cmdData.SystemCalls[trace.Name] = trace.Number
+
+	if trace.Name == "clone" && cloneCreatesProcess(trace) || staticPattern(trace.Name) {
+		recordExecutionPattern(data, trace.Name, command)
+	} else if CloneCreatesThread(trace) && !u.Contains(data.Threads, command) {
+		data.Threads = append(data.Threads, command)
+	}
+
+	if trace.Return < 0 || !strings.HasPrefix(trace.Name, "open") {
+		return 0
+	}
+	unresolved := 0
+	for _, arg := range trace.Args {
+		if !strings.HasPrefix(arg, "\"") || strings.HasSuffix(arg, truncatedSuffix) {
+			// Not a path or truncated path
+			continue
+		}
+		path, err := strconv.Unquote(arg)
+		if err != nil {
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			if len(trace.Path) == 0 {
+				unresolved++
+				continue
+			}
+			path = trace.Path
+		}
+		if sharedLibRe.MatchString(filepath.Base(path)) {
+			cmdData.SharedLibs[filepath.Base(path)] = path
+		}
+		recordFile(data, path, command)
+	}
+	return unresolved
+}
+
+// recordFile records a file opened by the application and the first test
+// command which opened it.
+func recordFile(data *u.DynamicData, path, command string) {
+	if data.Files == nil {
+		data.Files = make(map[string]string)
+	}
+	if _, ok := data.Files[path]; !ok {
+		data.Files[path] = command
+	}
+}
+
+// Mark following the string arguments which are truncated (strace and
+// native tracer), e.g. "/usr/lib/x86_64"...
+const truncatedSuffix = "..."
+
+var (
+	// Lines of strace/ltrace output with timestamps (-tt)
+	traceLineRe = regexp.MustCompile(`^(?:\[pid\s+\d+\]\s+|\d+\s+)?` +
+		`(\d{2}):(\d{2}):(\d{2})\.(\d{6})\s+([a-zA-Z_0-9@/-]+?)\((.*)`)
+	// Result of a system call
+	traceReturnRe = regexp.MustCompile(`=\s+(-?\d+)`)
+	// Name of a shared library (e.g., libc.so.6)
+	sharedLibRe = regexp.MustCompile(`\.so(\.\d+)*$`)
+	// Flags of clone (e.g., flags=CLONE_CHILD_SETTID|SIGCHLD)
+	cloneFlagsRe = regexp.MustCompile(`flags=([A-Z0-9_|]+)`)
+)
+
+// attributeTrace attributes the system calls (strace) or the symbols (ltrace)
+// of a trace output to the test commands by using the timestamps of the
+// trace.
+func attributeTrace(output, command string, phases *testPhases,
+	data *u.DynamicData) {
+
+	systemCalls := initSystemCalls()
+	unresolved := 0
+	for _, line := range strings.Split(output, "\n") {
+
+		match := traceLineRe.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		values := make([]int, 4)
+		for i := range values {
+			values[i], _ = strconv.Atoi(match[i+1])
+		}
+		phase := phases.at(values[0], values[1], values[2], values[3]*1000)
+		name := match[5]
+
+		if command == libtrace {
+			commandData(data, phase).Symbols[name] = ""
+			if staticPattern(name) {
+				recordExecutionPattern(data, name, phase)
+			}
+			continue
+		}
+
+		number, ok := systemCalls[name]
+		if !ok {
+			number = -1
+		}
+
+		trace := u.SystemCallTrace{Number: number, Name: name, Return: -1}
+		if ret := traceReturnRe.FindAllStringSubmatch(match[6], -1); ret != nil {
+			trace.Return, _ = strconv.ParseInt(ret[len(ret)-1][1], 10, 64)
+		}
+		if start := strings.Index(match[6], "\""); start >= 0 {
+			if end := strings.Index(match[6][start+1:], "\""); end >= 0 {
+				// Keep the truncation mark if any
+				end += start + 2
+				if strings.HasPrefix(match[6][end:], truncatedSuffix) {
+					end += len(truncatedSuffix)
+				}
+				trace.Args = []string{match[6][start:end]}
+			}
+		}
+		if flags := cloneFlagsRe.FindStringSubmatch(match[6]); name == "clone" && flags != nil {
+			trace.Args = []string{"flags=" + flags[1]}
+		}
+		unresolved += attributeSystemCall(data, phase, trace)
+	}
+
+	if unresolved > 0 {
+		u.PrintWarning(fmt.Sprintf("%d file(s) opened with a relative path are not "+
+			"recorded (only resolved by the native tracer)", unresolved))
+	}
+}
+
+// computeFirstSeen computes the first test command which triggered each
+// shared library, system call and symbol.
+func computeFirstSeen(data *u.DynamicData) {
+
+	data.FirstSeen = u.FirstSeen{
+		SharedLibs:  make(map[string]string),
+		SystemCalls: make(map[string]string),
+		Symbols:     make(map[string]string),
+	}
+
+	// Commands are ordered by execution (startup first)
+	for _, cmdData := range data.Commands {
+		for lib := range cmdData.SharedLibs {
+			if _, ok := data.FirstSeen.SharedLibs[lib]; !ok {
+				data.FirstSeen.SharedLibs[lib] = cmdData.Command
+			}
+		}
+		for name := range cmdData.SystemCalls {
+			if _, ok := data.FirstSeen.SystemCalls[name]; !ok {
+				data.FirstSeen.SystemCalls[name] = cmdData.Command
+			}
+		}
+		for name := range cmdData.Symbols {
+			if _, ok := data.FirstSeen.Symbols[name]; !ok {
+				data.FirstSeen.Symbols[name] = cmdData.Command
+			}
+		}
+	}
+}
@@ -23,6 +23,10 @@ type DynamicArgs struct {
 	fullDeps, saveOutput bool
 	testFile             string
 	options              []string
+	strace               bool
+
+	// Test commands executed by the tester
+	phases *testPhases
 }
 
 const (
@@ -54,7 +58,11 @@ func ReadTestFileJson(filename string) (*Testing, error) {
 		return nil, err
 	}
 
-	if testingStruct.ListCommands == nil || len(testingStruct.ListCommands) == 0 {
+	if checkTypeTest(testingStruct) == httpTest {
+		if len(testingStruct.ListRequests) == 0 || len(testingStruct.BaseUrl) == 0 {
+			return nil, errors.New("ListRequests and BaseUrl cannot be empty")
+		}
+	} else if testingStruct.ListCommands == nil || len(testingStruct.ListCommands) == 0 {
 		return nil, errors.New("ListCommands cannot be empty")
 	}
 
@@ -78,8 +86,26 @@ func gatherDataAux(command, programPath, programName, option string,
 			u.PrintWarning("Cannot find test file: " + err.Error())
 		}
 	}
+
+	// Attribute the data to the test commands
+	dArgs.phases = newTestPhases()
+	initCommandsData(data, testingStruct)
+
+	if command == systrace && nativeTracer && !dArgs.strace {
+		_, _, err := runCommandTracer(programPath, programName, option,
+			testingStruct, dArgs, data)
+		if err == nil {
+			return false
+		} else if errors.Is(err, syscall.EPERM) {
+			// ptrace is not permitted
+			return true
+		}
+		u.PrintWarning("Native tracer failed (" + err.Error() + "), use " + systrace)
+	}
+
 	_, errStr := runCommandTester(programPath, programName, command, option,
 		testingStruct, dArgs, data)
+	attributeTrace(errStr, command, dArgs.phases, data)
 
 	ret := false
 	if command == systrace {
@@ -169,7 +195,7 @@ func gatherDynamicSharedLibs(programName string, pid int, data *u.DynamicData,
 func getDArgs(args *u.Arguments, options []string) DynamicArgs {
 	return DynamicArgs{*args.IntArg[waitTimeArg],
 		*args.BoolArg[fullDepsArg], *args.BoolArg[saveOutputArg],
-		*args.StringArg[testFileArg], options}
+		*args.StringArg[testFileArg], options, *args.BoolArg[straceArg], nil}
 }
 
 // -------------------------------------RUN-------------------------------------
@@ -208,6 +234,7 @@ func dynamicAnalyser(args *u.Arguments, data *u.Data, programPath string) {
 	dynamicData.SharedLibs = make(map[string][]string)
 	dynamicData.SystemCalls = make(map[string]int)
 	dynamicData.Symbols = make(map[string]string)
+	dynamicData.Files = make(map[string]string)
 
 	// Run strace
 	u.PrintHeader2("(*) Gathering system calls from ELF file")
@@ -222,4 +249,6 @@ func dynamicAnalyser(args *u.Arguments, data *u.Data, programPath string) {
 	// Run ltrace
 	u.PrintHeader2("(*) Gathering symbols from ELF file")
 	gatherData(libtrace, programPath, programName, dynamicData, dArgs)
+
+	computeFirstSeen(dynamicData)
 }
@@ -0,0 +1,416 @@
+// Copyright 2019 The UNICORE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// Author: Gaulthier Gain <gaulthier.gain@uliege.be>
+
+package dependtool
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"github.com/knightsc/gapstone"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	u "tools/srcs/common"
+)
+
+// Functions and system calls which are not supported by a single address
+// space. clone is a pattern only if CLONE_VM is not set (see
+// cloneCreatesProcess), so it is only detected by the dynamic analysis.
+var executionPatterns = map[string]string{
+	"fork":         u.PATTERN_PROCESS,
+	"vfork":        u.PATTERN_PROCESS,
+	"clone":        u.PATTERN_PROCESS,
+	"daemon":       u.PATTERN_PROCESS,
+	"forkpty":      u.PATTERN_PROCESS,
+	"execve":       u.PATTERN_EXECUTION,
+	"execveat":     u.PATTERN_EXECUTION,
+	"fexecve":      u.PATTERN_EXECUTION,
+	"execv":        u.PATTERN_EXECUTION,
+	"execvp":       u.PATTERN_EXECUTION,
+	"execvpe":      u.PATTERN_EXECUTION,
+	"execl":        u.PATTERN_EXECUTION,
+	"execlp":       u.PATTERN_EXECUTION,
+	"execle":       u.PATTERN_EXECUTION,
+	"system":       u.PATTERN_EXECUTION,
+	"popen":        u.PATTERN_EXECUTION,
+	"posix_spawn":  u.PATTERN_EXECUTION,
+	"posix_spawnp": u.PATTERN_EXECUTION,
+	"shmget":       u.PATTERN_SYSV_IPC,
+	"shmat":        u.PATTERN_SYSV_IPC,
+	"shmdt":        u.PATTERN_SYSV_IPC,
+	"shmctl":       u.PATTERN_SYSV_IPC,
+	"semget":       u.PATTERN_SYSV_IPC,
+	"semop":        u.PATTERN_SYSV_IPC,
+	"semtimedop":   u.PATTERN_SYSV_IPC,
+	"semctl":       u.PATTERN_SYSV_IPC,
+	"msgget":       u.PATTERN_SYSV_IPC,
+	"msgsnd":       u.PATTERN_SYSV_IPC,
+	"msgrcv":       u.PATTERN_SYSV_IPC,
+	"msgctl":       u.PATTERN_SYSV_IPC,
+	"ftok":         u.PATTERN_SYSV_IPC,
+}
+
+// Order of the categories of execution patterns in the reports
+var patternCategories = []string{u.PATTERN_PROCESS, u.PATTERN_EXECUTION, u.PATTERN_SYSV_IPC}
+
+// Flags of clone which share the address space and the thread group
+const (
+	cloneVM     = 0x100
+	cloneThread = 0x10000
+)
+
+// Size of a PLT entry (x86_64)
+const pltEntrySize = 16
+
+// staticPattern checks if a function is an execution pattern whose use can
+// be detected without running the application.
+//
+// It returns true if it is a pattern, false otherwise.
+func staticPattern(name string) bool {
+	_, ok := executionPatterns[name]
+	return ok && name != "clone"
+}
+
+// cloneFlag checks if a flag is set in the flags of a clone system call. The
+// flags are either a hexadecimal value (tracer) or symbolic names (strace).
+//
+// It returns true if the flag is set and false if the flags are unknown.
+func cloneFlag(trace u.SystemCallTrace, name string, value uint64) (set, known bool) {
+
+	if trace.Name != "clone" || len(trace.Args) == 0 {
+		return false, false
+	}
+
+	flags := trace.Args[0]
+	if strings.HasPrefix(flags, "flags=") {
+		return u.Contains(strings.Split(strings.TrimPrefix(flags, "flags="), "|"),
+			name), true
+	}
+	number, err := strconv.ParseUint(strings.TrimPrefix(flags, "0x"), 16, 64)
+	return err == nil && number&value != 0, err == nil
+}
+
+// cloneCreatesProcess checks if a clone system call creates a process (i.e.,
+// CLONE_VM is not set).
+//
+// It returns true if it creates a process, false if it creates a thread or
+// if the flags are unknown.
+func cloneCreatesProcess(trace u.SystemCallTrace) bool {
+	set, known := cloneFlag(trace, "CLONE_VM", cloneVM)
+	return known && !set
+}
+
+// CloneCreatesThread checks if a clone system call creates a thread (i.e.,
+// CLONE_THREAD is set).
+//
+// It returns true if it creates a thread, false otherwise.
+func CloneCreatesThread(trace u.SystemCallTrace) bool {
+	set, _ := cloneFlag(trace, "CLONE_THREAD", cloneThread)
+	return set
+}
+
+// addPatternEntry adds an entry (call site or test command) to an execution
+// pattern (only once).
+func addPatternEntry(patterns map[string][]string, name, entry string) {
+	if _, ok := patterns[name]; !ok {
+		patterns[name] = make([]string, 0)
+	}
+	if len(entry) > 0 && !u.Contains(patterns[name], entry) {
+		patterns[name] = append(patterns[name], entry)
+	}
+}
+
+// recordExecutionPattern records an execution pattern triggered by a test
+// command at runtime.
+func recordExecutionPattern(data *u.DynamicData, name, command string) {
+	if data.ExecutionPatterns == nil {
+		data.ExecutionPatterns = make(map[string][]string)
+	}
+	addPatternEntry(data.ExecutionPatterns, name, command)
+}
+
+// relocations reads the relocations (x86_64) of a section and associates
+// the address of each relocated slot to the name of its symbol.
+func relocations(elfFile *elf.File, name string, dynSymbols []elf.Symbol,
+	slots map[uint64]string) []string {
+
+	section := elfFile.Section(name)
+	if section == nil {
+		return nil
+	}
+	content, err := section.Data()
+	if err != nil {
+		return nil
+	}
+
+	names := make([]string, 0)
+	reader := bytes.NewReader(content)
+	var rela elf.Rela64
+	for binary.Read(reader, elfFile.ByteOrder, &rela) == nil {
+		index := int(elf.R_SYM64(rela.Info))
+		symbol := ""
+		if index > 0 && index <= len(dynSymbols) {
+			symbol = dynSymbols[index-1].Name
+		}
+		slots[rela.Off] = symbol
+		names = append(names, symbol)
+	}
+	return names
+}
+
+// patternCallSites disassembles the executable sections of a program (x86_64)
+// to find the calls to the functions which are execution patterns. Calls to
+// the PLT stubs, indirect calls through the GOT (-fno-plt) and direct calls
+// to the functions linked statically are resolved.
+//
+// It returns a map which associates each pattern to the addresses of its calls
+// and an error if any, otherwise it returns nil.
+func patternCallSites(elfFile *elf.File, binaryName string) (map[string][]string, error) {
+
+	if elfFile.Machine != elf.EM_X86_64 || elfFile.Class != elf.ELFCLASS64 {
+		return nil, fmt.Errorf("call sites of %s binaries are not supported",
+			elfFile.Machine)
+	}
+
+	// Targets of the calls: functions, PLT stubs and GOT slots (the dynamic
+	// symbols are missing for static binaries)
+	functions := functionSymbols(elfFile)
+	targets, slots := make(map[uint64]string), make(map[uint64]string)
+	for _, f := range functions {
+		if staticPattern(f.name) {
+			targets[f.addr] = f.name
+		}
+	}
+	if dynSymbols, err := elfFile.DynamicSymbols(); err == nil {
+		_ = relocations(elfFile, ".rela.dyn", dynSymbols, slots)
+		for i, name := range relocations(elfFile, ".rela.plt", dynSymbols, slots) {
+			if section := elfFile.Section(".plt.sec"); section != nil {
+				targets[section.Addr+uint64(i*pltEntrySize)] = name
+			} else if section := elfFile.Section(".plt"); section != nil {
+				targets[section.Addr+uint64((i+1)*pltEntrySize)] = name
+			}
+		}
+	}
+
+	engine, err := gapstone.New(gapstone.CS_ARCH_X86, gapstone.CS_MODE_64)
+	if err != nil {
+		return nil, err
+	}
+	defer engine.Close()
+
+	sites := make(map[string][]string)
+	for _, section := range elfFile.Sections {
+
+		if section.Flags&elf.SHF_EXECINSTR == 0 || section.Type == elf.SHT_NOBITS ||
+			strings.HasPrefix(section.Name, ".plt") {
+			continue
+		}
+		content, err := section.Data()
+		if err != nil {
+			u.PrintWarning(err)
+			continue
+		}
+
+		for _, insn := range disassemble(&engine, content, section.Addr) {
+
+			if insn.Mnemonic != "call" && insn.Mnemonic != "jmp" {
+				continue
+			}
+
+			var name string
+			if target, ok := immValue(insn.OpStr); ok {
+				name = targets[uint64(target)]
+			} else if strings.HasPrefix(insn.OpStr, "qword ptr [rip") {
+				// call qword ptr [rip + 0x2fe2]
+				disp := strings.TrimSuffix(strings.TrimPrefix(insn.OpStr,
+					"qword ptr [rip"), "]")
+				disp = strings.ReplaceAll(disp, " ", "")
+				if offset, err := strconv.ParseInt(disp, 0, 64); err == nil {
+					name = slots[uint64(int64(insn.Address)+int64(insn.Size)+offset)]
+				}
+			}
+			if !staticPattern(name) {
+				continue
+			}
+
+			site := fmt.Sprintf("%s 0x%x", binaryName, insn.Address)
+			if function := findFunction(functions, uint64(insn.Address)); len(function) > 0 {
+				site += " (" + function + ")"
+			}
+			sites[name] = append(sites[name], site)
+		}
+	}
+	return sites, nil
+}
+
+// gatherExecutionPatterns detects the execution patterns of a program: the
+// imported functions (and the addresses of their calls), the functions
+// linked statically and the system call instructions of the program itself.
+// The system call sites must have been gathered before.
+func gatherExecutionPatterns(elfFile *elf.File, path string, data *u.StaticData) {
+
+	data.ExecutionPatterns = make(map[string][]string)
+	binaryName := filepath.Base(path)
+
+	if imported, err := elfFile.ImportedSymbols(); err == nil {
+		for _, s := range imported {
+			if staticPattern(s.Name) {
+				addPatternEntry(data.ExecutionPatterns, s.Name, "")
+			}
+		}
+	}
+
+	if symbols, err := elfFile.Symbols(); err == nil {
+		for _, s := range symbols {
+			if elf.ST_TYPE(s.Info) == elf.STT_FUNC && s.Value > 0 && staticPattern(s.Name) {
+				addPatternEntry(data.ExecutionPatterns, s.Name, "")
+			}
+		}
+	}
+
+	for _, site := range data.SystemCallSites {
+		if site.Binary == binaryName && staticPattern(site.Name) {
+			entry := fmt.Sprintf("%s 0x%x", site.Binary, site.Address)
+			if len(site.Function) > 0 {
+				entry += " (" + site.Function + ")"
+			}
+			addPatternEntry(data.ExecutionPatterns, site.Name, entry)
+		}
+	}
+
+	if len(data.ExecutionPatterns) == 0 {
+		return
+	}
+	sites, err := patternCallSites(elfFile, binaryName)
+	if err != nil {
+		u.PrintWarning(err)
+		return
+	}
+	for name, entries := range sites {
+		for _, entry := range entries {
+			addPatternEntry(data.ExecutionPatterns, name, entry)
+		}
+	}
+}
+
+// executionPatternsOf merges the execution patterns detected by the static,
+// dynamic and sources analyses.
+//
+// It returns a slice of ExecutionPattern sorted by category (see
+// patternCategories) and name.
+func executionPatternsOf(data *u.Data) []u.ExecutionPattern {
+
+	merged := make(map[string]*u.ExecutionPattern)
+	for _, analysis := range []struct {
+		name     string
+		patterns map[string][]string
+	}{
+		{"static", data.StaticData.ExecutionPatterns},
+		{"dynamic", data.DynamicData.ExecutionPatterns},
+		{"sources", data.SourcesData.ExecutionPatterns},
+	} {
+		for name, entries := range analysis.patterns {
+			category, ok := executionPatterns[name]
+			if !ok {
+				continue
+			}
+			p, ok := merged[name]
+			if !ok {
+				p = &u.ExecutionPattern{Name: name, Category: category}
+				merged[name] = p
+			}
+			p.Analyses = append(p.Analyses, analysis.name)
+			if analysis.name == "dynamic" {
+				p.Commands = append(p.Commands, entries...)
+			} else {
+				p.Sites = append(p.Sites, entries...)
+			}
+		}
+	}
+
+	patterns := make([]u.ExecutionPattern, 0, len(merged))
+	for _, p := range merged {
+		patterns = append(patterns, *p)
+	}
+	order := make(map[string]int)
+	for i, category := range patternCategories {
+		order[category] = i
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if patterns[i].Category != patterns[j].Category {
+			return order[patterns[i].Category] < order[patterns[j].Category]
+		}
+		return patterns[i].Name < patterns[j].Name
+	})
+	return patterns
+}
+
+// executionPatternsReport formats the execution patterns as a text report.
+//
+// It returns the text report.
+func executionPatternsReport(patterns []u.ExecutionPattern) string {
+
+	var sb strings.Builder
+	for _, p := range patterns {
+		sb.WriteString(fmt.Sprintf("%s (%s) found by %s\n", p.Name, p.Category,
+			strings.Join(p.Analyses, ",")))
+		for _, site := range p.Sites {
+			sb.WriteString("\tsite: " + site + "\n")
+		}
+		for _, command := range p.Commands {
+			sb.WriteString("\tcommand: " + command + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// runExecutionPatternsAnalyser merges the execution patterns detected by the
+// analyses and warns about the patterns which are not supported by a single
+// address space.
+func runExecutionPatternsAnalyser(args *u.Arguments, programName, outFolder string,
+	data *u.Data) {
+
+	data.ExecutionPatterns = executionPatternsOf(data)
+	if len(data.ExecutionPatterns) == 0 {
+		u.PrintOk("No multi-process or unsupported execution pattern found")
+		return
+	}
+
+	categories := make(map[string]bool)
+	for _, p := range data.ExecutionPatterns {
+		categories[p.Category] = true
+		where := p.Sites
+		if len(where) > 3 {
+			where = append(where[:3:3], fmt.Sprintf("%d more", len(p.Sites)-3))
+		}
+		msg := p.Name + " (" + p.Category + ") found by " + strings.Join(p.Analyses, ", ")
+		if len(where) > 0 {
+			msg += " at " + strings.Join(where, ", ")
+		}
+		if len(p.Commands) > 0 {
+			msg += " - triggered by " + strconv.Quote(p.Commands[0])
+		}
+		u.PrintWarning(msg)
+	}
+	if categories[u.PATTERN_PROCESS] && !categories[u.PATTERN_EXECUTION] {
+		u.PrintInfo("Processes are created without executing programs (e.g., " +
+			"master/worker model): look for a single process mode")
+	}
+
+	// Save the execution patterns into a text file if display mode is set
+	if *args.BoolArg[saveOutputArg] {
+		fn := outFolder + programName + "_execution_patterns.txt"
+		if err := u.WriteToFile(fn, []byte(executionPatternsReport(
+			data.ExecutionPatterns))); err != nil {
+			u.PrintWarning(err)
+		} else {
+			u.PrintOk("Execution patterns saved into " + fn)
+		}
+	}
+}
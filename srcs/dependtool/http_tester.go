@@ -0,0 +1,168 @@
+// Copyright 2019 The UNICORE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// Author: Gaulthier Gain <gaulthier.gain@uliege.be>
+
+package dependtool
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	u "tools/srcs/common"
+)
+
+// HttpRequest represents a request of an http test and the assertions on its
+// response.
+type HttpRequest struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+
+	// Assertions (the status code must be one of the expected ones)
+	ExpectedStatus []int    `json:"expectedStatus,omitempty"`
+	BodyContains   []string `json:"bodyContains,omitempty"`
+	BodyMatches    string   `json:"bodyMatches,omitempty"`
+}
+
+// String returns the name of the request used as test command (e.g., "GET
+// /index.html").
+func (r HttpRequest) String() string {
+	method := r.Method
+	if len(method) == 0 {
+		method = http.MethodGet
+	}
+	return strings.ToUpper(method) + " " + r.Path
+}
+
+// newHttpClient creates an http client according to the TLS settings of the
+// test file (CA certificate or insecure mode).
+//
+// It returns a pointer to an http.Client and an error if any, otherwise it
+// returns nil.
+func newHttpClient(testStruct *Testing) (*http.Client, error) {
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: testStruct.Insecure}
+	if len(testStruct.CACert) > 0 {
+		pem, err := ioutil.ReadFile(testStruct.CACert)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificate found in " + testStruct.CACert)
+		}
+	}
+
+	return &http.Client{
+		Timeout:   time.Duration(timeOutMs) * time.Millisecond,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		// Redirections are checked by the assertions
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}, nil
+}
+
+// checkHttpResponse checks the assertions of a request on its response.
+//
+// It returns a slice of string which represents the failed assertions.
+func checkHttpResponse(request HttpRequest, status int, body string) []string {
+
+	failures := make([]string, 0)
+	if len(request.ExpectedStatus) > 0 {
+		found := false
+		for _, expected := range request.ExpectedStatus {
+			found = found || expected == status
+		}
+		if !found {
+			failures = append(failures, fmt.Sprintf("status %d not in %v", status,
+				request.ExpectedStatus))
+		}
+	}
+
+	for _, expected := range request.BodyContains {
+		if !strings.Contains(body, expected) {
+			failures = append(failures, "body does not contain "+strconv.Quote(expected))
+		}
+	}
+
+	if len(request.BodyMatches) > 0 {
+		if re, err := regexp.Compile(request.BodyMatches); err != nil {
+			failures = append(failures, "invalid regexp "+strconv.Quote(request.BodyMatches))
+		} else if !re.MatchString(body) {
+			failures = append(failures, "body does not match "+
+				strconv.Quote(request.BodyMatches))
+		}
+	}
+	return failures
+}
+
+// launchHttpTest runs the http requests written in the 'test.json' file and
+// checks their responses. The beginning of each request is recorded into
+// phases (if not nil).
+//
+// It returns the status codes and the bodies of the responses.
+func launchHttpTest(testStruct *Testing, phases *testPhases, report *testReport) string {
+
+	client, err := newHttpClient(testStruct)
+	if err != nil {
+		u.PrintWarning("Impossible to create the http client: " + err.Error())
+		return ""
+	}
+
+	var sb strings.Builder
+	baseUrl := strings.TrimSuffix(testStruct.BaseUrl, "/")
+	for _, request := range testStruct.ListRequests {
+
+		// Perform a sleep between requests if specified
+		if testStruct.TimeCommand > 0 {
+			timeMs := rand.Int31n(testStruct.TimeCommand)
+			time.Sleep(time.Duration(timeMs) * time.Millisecond)
+		}
+		phases.begin(request.String())
+
+		method := strings.ToUpper(request.Method)
+		if len(method) == 0 {
+			method = http.MethodGet
+		}
+		req, err := http.NewRequest(method, baseUrl+request.Path,
+			strings.NewReader(request.Body))
+		if err != nil {
+			report.add(request.String(), []string{err.Error()})
+			continue
+		}
+		for key, value := range request.Headers {
+			if strings.EqualFold(key, "Host") {
+				req.Host = value
+			} else {
+				req.Header.Set(key, value)
+			}
+		}
+
+		status, body := 0, ""
+		failures := make([]string, 0)
+		if resp, err := client.Do(req); err != nil {
+			failures = append(failures, err.Error())
+		} else {
+			b, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			status, body = resp.StatusCode, string(b)
+			failures = checkHttpResponse(request, status, body)
+		}
+
+		sb.WriteString(fmt.Sprintf("%s -> %d\n%s\n", request.String(), status, body))
+		report.add(request.String(), failures)
+	}
+	return sb.String()
+}
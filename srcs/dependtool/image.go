@@ -0,0 +1,683 @@
+// Copyright 2019 The UNICORE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// Author: Gaulthier Gain <gaulthier.gain@uliege.be>
+
+package dependtool
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"debug/elf"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	u "tools/srcs/common"
+)
+
+const (
+	dockerManifest = "manifest.json"
+	ociIndex       = "index.json"
+	ociBlobs       = "blobs"
+
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+
+	defaultPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+	maxSymlinks = 40
+)
+
+// containerImage represents a container image unpacked into a rootfs.
+type containerImage struct {
+	rootfs string
+	config imageConfig
+
+	// Paths (within the rootfs) of the shared libraries
+	libPaths map[string]string
+}
+
+type imageConfig struct {
+	Entrypoint []string `json:"Entrypoint"`
+	Cmd        []string `json:"Cmd"`
+	Env        []string `json:"Env"`
+	WorkingDir string   `json:"WorkingDir"`
+}
+
+// Entry of the manifest of a 'docker save' tarball
+type dockerManifestEntry struct {
+	Config   string   `json:"Config"`
+	RepoTags []string `json:"RepoTags"`
+	Layers   []string `json:"Layers"`
+}
+
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Platform  *struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+	} `json:"platform,omitempty"`
+}
+
+// OCI image index and manifest
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Manifests []ociDescriptor `json:"manifests"`
+	Config    ociDescriptor   `json:"config"`
+	Layers    []ociDescriptor `json:"layers"`
+}
+
+// -----------------------------------Unpack------------------------------------
+
+// openImage unpacks a 'docker save' tarball or an OCI image layout folder into
+// a rootfs located in the scratch folder.
+//
+// It returns a pointer to a containerImage structure and an error if any,
+// otherwise it returns nil.
+func openImage(imagePath, scratch string) (*containerImage, error) {
+
+	info, err := os.Stat(imagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	layout := imagePath
+	if !info.IsDir() {
+		// Extract the tarball to access its manifest and layers
+		layout = filepath.Join(scratch, "image")
+		if err := extractTar(imagePath, layout, false); err != nil {
+			return nil, err
+		}
+	}
+
+	configPath, layers, err := readImageLayout(layout)
+	if err != nil {
+		return nil, err
+	}
+
+	image := &containerImage{
+		rootfs:   filepath.Join(scratch, "rootfs"),
+		libPaths: make(map[string]string),
+	}
+	if err := os.MkdirAll(image.rootfs, u.PERM); err != nil {
+		return nil, err
+	}
+
+	for i, layer := range layers {
+		u.PrintInfo(fmt.Sprintf("Unpacking layer %d/%d", i+1, len(layers)))
+		if err := extractTar(layer, image.rootfs, true); err != nil {
+			return nil, err
+		}
+	}
+
+	byteValue, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	config := struct {
+		Config imageConfig `json:"config"`
+	}{}
+	if err := json.Unmarshal(byteValue, &config); err != nil {
+		return nil, err
+	}
+	image.config = config.Config
+
+	return image, nil
+}
+
+// readImageLayout reads the manifest of an image layout (docker or OCI).
+//
+// It returns the path of the image configuration, the paths of the layers
+// (from the lowest one) and an error if any, otherwise it returns nil.
+func readImageLayout(layout string) (string, []string, error) {
+
+	if byteValue, err := ioutil.ReadFile(filepath.Join(layout, dockerManifest)); err == nil {
+		var entries []dockerManifestEntry
+		if err := json.Unmarshal(byteValue, &entries); err != nil {
+			return "", nil, err
+		}
+		if len(entries) == 0 {
+			return "", nil, errors.New("empty image manifest")
+		}
+		if len(entries) > 1 {
+			u.PrintWarning("Several images found, only the first one is analysed")
+		}
+
+		layers := make([]string, len(entries[0].Layers))
+		for i, layer := range entries[0].Layers {
+			layers[i] = filepath.Join(layout, filepath.FromSlash(layer))
+		}
+		return filepath.Join(layout, filepath.FromSlash(entries[0].Config)), layers, nil
+	}
+
+	// OCI image layout
+	manifest := new(ociManifest)
+	if err := readJsonBlob(filepath.Join(layout, ociIndex), manifest); err != nil {
+		return "", nil, errors.New("neither " + dockerManifest + " nor " + ociIndex +
+			" found in " + layout)
+	}
+
+	// Follow the indexes until an image manifest
+	for len(manifest.Manifests) > 0 {
+		descriptor := selectManifest(manifest.Manifests)
+		manifest = new(ociManifest)
+		if err := readJsonBlob(blobPath(layout, descriptor.Digest), manifest); err != nil {
+			return "", nil, err
+		}
+	}
+
+	layers := make([]string, len(manifest.Layers))
+	for i, layer := range manifest.Layers {
+		layers[i] = blobPath(layout, layer.Digest)
+	}
+	return blobPath(layout, manifest.Config.Digest), layers, nil
+}
+
+// selectManifest selects the manifest of the current architecture (or the
+// first one).
+func selectManifest(descriptors []ociDescriptor) ociDescriptor {
+	for _, d := range descriptors {
+		if d.Platform != nil && d.Platform.OS == "linux" &&
+			d.Platform.Architecture == runtime.GOARCH {
+			return d
+		}
+	}
+	return descriptors[0]
+}
+
+func blobPath(layout, digest string) string {
+	return filepath.Join(layout, ociBlobs, strings.Replace(digest, ":", u.SEP, 1))
+}
+
+func readJsonBlob(filename string, v interface{}) error {
+	byteValue, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(byteValue, v)
+}
+
+// extractTar extracts a (gzip compressed or not) tarball into a folder. If
+// layer is true, whiteout files are handled (deleted files of a layer).
+//
+// It returns an error if any, otherwise it returns nil.
+func extractTar(tarPath, dest string, layer bool) error {
+
+	f, err := os.Open(tarPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	var stream io.Reader = reader
+	if magic, err := reader.Peek(4); err == nil {
+		if bytes.HasPrefix(magic, []byte{0x1f, 0x8b}) {
+			gz, err := gzip.NewReader(reader)
+			if err != nil {
+				return err
+			}
+			defer gz.Close()
+			stream = gz
+		} else if bytes.Equal(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}) {
+			return errors.New("zstd compressed layers are not supported: " + tarPath)
+		}
+	}
+
+	// Entries of the layer (opaque whiteouts only hide lower layers)
+	extracted := make(map[string]bool)
+
+	tr := tar.NewReader(stream)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		name := path.Clean("/" + hdr.Name)
+		if name == "/" {
+			continue
+		}
+
+		// Parent folders are resolved within the destination folder
+		dir, err := resolveInRoot(dest, path.Dir(name))
+		if err != nil {
+			return err
+		}
+		base := path.Base(name)
+
+		if layer && base == whiteoutOpaque {
+			// Remove the content of the folder of lower layers
+			entries, _ := ioutil.ReadDir(dir)
+			for _, entry := range entries {
+				if target := filepath.Join(dir, entry.Name()); !extracted[target] {
+					_ = os.RemoveAll(target)
+				}
+			}
+			continue
+		} else if layer && strings.HasPrefix(base, whiteoutPrefix) {
+			_ = os.RemoveAll(filepath.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)))
+			continue
+		}
+
+		if err := os.MkdirAll(dir, u.PERM); err != nil {
+			return err
+		}
+		target := filepath.Join(dir, base)
+		extracted[target] = true
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if fi, err := os.Lstat(target); err == nil && !fi.IsDir() {
+				_ = os.RemoveAll(target)
+			}
+			if err := os.MkdirAll(target, os.FileMode(hdr.Mode)&os.ModePerm|0700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			_ = os.RemoveAll(target)
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC,
+				os.FileMode(hdr.Mode)&os.ModePerm|0600)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(out, tr)
+			out.Close()
+			if err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			_ = os.RemoveAll(target)
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			src, err := resolveInRoot(dest, hdr.Linkname)
+			if err != nil {
+				return err
+			}
+			_ = os.RemoveAll(target)
+			if err := os.Link(src, target); err != nil {
+				u.PrintWarning(err)
+			}
+		default:
+			// Devices and fifos are not needed for the analysis
+		}
+	}
+}
+
+// resolveInRoot resolves a path (and its symbolic links) as if root was the
+// root folder. Symbolic links cannot escape from the root folder.
+//
+// It returns the resolved path on the host and an error if any, otherwise it
+// returns nil.
+func resolveInRoot(root, name string) (string, error) {
+
+	remaining := strings.Split(path.Clean("/"+name), "/")
+	current := "/"
+	links := 0
+	for len(remaining) > 0 {
+		part := remaining[0]
+		remaining = remaining[1:]
+
+		if part == "" || part == "." {
+			continue
+		} else if part == ".." {
+			current = path.Dir(current)
+			continue
+		}
+
+		next := path.Join(current, part)
+		host := filepath.Join(root, filepath.FromSlash(next))
+		fi, err := os.Lstat(host)
+		if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+			current = next
+			continue
+		}
+
+		if links++; links > maxSymlinks {
+			return "", errors.New("too many levels of symbolic links: " + name)
+		}
+		target, err := os.Readlink(host)
+		if err != nil {
+			return "", err
+		}
+		if path.IsAbs(target) {
+			current = "/"
+		}
+		remaining = append(strings.Split(target, "/"), remaining...)
+	}
+
+	return filepath.Join(root, filepath.FromSlash(current)), nil
+}
+
+// ----------------------------------Program------------------------------------
+
+// containerPath returns the path within the image of a path of the rootfs.
+func (image *containerImage) containerPath(hostPath string) string {
+	rel, err := filepath.Rel(image.rootfs, hostPath)
+	if err != nil {
+		return hostPath
+	}
+	return "/" + filepath.ToSlash(rel)
+}
+
+// env returns the value of an environment variable of the image.
+func (image *containerImage) env(key string) string {
+	for _, kv := range image.config.Env {
+		if strings.HasPrefix(kv, key+"=") {
+			return strings.TrimPrefix(kv, key+"=")
+		}
+	}
+	return ""
+}
+
+// lookPath searches an executable within the image (by using its PATH).
+//
+// It returns the path of the executable on the host and an error if any,
+// otherwise it returns nil.
+func (image *containerImage) lookPath(name string) (string, error) {
+
+	candidates := make([]string, 0)
+	if strings.Contains(name, "/") {
+		if !path.IsAbs(name) {
+			name = path.Join("/", image.config.WorkingDir, name)
+		}
+		candidates = append(candidates, name)
+	} else {
+		paths := image.env("PATH")
+		if len(paths) == 0 {
+			paths = defaultPath
+		}
+		for _, dir := range strings.Split(paths, ":") {
+			candidates = append(candidates, path.Join(dir, name))
+		}
+	}
+
+	for _, candidate := range candidates {
+		hostPath, err := resolveInRoot(image.rootfs, candidate)
+		if err != nil {
+			continue
+		}
+		if fi, err := os.Stat(hostPath); err == nil && fi.Mode().IsRegular() {
+			return hostPath, nil
+		}
+	}
+	return "", errors.New(name + " not found in the image")
+}
+
+// isElfFile returns true if the file is an ELF file.
+func isElfFile(filename string) bool {
+	f, err := os.Open(filename)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return false
+	}
+	return bytes.Equal(magic, []byte(elf.ELFMAG))
+}
+
+// programPath resolves the program to analyse within the image: the given
+// program or the entrypoint of the image (or its command if the entrypoint
+// is a script).
+//
+// It returns the path of the program on the host and an error if any,
+// otherwise it returns nil.
+func (image *containerImage) programPath(program string) (string, error) {
+
+	candidates := make([]string, 0)
+	if len(program) > 0 {
+		candidates = append(candidates, program)
+	} else {
+		if len(image.config.Entrypoint) > 0 {
+			candidates = append(candidates, image.config.Entrypoint[0])
+		}
+		if len(image.config.Cmd) > 0 {
+			candidates = append(candidates, image.config.Cmd[0])
+		}
+	}
+
+	if len(candidates) == 0 {
+		return "", errors.New("the image has no entrypoint, a program must be provided")
+	}
+
+	found := ""
+	for _, candidate := range candidates {
+		hostPath, err := image.lookPath(candidate)
+		if err != nil {
+			u.PrintWarning(err)
+			continue
+		}
+		if isElfFile(hostPath) {
+			return hostPath, nil
+		}
+		if len(found) == 0 {
+			found = hostPath
+		}
+	}
+
+	if len(found) == 0 {
+		return "", errors.New("cannot find the program to analyse in the image")
+	}
+	u.PrintWarning(image.containerPath(found) + " is not an ELF file")
+	return found, nil
+}
+
+// sourcesFolder returns the folder of the image which contains the sources
+// of the program (the working directory of the image or the folder of the
+// program).
+func (image *containerImage) sourcesFolder(programPath string) string {
+	if wd := image.config.WorkingDir; len(wd) > 0 && path.Clean(wd) != "/" {
+		if hostPath, err := resolveInRoot(image.rootfs, wd); err == nil {
+			return hostPath
+		}
+	}
+	if len(programPath) == 0 {
+		return image.rootfs
+	}
+	return filepath.Dir(programPath)
+}
+
+// -------------------------------Shared libraries------------------------------
+
+// libraryDirs returns the folders in which the dynamic linker of the image
+// searches shared libraries.
+func (image *containerImage) libraryDirs(elfFile *elf.File) []string {
+
+	dirs := make([]string, 0)
+
+	// ld.so.conf (glibc) and its includes
+	confs := []string{"/etc/ld.so.conf"}
+	for len(confs) > 0 {
+		conf := confs[0]
+		confs = confs[1:]
+		lines, err := image.readLines(conf)
+		if err != nil {
+			continue
+		}
+		for _, line := range lines {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "include") {
+				pattern := strings.TrimSpace(strings.TrimPrefix(line, "include"))
+				if !path.IsAbs(pattern) {
+					pattern = path.Join(path.Dir(conf), pattern)
+				}
+				dir, _ := resolveInRoot(image.rootfs, path.Dir(pattern))
+				matches, _ := filepath.Glob(filepath.Join(dir, path.Base(pattern)))
+				for _, match := range matches {
+					confs = append(confs, path.Join(path.Dir(pattern), filepath.Base(match)))
+				}
+			} else if len(line) > 0 && !strings.HasPrefix(line, "#") {
+				dirs = append(dirs, line)
+			}
+		}
+	}
+
+	// musl
+	muslPaths, _ := filepath.Glob(filepath.Join(image.rootfs, "etc", "ld-musl-*.path"))
+	for _, muslPath := range muslPaths {
+		if lines, err := image.readLines(image.containerPath(muslPath)); err == nil {
+			for _, line := range lines {
+				dirs = append(dirs, strings.Split(line, ":")...)
+			}
+		}
+	}
+
+	// Default folders
+	triplet := map[elf.Machine]string{
+		elf.EM_X86_64:  "x86_64-linux-gnu",
+		elf.EM_386:     "i386-linux-gnu",
+		elf.EM_AARCH64: "aarch64-linux-gnu",
+		elf.EM_ARM:     "arm-linux-gnueabihf",
+	}[elfFile.Machine]
+	if len(triplet) > 0 {
+		dirs = append(dirs, "/lib/"+triplet, "/usr/lib/"+triplet)
+	}
+	if elfFile.Class == elf.ELFCLASS64 {
+		dirs = append(dirs, "/lib64", "/usr/lib64")
+	}
+	return append(dirs, "/lib", "/usr/lib", "/usr/local/lib")
+}
+
+// readLines reads the lines of a file of the image.
+func (image *containerImage) readLines(name string) ([]string, error) {
+	return readLinesInRoot(image.rootfs, name)
+}
+
+// readLinesInRoot reads the lines (without line breaks) of a file located in
+// a root folder.
+//
+// It returns a slice of string which represents each line of the file and an
+// error if any, otherwise it returns nil.
+func readLinesInRoot(root, name string) ([]string, error) {
+	hostPath, err := resolveInRoot(root, name)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := u.ReadLinesFile(hostPath)
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], "\r\n")
+	}
+	return lines, err
+}
+
+// findLibrary searches a shared library within the image.
+//
+// It returns the path of the library on the host (or an empty string).
+func (image *containerImage) findLibrary(lib string, dirs []string, class elf.Class) string {
+
+	if strings.Contains(lib, "/") {
+		dirs = []string{""}
+	}
+
+	for _, dir := range dirs {
+		hostPath, err := resolveInRoot(image.rootfs, path.Join(dir, lib))
+		if err != nil {
+			continue
+		}
+		libElf, err := elf.Open(hostPath)
+		if err != nil {
+			continue
+		}
+		sameClass := libElf.Class == class
+		libElf.Close()
+		if sameClass {
+			return hostPath
+		}
+	}
+	return ""
+}
+
+// neededLibs returns the DT_NEEDED entries of an ELF file and its search
+// paths (DT_RPATH and DT_RUNPATH).
+func (image *containerImage) neededLibs(elfFile *elf.File, hostPath string) ([]string, []string) {
+
+	needed, _ := elfFile.ImportedLibraries()
+
+	origin := path.Dir(image.containerPath(hostPath))
+	dirs := make([]string, 0)
+	for _, tag := range []elf.DynTag{elf.DT_RPATH, elf.DT_RUNPATH} {
+		values, _ := elfFile.DynString(tag)
+		for _, value := range values {
+			for _, dir := range strings.Split(value, ":") {
+				dir = strings.Replace(dir, "${ORIGIN}", origin, -1)
+				dirs = append(dirs, strings.Replace(dir, "$ORIGIN", origin, -1))
+			}
+		}
+	}
+	return needed, dirs
+}
+
+// gatherSharedLibs resolves the shared libraries needed by the program
+// (recursively) within the image. If fullDeps is set, the dependencies of
+// each library are saved, otherwise its path within the image is saved.
+func (image *containerImage) gatherSharedLibs(elfFile *elf.File, programPath string,
+	data *u.StaticData, fullDeps bool) {
+
+	defaultDirs := image.libraryDirs(elfFile)
+
+	type item struct {
+		name     string
+		hostPath string
+	}
+	queue := []item{{filepath.Base(programPath), programPath}}
+	visited := make(map[string]bool)
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		var needed, dirs []string
+		if current.hostPath == programPath {
+			needed, dirs = image.neededLibs(elfFile, programPath)
+		} else {
+			libElf, err := elf.Open(current.hostPath)
+			if err != nil {
+				u.PrintWarning(err)
+				continue
+			}
+			needed, dirs = image.neededLibs(libElf, current.hostPath)
+			libElf.Close()
+			if fullDeps {
+				data.SharedLibs[current.name] = append([]string{}, needed...)
+			}
+		}
+
+		for _, lib := range needed {
+			if visited[lib] {
+				continue
+			}
+			visited[lib] = true
+
+			hostPath := image.findLibrary(lib, append(dirs, defaultDirs...), elfFile.Class)
+			if len(hostPath) == 0 {
+				u.PrintWarning(lib + " not found in the image")
+				data.SharedLibs[lib] = nil
+				continue
+			}
+
+			image.libPaths[lib] = hostPath
+			if !fullDeps {
+				// The rootfs is removed after the analysis
+				data.SharedLibs[lib] = []string{image.containerPath(hostPath)}
+			}
+			queue = append(queue, item{lib, hostPath})
+		}
+	}
+}
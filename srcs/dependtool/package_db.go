@@ -0,0 +1,435 @@
+// Copyright 2019 The UNICORE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// Author: Gaulthier Gain <gaulthier.gain@uliege.be>
+
+package dependtool
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	u "tools/srcs/common"
+)
+
+const (
+	dpkgStatus   = "/var/lib/dpkg/status"
+	dpkgInfo     = "/var/lib/dpkg/info"
+	apkInstalled = "/lib/apk/db/installed"
+	rpmDatabase  = "/var/lib/rpm"
+	rpmSysimage  = "/usr/lib/sysimage/rpm"
+)
+
+// packageBackend represents a package manager whose database of installed
+// packages can be read.
+type packageBackend interface {
+	// kind returns the name of the package manager.
+	kind() string
+	// detect returns true if the package manager is used in the root folder.
+	detect(root string) bool
+	// read reads the packages installed in the root folder.
+	read(root string) (*packageDatabase, error)
+}
+
+// Supported package managers (in order of detection)
+var packageBackends = []packageBackend{dpkgBackend{}, apkBackend{}, rpmBackend{}}
+
+// installedPackage represents a package installed in a root folder.
+type installedPackage struct {
+	name    string
+	depends []string
+	files   []string
+}
+
+// packageDatabase represents the packages installed in a root folder.
+type packageDatabase struct {
+	kind     string
+	packages map[string]*installedPackage
+	// Virtual packages and provides (e.g., so:libc.musl-x86_64.so.1)
+	provides map[string]string
+}
+
+func newPackageDatabase(kind string) *packageDatabase {
+	return &packageDatabase{
+		kind:     kind,
+		packages: make(map[string]*installedPackage),
+		provides: make(map[string]string),
+	}
+}
+
+// readPackageDatabase reads the package database of the first package
+// manager detected in a root folder.
+//
+// It returns a pointer to a packageDatabase structure and an error if any,
+// otherwise it returns nil.
+func readPackageDatabase(root string) (*packageDatabase, error) {
+
+	for _, backend := range packageBackends {
+		if backend.detect(root) {
+			return backend.read(root)
+		}
+	}
+	return nil, errors.New("no package database (dpkg, apk or rpm) found in " + root)
+}
+
+// existsInRoot returns true if a file exists in a root folder.
+func existsInRoot(root, name string) bool {
+	hostPath, err := resolveInRoot(root, name)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(hostPath)
+	return err == nil
+}
+
+// splitParagraphs splits the lines of a database into paragraphs (separated
+// by empty lines).
+func splitParagraphs(lines []string) [][]string {
+
+	paragraphs := make([][]string, 0)
+	current := make([]string, 0)
+	for _, line := range lines {
+		if len(strings.TrimSpace(line)) == 0 {
+			if len(current) > 0 {
+				paragraphs = append(paragraphs, current)
+				current = make([]string, 0)
+			}
+			continue
+		}
+		current = append(current, line)
+	}
+	if len(current) > 0 {
+		paragraphs = append(paragraphs, current)
+	}
+	return paragraphs
+}
+
+// dpkgBackend reads the dpkg status file and the lists of files of the
+// installed packages (Debian, Ubuntu, ...).
+type dpkgBackend struct{}
+
+func (dpkgBackend) kind() string { return "dpkg" }
+
+func (dpkgBackend) detect(root string) bool { return existsInRoot(root, dpkgStatus) }
+
+func (b dpkgBackend) read(root string) (*packageDatabase, error) {
+
+	lines, err := readLinesInRoot(root, dpkgStatus)
+	if err != nil {
+		return nil, err
+	}
+
+	db := newPackageDatabase(b.kind())
+	for _, paragraph := range splitParagraphs(lines) {
+		pkg := &installedPackage{depends: make([]string, 0)}
+		installed := true
+		arch := ""
+		provides := ""
+		for _, line := range paragraph {
+			key, value := splitField(line, ":")
+			switch key {
+			case "Package":
+				pkg.name = value
+			case "Architecture":
+				arch = value
+			case "Status":
+				installed = strings.HasSuffix(value, " installed")
+			case "Depends", "Pre-Depends":
+				pkg.depends = append(pkg.depends, parseDpkgRelations(value)...)
+			case "Provides":
+				provides = value
+			}
+		}
+
+		if len(pkg.name) == 0 || !installed {
+			continue
+		}
+
+		// Files of the package (multi-arch packages use 'name:arch.list')
+		for _, list := range []string{pkg.name + ".list", pkg.name + ":" + arch + ".list"} {
+			if files, err := readLinesInRoot(root, path.Join(dpkgInfo, list)); err == nil {
+				pkg.files = append(pkg.files, files...)
+			}
+		}
+
+		db.packages[pkg.name] = pkg
+		for _, virtual := range parseDpkgRelations(provides) {
+			db.provides[virtual] = pkg.name
+		}
+	}
+	return db, nil
+}
+
+// parseDpkgRelations parses a dpkg relationship field (e.g., "libc6 (>= 2.34),
+// libssl3 | libssl1.1").
+//
+// It returns a slice of package names (only the first alternative is kept).
+func parseDpkgRelations(value string) []string {
+
+	names := make([]string, 0)
+	for _, relation := range strings.Split(value, ",") {
+		relation = strings.TrimSpace(strings.Split(relation, "|")[0])
+		if i := strings.IndexAny(relation, " ("); i >= 0 {
+			relation = relation[:i]
+		}
+		// Remove the architecture qualifier (e.g., python3:any)
+		relation = strings.Split(relation, ":")[0]
+		if len(relation) > 0 {
+			names = append(names, relation)
+		}
+	}
+	return names
+}
+
+// apkBackend reads the apk database of installed packages (Alpine).
+type apkBackend struct{}
+
+func (apkBackend) kind() string { return "apk" }
+
+func (apkBackend) detect(root string) bool { return existsInRoot(root, apkInstalled) }
+
+func (b apkBackend) read(root string) (*packageDatabase, error) {
+
+	lines, err := readLinesInRoot(root, apkInstalled)
+	if err != nil {
+		return nil, err
+	}
+
+	db := newPackageDatabase(b.kind())
+	for _, paragraph := range splitParagraphs(lines) {
+		pkg := &installedPackage{depends: make([]string, 0)}
+		folder := ""
+		provides := make([]string, 0)
+		for _, line := range paragraph {
+			key, value := splitField(line, ":")
+			switch key {
+			case "P":
+				pkg.name = value
+			case "D":
+				for _, dep := range strings.Fields(value) {
+					// Skip conflicts
+					if !strings.HasPrefix(dep, "!") {
+						pkg.depends = append(pkg.depends, trimApkVersion(dep))
+					}
+				}
+			case "p":
+				for _, virtual := range strings.Fields(value) {
+					provides = append(provides, trimApkVersion(virtual))
+				}
+			case "F":
+				folder = value
+			case "R":
+				pkg.files = append(pkg.files, "/"+path.Join(folder, value))
+			}
+		}
+
+		if len(pkg.name) == 0 {
+			continue
+		}
+		db.packages[pkg.name] = pkg
+		for _, virtual := range provides {
+			db.provides[virtual] = pkg.name
+		}
+	}
+	return db, nil
+}
+
+// trimApkVersion removes the version constraint of an apk dependency (e.g.,
+// "so:libc.musl-x86_64.so.1=1.2.3").
+func trimApkVersion(dep string) string {
+	if i := strings.IndexAny(dep, "<>=~"); i >= 0 {
+		return dep[:i]
+	}
+	return dep
+}
+
+// rpmBackend queries the rpm database (Fedora, RHEL, openSUSE, ...). The
+// requirements are the ones displayed by 'rpm -qa --requires'.
+type rpmBackend struct{}
+
+func (rpmBackend) kind() string { return "rpm" }
+
+func (rpmBackend) detect(root string) bool {
+	if !existsInRoot(root, rpmDatabase) && !existsInRoot(root, rpmSysimage) {
+		return false
+	}
+	if _, err := exec.LookPath("rpm"); err != nil {
+		u.PrintWarning("rpm database found but rpm is not installed")
+		return false
+	}
+	return true
+}
+
+func (b rpmBackend) read(root string) (*packageDatabase, error) {
+
+	// One line per requirement, provide and file of each package
+	output, err := u.ExecuteCommand("rpm", []string{"--root", root, "-qa",
+		"--queryformat", "[%{NAME}\tR\t%{REQUIRENAME}\n]" +
+			"[%{NAME}\tP\t%{PROVIDENAME}\n][%{NAME}\tF\t%{FILENAMES}\n]"})
+	if err != nil {
+		return nil, err
+	}
+
+	db := newPackageDatabase(b.kind())
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 || len(fields[2]) == 0 {
+			continue
+		}
+
+		pkg, ok := db.packages[fields[0]]
+		if !ok {
+			pkg = &installedPackage{name: fields[0], depends: make([]string, 0)}
+			db.packages[pkg.name] = pkg
+		}
+
+		switch fields[1] {
+		case "R":
+			// Skip the features of rpm itself (e.g., rpmlib(PayloadIsXz))
+			if !strings.HasPrefix(fields[2], "rpmlib(") {
+				pkg.depends = append(pkg.depends, fields[2])
+			}
+		case "P":
+			db.provides[fields[2]] = pkg.name
+		case "F":
+			pkg.files = append(pkg.files, fields[2])
+		}
+	}
+	return db, nil
+}
+
+// splitField splits a "key: value" line.
+func splitField(line, sep string) (string, string) {
+	i := strings.Index(line, sep)
+	if i < 0 {
+		return line, ""
+	}
+	return line[:i], strings.TrimSpace(line[i+len(sep):])
+}
+
+// resolve returns the name of the package which provides the given name.
+func (db *packageDatabase) resolve(name string) string {
+	if _, ok := db.packages[name]; ok {
+		return name
+	}
+	if provider, ok := db.provides[name]; ok {
+		return provider
+	}
+	if strings.HasPrefix(name, "/") {
+		// File dependency (e.g., /bin/sh)
+		return db.owner(name)
+	}
+	return ""
+}
+
+// owner returns the name of the package which installed one of the given
+// files (or an empty string).
+func (db *packageDatabase) owner(files ...string) string {
+	for _, pkg := range db.packages {
+		for _, f := range pkg.files {
+			if u.Contains(files, f) {
+				return pkg.name
+			}
+		}
+	}
+	return ""
+}
+
+// programOwner returns the name of the package which installed the program
+// (or an empty string).
+func (db *packageDatabase) programOwner(files []string) string {
+
+	packageName := db.owner(files...)
+	if len(packageName) == 0 {
+		u.PrintWarning(files[0] + " is not owned by any " + db.kind + " package")
+	} else {
+		u.PrintInfo(files[0] + " is owned by the " + db.kind + " package " + packageName)
+	}
+	return packageName
+}
+
+// gatherDependencies saves the dependencies of a package. If fullDeps is set,
+// dependencies of dependencies are saved too.
+//
+// It returns false if the package is not installed.
+func (db *packageDatabase) gatherDependencies(packageName string, data *u.StaticData,
+	fullDeps bool) bool {
+
+	if _, ok := db.packages[packageName]; !ok {
+		u.PrintWarning(packageName + " is not installed (" + db.kind + ")")
+		return false
+	}
+
+	data.Dependencies = make(map[string][]string)
+	queue := []string{packageName}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if _, ok := data.Dependencies[name]; ok {
+			continue
+		}
+
+		deps := make([]string, 0)
+		for _, dep := range db.packages[name].depends {
+			resolved := db.resolve(dep)
+			if len(resolved) == 0 {
+				u.PrintWarning("dependency " + dep + " of " + name + " is not installed")
+				continue
+			}
+			if !u.Contains(deps, resolved) && resolved != name {
+				deps = append(deps, resolved)
+			}
+		}
+		data.Dependencies[name] = deps
+
+		if fullDeps {
+			queue = append(queue, deps...)
+		}
+	}
+	return true
+}
+
+// ownerFiles returns the paths under which a program may be referenced by a
+// package database (symbolic links and merged /usr folders).
+func ownerFiles(root, programPath string) []string {
+
+	files := []string{programPath}
+	if resolved, err := resolveInRoot(root, programPath); err == nil {
+		rel, _ := filepath.Rel(root, resolved)
+		files = append(files, "/"+filepath.ToSlash(rel))
+	}
+
+	for _, f := range append([]string{}, files...) {
+		if strings.HasPrefix(f, "/usr/") {
+			files = append(files, strings.TrimPrefix(f, "/usr"))
+		} else {
+			files = append(files, "/usr"+f)
+		}
+	}
+	return files
+}
+
+// gatherDependencies gathers the dependencies of the program from the package
+// database of the image. If packageName is empty, the package which installed
+// the program is used.
+//
+// It returns an error if any, otherwise it returns nil.
+func (image *containerImage) gatherDependencies(programPath, packageName string,
+	data *u.StaticData, fullDeps bool) error {
+
+	db, err := readPackageDatabase(image.rootfs)
+	if err != nil {
+		return err
+	}
+	if len(packageName) == 0 {
+		packageName = db.programOwner(ownerFiles(image.rootfs, image.containerPath(programPath)))
+	}
+	if len(packageName) > 0 {
+		db.gatherDependencies(packageName, data, fullDeps)
+	}
+	return nil
+}
@@ -8,7 +8,9 @@ package dependtool
 
 import (
 	"fmt"
+	"os"
 	"regexp"
+	"sort"
 	"strings"
 
 	u "tools/srcs/common"
@@ -28,35 +30,33 @@ type recursiveData struct {
 // parsePackagesName parses the output of the 'apt-cache pkgnames' command.
 //
 // It returns a string which represents the name of application used by the
-// package manager (apt, ...).
-func parsePackagesName(output string) string {
+// package manager (apt, ...). The package named as the program is selected
+// if any, otherwise the shortest name is selected.
+func parsePackagesName(output, programName string) string {
 
-	var i = 1
-	lines := strings.Split(output, "\n")
-	for _, line := range lines {
-		if len(line) > 0 {
-			fmt.Printf("%d) %s\n", i, line)
-			i++
+	names := make([]string, 0)
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); len(line) > 0 {
+			if line == programName {
+				return line
+			}
+			names = append(names, line)
 		}
 	}
 
-	var input int
-	for true {
-		fmt.Print("Please enter your choice (0 to exit): ")
-		if _, err := fmt.Scanf("%d", &input); err != nil {
-			u.PrintWarning("Choice must be numeric! Try again")
-		} else {
-			if input == 0 {
-				u.PrintWarning("Abort dependencies analysis from apt-cache")
-				return ""
-			} else if (input >= 0) && (input <= i) {
-				return lines[input-1]
-			} else {
-				u.PrintWarning("Invalid input! Try again")
-			}
-		}
+	if len(names) == 0 {
+		return ""
+	} else if len(names) == 1 {
+		return names[0]
 	}
-	return ""
+
+	// Select the shortest name (e.g., 'nginx' instead of 'nginx-full')
+	sort.Slice(names, func(i, j int) bool {
+		return len(names[i]) < len(names[j])
+	})
+	u.PrintWarning("Several packages match " + programName + ", " + names[0] +
+		" is selected among: " + strings.Join(names, ", "))
+	return names[0]
 }
 
 // parseDependencies parses the output of the 'apt-cache depends' command.
@@ -271,6 +271,14 @@ func parseLsof(output string, data *u.DynamicData, fullDeps bool) error {
 
 	lddMap := make(map[string][]string)
 	for _, line := range strings.Split(output, "\n") {
+
+		// Record the regular files which are still open
+		if strings.HasPrefix(line, "/") {
+			if info, err := os.Stat(line); err == nil && info.Mode().IsRegular() {
+				recordFile(data, line, "")
+			}
+		}
+
 		if strings.Contains(line, ".so") {
 			words := strings.Split(line, "/")
 			data.SharedLibs[words[len(words)-1]] = nil
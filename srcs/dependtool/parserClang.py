@@ -32,6 +32,8 @@ verbose = False # Change it to verbose mode
 
 global_funcs = Counter()
 global_calls = Counter()
+global_protos = {}
+global_call_sites = {}
 
 silent_flag = False
 
@@ -136,11 +138,15 @@ def find_funcs_and_calls(tu):
             # filter name to take only the name if necessary
             funcName = filter_func_name(c.displayname)
             global_calls[funcName] += 1
+            if c.location.file is not None:
+                site = c.location.file.name + ':' + str(c.location.line)
+                global_call_sites.setdefault(funcName, []).append(site)
         elif c.kind == CursorKind.FUNCTION_DECL:
             funcs.append(c)
             # filter name to take only the name if necessary
             funcName = filter_func_name(c.displayname)
             global_funcs[funcName] += 1
+            global_protos[funcName] = c.type.spelling
     return funcs, calls
 
 # Write data to json file
@@ -173,11 +179,13 @@ def compare_syscalls(syscalls):
 
 # Main function
 def main():
-    optlist, args = getopt.getopt(sys.argv[1:], "o:qvt")
+    optlist, args = getopt.getopt(sys.argv[1:], "o:qvtpc")
     input_file_names = None
     includepathsFile = None
     output_file_name = None
     textFormat = False
+    prototypes = False
+    callSites = False
     for opt in optlist:
         if opt[0] == "-i":
             includepathFile = opt[1]
@@ -191,6 +199,10 @@ def main():
             verbose = True
         if opt[0] == "-t":
             textFormat = True
+        if opt[0] == "-p":
+            prototypes = True
+        if opt[0] == "-c":
+            callSites = True
 
     
 
@@ -210,7 +222,11 @@ def main():
     if silent_flag is False:
         print("---------------------------------------------------------")
 
-    if textFormat:
+    if textFormat and prototypes:
+        # One function per line: name and prototype separated by a tab
+        for key in global_funcs.keys():
+            print(key + '\t' + global_protos.get(key, ''))
+    elif textFormat:
         i = 0
         for key,value in global_funcs.items():
             if i < len(global_funcs.items())-1:
@@ -218,7 +234,12 @@ def main():
             else:
                 print(key)
             i = i + 1
-    else:
+    if textFormat and callSites:
+        # One call site per line: '@', name and location separated by a tab
+        for key, sites in global_call_sites.items():
+            for site in sites:
+                print('@' + key + '\t' + site)
+    if not textFormat:
         # Dump function declarations and calls to json
         output_dikt = {
             'functions':'',
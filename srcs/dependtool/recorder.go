@@ -0,0 +1,441 @@
+// Copyright 2019 The UNICORE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// Author: Gaulthier Gain <gaulthier.gain@uliege.be>
+
+package dependtool
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	u "tools/srcs/common"
+	"unicode/utf8"
+)
+
+// Timeout of the lines of a telnet request which are not answered
+const minTimeOutMs = 100
+
+// Headers of the recorded http requests which are set by the http client
+var ignoredHeaders = map[string]bool{
+	"Accept-Encoding":   true,
+	"Connection":        true,
+	"Content-Length":    true,
+	"Keep-Alive":        true,
+	"Proxy-Connection":  true,
+	"Te":                true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+}
+
+// recordedChunk represents data read on one side of a proxied connection.
+type recordedChunk struct {
+	fromClient bool
+	at         time.Time
+	data       []byte
+}
+
+// recordedConn represents a client connection proxied to the application.
+type recordedConn struct {
+	mu     sync.Mutex
+	chunks []recordedChunk
+}
+
+// exchange represents a request of the client and the response of the
+// application.
+type exchange struct {
+	request  []byte
+	response []byte
+
+	sent      time.Time // First byte of the request
+	requested time.Time // Last byte of the request
+	answered  time.Time // Last byte of the response
+}
+
+// record records data read on one side of the connection.
+func (c *recordedConn) record(fromClient bool, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.chunks = append(c.chunks, recordedChunk{fromClient, time.Now(),
+		append([]byte(nil), data...)})
+}
+
+// exchanges splits the recorded data into exchanges: a request ends when the
+// application answers. Data sent by the application before the first request
+// (e.g., a banner) belongs to the first response.
+//
+// It returns a slice of exchange which represents the exchanges.
+func (c *recordedConn) exchanges() []exchange {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	exchanges := make([]exchange, 0)
+	current := exchange{}
+	for _, chunk := range c.chunks {
+		if !chunk.fromClient {
+			current.response = append(current.response, chunk.data...)
+			current.answered = chunk.at
+			continue
+		}
+
+		if len(current.request) > 0 && len(current.response) > 0 {
+			exchanges = append(exchanges, current)
+			current = exchange{}
+		}
+		if len(current.request) == 0 {
+			current.sent = chunk.at
+		}
+		current.request = append(current.request, chunk.data...)
+		current.requested = chunk.at
+	}
+
+	if len(current.request) > 0 {
+		exchanges = append(exchanges, current)
+	}
+	return exchanges
+}
+
+// recordingProxy forwards the connections of a client to the application and
+// records the exchanged data.
+type recordingProxy struct {
+	target string
+
+	mu      sync.Mutex
+	conns   []*recordedConn
+	active  map[net.Conn]bool
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+// serve accepts the connections of the client until the listener is closed.
+func (p *recordingProxy) serve(listener net.Listener) {
+	defer p.wg.Done()
+	for {
+		client, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		p.wg.Add(1)
+		go p.forward(client)
+	}
+}
+
+// forward forwards a client connection to the application and records the
+// data of both sides.
+func (p *recordingProxy) forward(client net.Conn) {
+
+	defer p.wg.Done()
+	defer client.Close()
+
+	server, err := net.Dial("tcp", p.target)
+	if err != nil {
+		u.PrintWarning("Impossible to connect to " + p.target + ": " + err.Error())
+		return
+	}
+	defer server.Close()
+	u.PrintInfo("Recording connection from " + client.RemoteAddr().String())
+
+	conn := &recordedConn{}
+	p.mu.Lock()
+	if p.stopped {
+		p.mu.Unlock()
+		return
+	}
+	p.conns = append(p.conns, conn)
+	p.active[client], p.active[server] = true, true
+	p.mu.Unlock()
+
+	done := make(chan struct{}, 2)
+	go copyRecorded(server, client, conn, true, done)
+	go copyRecorded(client, server, conn, false, done)
+	<-done
+	<-done
+
+	p.mu.Lock()
+	delete(p.active, client)
+	delete(p.active, server)
+	p.mu.Unlock()
+}
+
+// stop closes the active connections and waits until they are recorded (the
+// listener must be closed before).
+func (p *recordingProxy) stop() {
+	p.mu.Lock()
+	p.stopped = true
+	for conn := range p.active {
+		_ = conn.Close()
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+// copyRecorded copies the data from src to dst and records them. The data are
+// recorded before being forwarded so that a response is always recorded after
+// its request.
+func copyRecorded(dst, src net.Conn, conn *recordedConn, fromClient bool,
+	done chan<- struct{}) {
+
+	buffer := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buffer)
+		if n > 0 {
+			conn.record(fromClient, buffer[:n])
+			if _, err := dst.Write(buffer[:n]); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	// Propagate the end of the stream (the other side can still answer)
+	if tcpConn, ok := dst.(*net.TCPConn); ok {
+		_ = tcpConn.CloseWrite()
+	}
+	done <- struct{}{}
+}
+
+// runRecorder records the session of a client with the application through a
+// recording proxy and generates a test file (telnet or http) from the
+// recorded exchanges.
+//
+// It returns an error if any, otherwise it returns nil.
+func runRecorder(args *u.Arguments) error {
+
+	testFile := *args.StringArg[testFileArg]
+	if len(testFile) == 0 {
+		return errors.New("the path of the test file to generate (-t) must be provided")
+	}
+
+	target := *args.StringArg[recordTargetArg]
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		return errors.New("the address of the application (--" + recordTargetArg +
+			") must be host:port")
+	}
+
+	typeTest := *args.StringArg[recordTypeArg]
+	if len(typeTest) > 0 && typeTest != telnetTestString && typeTest != httpTestString {
+		return errors.New("the type of the recorded test must be " + telnetTestString +
+			" or " + httpTestString)
+	}
+
+	listener, err := net.Listen("tcp", *args.StringArg[recordArg])
+	if err != nil {
+		return err
+	}
+	proxy := &recordingProxy{target: target, active: make(map[net.Conn]bool)}
+	proxy.wg.Add(1)
+	go proxy.serve(listener)
+
+	// Record until the wait time is elapsed or the user interrupts it
+	waitTime := *args.IntArg[waitTimeArg]
+	u.PrintInfo("Recording " + listener.Addr().String() + " -> " + target + " during " +
+		strconv.Itoa(waitTime) + " sec (Ctrl-C to stop)")
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	select {
+	case <-interrupt:
+	case <-time.After(time.Duration(waitTime) * time.Second):
+	}
+	signal.Stop(interrupt)
+	_ = listener.Close()
+	proxy.stop()
+
+	// Order the exchanges of all the connections
+	exchanges := make([]exchange, 0)
+	for _, conn := range proxy.conns {
+		exchanges = append(exchanges, conn.exchanges()...)
+	}
+	sort.SliceStable(exchanges, func(i, j int) bool {
+		return exchanges[i].sent.Before(exchanges[j].sent)
+	})
+	if len(exchanges) == 0 {
+		return errors.New("no exchange has been recorded")
+	}
+	if exchanges[0].request[0] == 0x16 {
+		return errors.New("TLS sessions cannot be recorded")
+	}
+	u.PrintInfo(strconv.Itoa(len(exchanges)) + " exchanges recorded on " +
+		strconv.Itoa(len(proxy.conns)) + " connections")
+
+	if len(typeTest) == 0 {
+		typeTest = telnetTestString
+		if isHttpRequest(exchanges[0].request) {
+			typeTest = httpTestString
+		}
+	}
+
+	var testStruct *Testing
+	if typeTest == httpTestString {
+		testStruct = httpTestFromRecord(exchanges, target)
+	} else {
+		if len(proxy.conns) > 1 {
+			u.PrintWarning("Telnet tests are replayed over a single connection")
+		}
+		testStruct = telnetTestFromRecord(exchanges, target, *args.BoolArg[recordAssertArg])
+	}
+
+	b, err := json.MarshalIndent(testStruct, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(testFile, append(b, '\n'), 0644); err != nil {
+		return err
+	}
+	u.PrintOk("Test file saved into " + testFile)
+	return nil
+}
+
+// isHttpRequest checks if the given data begin with an http request line.
+//
+// It returns true if it is the case, false otherwise.
+func isHttpRequest(data []byte) bool {
+
+	line := string(data)
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	fields := strings.Fields(line)
+	return len(fields) == 3 && strings.HasPrefix(fields[2], "HTTP/1.")
+}
+
+// recordedTimeCommand computes the value of 'timeMsCommand' from the delays
+// between the exchanges. Since the tester sleeps a random time between zero
+// and this value, it is twice the average delay.
+//
+// It returns the value of 'timeMsCommand' (in milliseconds).
+func recordedTimeCommand(exchanges []exchange) int32 {
+
+	if len(exchanges) < 2 {
+		return 0
+	}
+
+	var total time.Duration
+	for i := 1; i < len(exchanges); i++ {
+		previous := exchanges[i-1].answered
+		if previous.IsZero() {
+			previous = exchanges[i-1].requested
+		}
+		if delay := exchanges[i].sent.Sub(previous); delay > 0 {
+			total += delay
+		}
+	}
+	return int32(2 * total.Milliseconds() / int64(len(exchanges)-1))
+}
+
+// recordedTimeOut computes the timeout of a command from the time taken by
+// the application to answer (twice this time plus a margin).
+//
+// It returns the timeout (in milliseconds) or zero if the request has not
+// been answered.
+func (e exchange) recordedTimeOut() int32 {
+
+	if e.answered.IsZero() || e.answered.Before(e.requested) {
+		return 0
+	}
+	return int32(2*e.answered.Sub(e.requested).Milliseconds()) + 500
+}
+
+// telnetTestFromRecord generates a telnet test from the recorded exchanges.
+// Each line of a request is a command and the response is attributed to the
+// last one. If assert is true, the responses are used as expected outputs.
+//
+// It returns a pointer to a Testing structure.
+func telnetTestFromRecord(exchanges []exchange, target string, assert bool) *Testing {
+
+	host, port, _ := net.SplitHostPort(target)
+	testStruct := &Testing{TypeTest: telnetTestString, AddressTelnet: host,
+		TimeCommand: recordedTimeCommand(exchanges)}
+	testStruct.PortTelnet, _ = strconv.Atoi(port)
+
+	for _, e := range exchanges {
+		if !utf8.Valid(e.request) {
+			u.PrintWarning("Binary data have been recorded: the telnet test may not " +
+				"reproduce the session")
+		}
+
+		lines := make([]string, 0)
+		for _, line := range strings.Split(string(e.request), "\n") {
+			if line = strings.TrimSuffix(line, "\r"); len(line) > 0 {
+				lines = append(lines, line)
+			}
+		}
+
+		for i, line := range lines {
+			cmd := TestCommand{Command: line, TimeOutMs: minTimeOutMs}
+			if i == len(lines)-1 {
+				cmd.TimeOutMs = e.recordedTimeOut()
+				if assert && len(e.response) > 0 {
+					output := string(e.response)
+					cmd.ExpectedOutput = &output
+				}
+			}
+			testStruct.ListCommands = append(testStruct.ListCommands, cmd)
+		}
+	}
+	return testStruct
+}
+
+// httpTestFromRecord generates an http test from the recorded exchanges. The
+// recorded status codes are used as expected status codes.
+//
+// It returns a pointer to a Testing structure.
+func httpTestFromRecord(exchanges []exchange, target string) *Testing {
+
+	testStruct := &Testing{TypeTest: httpTestString, BaseUrl: "http://" + target,
+		TimeCommand: recordedTimeCommand(exchanges)}
+
+	for _, e := range exchanges {
+		requests := bufio.NewReader(bytes.NewReader(e.request))
+		responses := bufio.NewReader(bytes.NewReader(e.response))
+		for {
+			req, err := http.ReadRequest(requests)
+			if err != nil {
+				if err != io.EOF {
+					u.PrintWarning("Ignore a malformed http request: " + err.Error())
+				}
+				break
+			}
+
+			body, _ := ioutil.ReadAll(req.Body)
+			request := HttpRequest{Method: req.Method, Path: req.URL.RequestURI(),
+				Body: string(body), Headers: make(map[string]string)}
+			for key := range req.Header {
+				if !ignoredHeaders[key] {
+					request.Headers[key] = req.Header.Get(key)
+				}
+			}
+
+			// Skip the informational responses (e.g., 100 Continue)
+			resp, err := http.ReadResponse(responses, req)
+			for err == nil && resp.StatusCode >= 100 && resp.StatusCode < 200 &&
+				resp.StatusCode != http.StatusSwitchingProtocols {
+				resp, err = http.ReadResponse(responses, req)
+			}
+			if err == nil {
+				_, _ = io.Copy(ioutil.Discard, resp.Body)
+				resp.Body.Close()
+				request.ExpectedStatus = []int{resp.StatusCode}
+			}
+
+			testStruct.ListRequests = append(testStruct.ListRequests, request)
+		}
+	}
+	return testStruct
+}
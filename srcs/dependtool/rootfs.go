@@ -0,0 +1,218 @@
+// Copyright 2019 The UNICORE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// Author: Gaulthier Gain <gaulthier.gain@uliege.be>
+
+package dependtool
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	u "tools/srcs/common"
+)
+
+// Formats of the root filesystem
+const (
+	cpioRootfs = "cpio" // Initrd read by ukcpio
+	pfs9Rootfs = "9pfs" // Folder shared with 9pfs
+)
+
+// Folders of virtual filesystems (they are not part of the root filesystem)
+var virtualFolders = []string{"/proc", "/sys", "/dev", "/run"}
+
+// rootfsEntry represents a file or a folder of the root filesystem.
+type rootfsEntry struct {
+	path string
+	info os.FileInfo
+}
+
+// rootfsEntries selects the entries of the root filesystem among the files
+// opened by the application. Virtual files, shared libraries (linked into
+// the unikernel) and the program itself are excluded. The parent folders are
+// added before their content.
+//
+// It returns a slice of rootfsEntry which represents the entries.
+func rootfsEntries(files map[string]string, programPath string) []rootfsEntry {
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, filepath.Clean(path))
+	}
+	sort.Strings(paths)
+
+	entries := make([]rootfsEntry, 0)
+	added := make(map[string]bool)
+	var add func(path string, info os.FileInfo)
+	add = func(path string, info os.FileInfo) {
+		if added[path] || path == "/" {
+			return
+		}
+		added[path] = true
+
+		// Add the parent folders first
+		parent := filepath.Dir(path)
+		if !added[parent] && parent != "/" {
+			if parentInfo, err := os.Stat(parent); err == nil {
+				add(parent, parentInfo)
+			}
+		}
+		entries = append(entries, rootfsEntry{path, info})
+	}
+
+	for _, path := range paths {
+
+		virtual := false
+		for _, folder := range virtualFolders {
+			virtual = virtual || path == folder || strings.HasPrefix(path, folder+"/")
+		}
+		if virtual || path == programPath || path == "/etc/ld.so.cache" ||
+			sharedLibRe.MatchString(filepath.Base(path)) {
+			continue
+		}
+
+		// Symbolic links are replaced by their target
+		info, err := os.Stat(path)
+		if err != nil {
+			u.PrintWarning("Skip " + path + " (it does not exist anymore)")
+			continue
+		} else if !info.Mode().IsRegular() && !info.IsDir() {
+			continue
+		}
+		add(path, info)
+	}
+	return entries
+}
+
+// writeCpio writes the entries into a CPIO archive (newc format) which can
+// be used as initrd.
+//
+// It returns an error if any, otherwise it returns nil.
+func writeCpio(filename string, entries []rootfsEntry) error {
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	offset := 0
+	writePadded := func(data []byte) error {
+		n, err := w.Write(data)
+		offset += n
+		if err != nil {
+			return err
+		}
+		for offset%4 != 0 {
+			if err := w.WriteByte(0); err != nil {
+				return err
+			}
+			offset++
+		}
+		return nil
+	}
+	writeHeader := func(ino int, name string, mode uint32, nlink int, mtime int64,
+		size int64) error {
+		header := fmt.Sprintf("070701%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X",
+			ino, mode, 0, 0, nlink, mtime, size, 0, 0, 0, 0, len(name)+1, 0)
+		return writePadded([]byte(header + name + "\x00"))
+	}
+
+	for i, entry := range entries {
+		name := strings.TrimPrefix(entry.path, "/")
+		perm := uint32(entry.info.Mode().Perm())
+		mtime := entry.info.ModTime().Unix()
+
+		if entry.info.IsDir() {
+			if err := writeHeader(i+1, name, syscall.S_IFDIR|perm, 2, mtime, 0); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := writeHeader(i+1, name, syscall.S_IFREG|perm, 1, mtime,
+			entry.info.Size()); err != nil {
+			return err
+		}
+		file, err := os.Open(entry.path)
+		if err != nil {
+			return err
+		}
+		n, err := io.CopyN(w, file, entry.info.Size())
+		file.Close()
+		offset += int(n)
+		if err != nil {
+			return err
+		}
+		if err := writePadded(nil); err != nil {
+			return err
+		}
+	}
+
+	if err := writeHeader(0, "TRAILER!!!", 0, 1, 0, 0); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// copyRootfs copies the entries into a folder which can be shared with
+// 9pfs.
+//
+// It returns an error if any, otherwise it returns nil.
+func copyRootfs(folder string, entries []rootfsEntry) error {
+
+	if err := os.RemoveAll(folder); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		dst := filepath.Join(folder, entry.path)
+		if entry.info.IsDir() {
+			if err := os.MkdirAll(dst, entry.info.Mode().Perm()|0700); err != nil {
+				return err
+			}
+		} else if err := u.CopyFileContents(entry.path, dst); err != nil {
+			return err
+		} else if err := os.Chmod(dst, entry.info.Mode().Perm()); err != nil {
+			return err
+		}
+		if err := os.Chtimes(dst, entry.info.ModTime(), entry.info.ModTime()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generateRootfs generates the root filesystem of the unikernel (CPIO
+// archive or 9pfs folder) from the files opened by the application.
+//
+// It returns the path of the root filesystem and an error if any, otherwise
+// it returns nil.
+func generateRootfs(format, programName, programPath, outFolder string,
+	data *u.DynamicData) (string, error) {
+
+	entries := rootfsEntries(data.Files, programPath)
+	for _, entry := range entries {
+		if !entry.info.IsDir() {
+			u.PrintInfo("Add " + entry.path + " to the root filesystem")
+		}
+	}
+
+	if format == cpioRootfs {
+		filename := outFolder + programName + ".cpio"
+		return filename, writeCpio(filename, entries)
+	}
+
+	folder := outFolder + "rootfs" + u.SEP
+	return folder, copyRootfs(folder, entries)
+}
@@ -4,6 +4,9 @@ import (
 	"debug/elf"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	u "tools/srcs/common"
@@ -49,16 +52,53 @@ func RunAnalyserTool(homeDir string, data *u.Data) {
 		u.PrintErr(err)
 	}
 
+	// Get the answers of the batch mode
+	answers, err := u.ReadAnswers(args)
+	if err != nil {
+		u.PrintErr(err)
+	}
+
+	// Record a client session to generate a test file
+	if len(*args.StringArg[recordArg]) > 0 {
+		u.PrintHeader1("(1.0) RECORD CLIENT SESSION")
+		if err := runRecorder(args); err != nil {
+			u.PrintErr(err)
+		}
+		return
+	}
+
 	// Get the kind of analysis (0: all; 1: static; 2: dynamic; 3: interdependence; 4: sources; 5:
-	// stripped-down app and json for buildtool)
+	// stripped-down app and json for buildtool; 6: system call support in Unikraft)
 	typeAnalysis := *args.IntArg[typeAnalysis]
-	if typeAnalysis < 0 || typeAnalysis > 5 {
-		u.PrintErr(errors.New("analysis argument must be between [0,5]"))
+	if typeAnalysis < 0 || typeAnalysis > 6 {
+		u.PrintErr(errors.New("analysis argument must be between [0,6]"))
+	}
+	if rootfs := *args.StringArg[rootfsArg]; len(rootfs) > 0 && rootfs != cpioRootfs &&
+		rootfs != pfs9Rootfs {
+		u.PrintErr(errors.New("rootfs argument must be " + cpioRootfs + " or " + pfs9Rootfs))
 	}
 
-	// Get program path
-	programPath, err := u.GetProgramPath(&*args.StringArg[programArg])
-	if err != nil {
+	// Get program path (from the host or from a container image)
+	var image *containerImage
+	var programPath string
+	if len(*args.StringArg[imageArg]) > 0 {
+		scratch, err := ioutil.TempDir("", "unicore-image-")
+		if err != nil {
+			u.PrintErr(err)
+		}
+		defer os.RemoveAll(scratch)
+
+		u.PrintHeader1("(1.0) UNPACK CONTAINER IMAGE")
+		if image, err = openImage(*args.StringArg[imageArg], scratch); err != nil {
+			u.PrintErr("Could not unpack the image", err)
+		}
+		if programPath, err = image.programPath(*args.StringArg[programArg]); err != nil {
+			u.PrintErr("Could not determine program path", err)
+		}
+		*args.StringArg[programArg] = filepath.Base(programPath)
+	} else if len(*args.StringArg[programArg]) == 0 {
+		u.PrintErr(errors.New("a program (-p) or an image (-i) must be provided"))
+	} else if programPath, err = u.GetProgramPath(&*args.StringArg[programArg]); err != nil {
 		u.PrintErr("Could not determine program path", err)
 	}
 
@@ -90,12 +130,14 @@ func RunAnalyserTool(homeDir string, data *u.Data) {
 	if typeAnalysis == 0 || typeAnalysis == 1 {
 		u.PrintHeader1("(1.1) RUN STATIC ANALYSIS")
 		runStaticAnalyser(elfFile, isDynamic, isLinux, args, programName, programPath, outFolder,
-			data)
+			image, answers, data)
 	}
 
 	// Run dynamic analyser
 	if typeAnalysis == 0 || typeAnalysis == 2 {
-		if isLinux {
+		if image != nil {
+			u.PrintWarning("Dynamic analysis is skipped for container images (offline analysis)")
+		} else if isLinux {
 			u.PrintHeader1("(1.2) RUN DYNAMIC ANALYSIS")
 			runDynamicAnalyser(args, programName, programPath, outFolder, data)
 		} else {
@@ -106,22 +148,48 @@ func RunAnalyserTool(homeDir string, data *u.Data) {
 
 	// Run interdependence analyser
 	if typeAnalysis == 0 || typeAnalysis == 3 {
-		u.PrintHeader1("(1.3) RUN INTERDEPENDENCE ANALYSIS")
-		_ = runInterdependAnalyser(getProgramFolder(programPath), programName, outFolder)
+		if image != nil {
+			u.PrintWarning("Interdependence analysis is skipped for container images")
+		} else {
+			u.PrintHeader1("(1.3) RUN INTERDEPENDENCE ANALYSIS")
+			_ = runInterdependAnalyser(getProgramFolder(programPath), programName, outFolder)
+		}
 	}
 
 	// Run sources analyser
 	if typeAnalysis == 0 || typeAnalysis == 4 {
 		u.PrintHeader1("(1.4) RUN SOURCES ANALYSIS")
-		runSourcesAnalyser(getProgramFolder(programPath), data)
+		if image != nil {
+			runSourcesAnalyser(image.sourcesFolder(programPath), data)
+		} else {
+			runSourcesAnalyser(getProgramFolder(programPath), data)
+		}
 	}
 
 	// Prepare stripped-down app for buildtool
-	if typeAnalysis == 5 {
+	if typeAnalysis == 5 && image != nil {
+		u.PrintWarning("Stripped-down app preparation is skipped for container images")
+	} else if typeAnalysis == 5 {
 		u.PrintHeader1("(1.5) PREPARE STRIPPED-DOWN APP AND JSON FOR BUILDTOOL")
 		runSourcesAnalyser(runInterdependAnalyser(programPath, programName, outFolder), data)
 	}
 
+	// Run system call support analyser
+	if typeAnalysis == 0 || typeAnalysis == 6 {
+		u.PrintHeader1("(1.6) RUN SYSTEM CALL SUPPORT ANALYSIS")
+		if typeAnalysis == 6 {
+			// Use the system calls of the previous analyses
+			if _, err := u.ReadDataJson(outFolder+programName, data); err != nil {
+				u.PrintWarning("Cannot read the previous analyses: " + err.Error())
+			}
+		}
+		runSyscallSupportAnalyser(args, programName, outFolder, homeDir, data)
+	}
+
+	// Merge the multi-process and IPC patterns detected by the analyses
+	u.PrintHeader1("(1.7) DETECT MULTI-PROCESS AND IPC PATTERNS")
+	runExecutionPatternsAnalyser(args, programName, outFolder, data)
+
 	// Save Data to JSON
 	if err = u.RecordDataJson(outFolder+programName, data); err != nil {
 		u.PrintErr(err)
@@ -141,6 +209,9 @@ func displayProgramDetails(programName, programPath string, args *u.Arguments) {
 	fmt.Println("----------------------------------------------")
 	fmt.Println("Analyze Program: ", color.GreenString(programName))
 	fmt.Println("Full Path: ", color.GreenString(programPath))
+	if len(*args.StringArg[imageArg]) > 0 {
+		fmt.Println("Image: ", color.GreenString(*args.StringArg[imageArg]))
+	}
 	if len(*args.StringArg[optionsArg]) > 0 {
 		fmt.Println("Options: ", color.GreenString(*args.StringArg[optionsArg]))
 	}
@@ -197,9 +268,9 @@ func checkElf(programPath *string) (*elf.File, bool) {
 
 // runStaticAnalyser runs the static analyser
 func runStaticAnalyser(elfFile *elf.File, isDynamic, isLinux bool, args *u.Arguments, programName,
-	programPath, outFolder string, data *u.Data) {
+	programPath, outFolder string, image *containerImage, answers *u.Answers, data *u.Data) {
 
-	staticAnalyser(elfFile, isDynamic, isLinux, *args, data, programPath)
+	staticAnalyser(elfFile, isDynamic, isLinux, *args, data, programPath, image, answers)
 
 	// Save static Data into text file if display mode is set
 	if *args.BoolArg[saveOutputArg] {
@@ -211,8 +282,9 @@ func runStaticAnalyser(elfFile *elf.File, isDynamic, isLinux bool, args *u.Argum
 		}
 
 		fn := outFolderStatic + programName + ".txt"
-		headersStr := []string{"Dependencies (from apt-cache show) list:",
-			"Shared libraries list:", "System calls list:", "Symbols list:"}
+		headersStr := []string{"Shared libraries list:", "System calls list:",
+			"Symbols list:", "Dependencies (from apt-cache show) list:",
+			"System call sites:", "Execution patterns:"}
 
 		if err := u.RecordDataTxt(fn, headersStr, data.StaticData); err != nil {
 			u.PrintWarning(err)
@@ -228,6 +300,19 @@ func runDynamicAnalyser(args *u.Arguments, programName, programPath,
 
 	dynamicAnalyser(args, data, programPath)
 
+	// Generate the root filesystem from the opened files
+	if format := *args.StringArg[rootfsArg]; len(format) > 0 {
+		u.PrintHeader2("(*) Generating the root filesystem")
+		rootfs, err := generateRootfs(format, programName, programPath, outFolder,
+			&data.DynamicData)
+		if err != nil {
+			u.PrintWarning(err)
+		} else {
+			data.DynamicData.Rootfs = rootfs
+			u.PrintOk("Root filesystem saved into " + rootfs)
+		}
+	}
+
 	// Save dynamic Data into text file if display mode is set
 	if *args.BoolArg[saveOutputArg] {
 
@@ -239,7 +324,9 @@ func runDynamicAnalyser(args *u.Arguments, programName, programPath,
 
 		fn := outFolderDynamic + programName + ".txt"
 		headersStr := []string{"Shared libraries list:", "System calls list:",
-			"Symbols list:"}
+			"Symbols list:", "System calls trace:", "Test commands list:",
+			"First seen in:", "Test results:", "Files list:", "Root filesystem:",
+			"Execution patterns:", "Threads created by:"}
 
 		if err := u.RecordDataTxt(fn, headersStr, data.DynamicData); err != nil {
 			u.PrintWarning(err)
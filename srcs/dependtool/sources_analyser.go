@@ -38,19 +38,37 @@ func findSourceFiles(workspace string) ([]string, error) {
 }
 
 // addSourceFileSymbols adds all the symbols present in 'output' to the static data field in
-// 'data'.
+// 'data'. Each line of 'output' contains the name of a symbol and its prototype separated by a
+// tab (e.g., "open\tint (const char *, int, ...)"). The prototype is kept as value. Lines
+// starting with '@' are call sites (e.g., "@fork\tsrc/main.c:42"), only the call sites of the
+// execution patterns are kept.
 func addSourceFileSymbols(output string, data *u.SourcesData) {
 
-	outputTab := strings.Split(output, ",")
+	outputTab := strings.Split(strings.TrimSpace(output), "\n")
 
 	// Get the list of system calls
 	systemCalls := initSystemCalls()
 
-	for _, s := range outputTab {
+	for _, line := range outputTab {
+		if strings.HasPrefix(line, "@") {
+			if fields := strings.SplitN(line[1:], "\t", 2); len(fields) == 2 &&
+				staticPattern(fields[0]) {
+				addPatternEntry(data.ExecutionPatterns, fields[0], fields[1])
+			}
+			continue
+		}
+
+		s, prototype := line, ""
+		if i := strings.Index(line, "\t"); i >= 0 {
+			s, prototype = line[:i], line[i+1:]
+		}
+		if len(s) == 0 {
+			continue
+		}
 		if _, isSyscall := systemCalls[s]; isSyscall {
 			data.SystemCalls[s] = systemCalls[s]
 		} else {
-			data.Symbols[s] = ""
+			data.Symbols[s] = prototype
 		}
 	}
 }
@@ -64,7 +82,7 @@ func extractPrototype(sourcesFiltered []string, data *u.SourcesData) error {
 	for _, f := range sourcesFiltered {
 		script := filepath.Join(os.Getenv("GOPATH"), "src", "tools", "srcs", "dependtool",
 			"parserClang.py")
-		output, err := u.ExecuteCommand("python3", []string{script, "-q", "-t", f})
+		output, err := u.ExecuteCommand("python3", []string{script, "-q", "-t", "-p", "-c", f})
 		if err != nil {
 			u.PrintWarning("Incomplete analysis with file " + f)
 			continue
@@ -104,6 +122,7 @@ func sourcesAnalyser(data *u.Data, programPath string) {
 	// Init symbols members
 	sourcesData.Symbols = make(map[string]string)
 	sourcesData.SystemCalls = make(map[string]int)
+	sourcesData.ExecutionPatterns = make(map[string][]string)
 
 	// Detect symbols from source files
 	u.PrintHeader2("(*) Gathering symbols from source files")
@@ -7,10 +7,9 @@
 package dependtool
 
 import (
-	"bufio"
 	"debug/elf"
 	"fmt"
-	"os"
+	"os/exec"
 	u "tools/srcs/common"
 )
 
@@ -103,66 +102,56 @@ func gatherStaticSharedLibsMac(programPath string, data *u.StaticData,
 	return nil
 }
 
-// gatherDependencies gathers dependencies of a given application.
+// gatherDependencies gathers dependencies of a given application. The package
+// is the one given in the answers file or the one which installed the program
+// according to the package database of the host (dpkg, apk or rpm). If the
+// package is not installed, it is searched in apt-cache.
 //
 // It returns an error if any, otherwise it returns nil.
-func gatherDependencies(programName string, data *u.StaticData, v bool) error {
+func gatherDependencies(programName, programPath string, answers *u.Answers,
+	data *u.StaticData, v bool) error {
 
-	//  Use 'apt-cache pkgnames' to get the name of the package
-	output, err := u.ExecuteCommand("apt-cache",
-		[]string{"pkgnames", programName})
-	if err != nil {
-		return err
-	}
-
-	// If the name of the package is know, execute apt-cache depends
-	if len(output) > 0 {
-		// Parse package name
-		packageName := parsePackagesName(output)
-		if len(packageName) > 0 {
-			if err := executeDependAptCache(packageName, data, v); err != nil {
-				u.PrintWarning(err)
-			}
-			if _, ok := data.Dependencies[packageName]; !ok {
-				data.Dependencies[packageName] = []string{""}
-			}
-		}
+	packageName := answers.PackageName
+	if db, err := readPackageDatabase(u.SEP); err != nil {
+		u.PrintWarning(err)
 	} else {
-		// Enter manually the name of the package
-		u.PrintWarning(programName + " not found in apt-cache")
-		var output string
-		for len(output) == 0 {
-			fmt.Print("Please enter manually the name of the package " +
-				"(empty string to exit): ")
-			scanner := bufio.NewScanner(os.Stdin)
-			if err := scanner.Err(); err != nil {
-				return err
-			}
-
-			if scanner.Scan() {
+		if len(packageName) == 0 && len(programPath) > 0 {
+			packageName = db.programOwner(ownerFiles(u.SEP, programPath))
+		}
+		if len(packageName) > 0 && db.gatherDependencies(packageName, data, v) {
+			return nil
+		}
+	}
 
-				// Get the new package name
-				input := scanner.Text()
-				if input == "" {
-					break
-				}
+	// Fallback: search the package in apt-cache
+	if _, err := exec.LookPath("apt-cache"); err != nil {
+		u.PrintWarning("Skip dependencies analysis from apt-cache depends")
+		return nil
+	}
 
-				output, err = u.ExecuteCommand("apt-cache",
-					[]string{"pkgnames", input})
-				if err != nil {
-					return err
-				}
-			}
+	if len(answers.PackageName) == 0 {
+		//  Use 'apt-cache pkgnames' to get the name of the package
+		output, err := u.ExecuteCommand("apt-cache",
+			[]string{"pkgnames", programName})
+		if err != nil {
+			return err
 		}
 
-		if len(output) == 0 {
+		packageName = parsePackagesName(output, programName)
+		if len(packageName) == 0 {
+			u.PrintWarning(programName + " not found in apt-cache")
 			u.PrintWarning("Skip dependencies analysis from apt-cache depends")
-		} else {
-			packageName := parsePackagesName(output)
-			return executeDependAptCache(packageName, data, v)
+			return nil
 		}
 	}
 
+	// If the name of the package is know, execute apt-cache depends
+	if err := executeDependAptCache(packageName, data, v); err != nil {
+		u.PrintWarning(err)
+	}
+	if _, ok := data.Dependencies[packageName]; !ok {
+		data.Dependencies[packageName] = []string{""}
+	}
 	return nil
 }
 
@@ -196,7 +185,7 @@ func executeDependAptCache(programName string, data *u.StaticData,
 // staticAnalyser runs the static analysis to get shared libraries,
 // system calls and library calls of a given application.
 func staticAnalyser(elfFile *elf.File, isDynamic, isLinux bool, args u.Arguments, data *u.Data,
-	programPath string) {
+	programPath string, image *containerImage, answers *u.Answers) {
 
 	programName := *args.StringArg[programArg]
 	fullDeps := *args.BoolArg[fullDepsArg]
@@ -218,10 +207,26 @@ func staticAnalyser(elfFile *elf.File, isDynamic, isLinux bool, args u.Arguments
 			if err := gatherStaticSymbols(elfFile, isDynamic, staticData); err != nil {
 				u.PrintWarning(err)
 			}
+
+			// Symbols are missing for static binaries and raw system calls
+			u.PrintHeader2("(*) Gathering system calls from system call instructions")
+			if err := gatherSystemCallSites(elfFile, programPath, staticData); err != nil {
+				u.PrintWarning(err)
+			}
+
+			u.PrintHeader2("(*) Gathering multi-process and IPC patterns from binary file")
+			gatherExecutionPatterns(elfFile, programPath, staticData)
 		}
 
 		u.PrintHeader2("(*) Gathering shared libraries from binary file")
-		if isLinux {
+		if image != nil {
+			// Libraries are resolved within the image (ldd cannot be used)
+			image.gatherSharedLibs(elfFile, programPath, staticData, fullDeps)
+
+			if err := elfFile.Close(); err != nil {
+				u.PrintWarning(err)
+			}
+		} else if isLinux {
 			// Cannot use "elfFile.ImportedLibraries()" since we need the ".so" path
 			// So in that case, we need to rely on ldd
 			if err := gatherStaticSharedLibsLinux(programPath, staticData,
@@ -245,15 +250,26 @@ func staticAnalyser(elfFile *elf.File, isDynamic, isLinux bool, args u.Arguments
 		u.PrintHeader2("(*) Gathering symbols and system calls of shared libraries from binary" +
 			"file")
 		for key, path := range staticData.SharedLibs {
+			if image != nil {
+				// SharedLibs only contains the paths within the image
+				path = nil
+				if libPath, ok := image.libPaths[key]; ok {
+					path = []string{libPath}
+				}
+			}
 			if len(path) > 0 {
 				fmt.Printf("\t-> Analysing %s - %s\n", key, path[0])
 				libElf, err := getElf(path[0])
 				if err != nil {
 					u.PrintWarning(err)
+					continue
 				}
 				if err := gatherStaticSymbols(libElf, true, staticData); err != nil {
 					u.PrintWarning(err)
 				}
+				if err := gatherSystemCallSites(libElf, path[0], staticData); err != nil {
+					u.PrintWarning(err)
+				}
 				if err := libElf.Close(); err != nil {
 					u.PrintWarning(err)
 				}
@@ -261,10 +277,18 @@ func staticAnalyser(elfFile *elf.File, isDynamic, isLinux bool, args u.Arguments
 		}
 	}
 
-	if isLinux {
-		// Gather Data from apt-cache
-		u.PrintHeader2("(*) Gathering dependencies from apt-cache depends")
-		if err := gatherDependencies(programName, staticData, fullDeps); err != nil {
+	if image != nil {
+		// Gather Data from the package database of the image
+		u.PrintHeader2("(*) Gathering dependencies from the package database of the image")
+		if err := image.gatherDependencies(programPath, answers.PackageName, staticData,
+			fullDeps); err != nil {
+			u.PrintWarning(err)
+		}
+	} else if isLinux {
+		// Gather Data from the package database or from apt-cache
+		u.PrintHeader2("(*) Gathering dependencies from the package database")
+		if err := gatherDependencies(programName, programPath, answers, staticData,
+			fullDeps); err != nil {
 			u.PrintWarning(err)
 		}
 	}
@@ -0,0 +1,286 @@
+// Copyright 2019 The UNICORE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// Author: Gaulthier Gain <gaulthier.gain@uliege.be>
+
+package dependtool
+
+import (
+	"debug/elf"
+	"fmt"
+	"github.com/knightsc/gapstone"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	u "tools/srcs/common"
+)
+
+// Maximum number of instructions inspected before a system call instruction
+const maxBackwardInsns = 32
+
+// function represents a function symbol of an ELF file.
+type function struct {
+	name string
+	addr uint64
+	size uint64
+}
+
+// functionSymbols returns the function symbols of an ELF file sorted by
+// address.
+func functionSymbols(elfFile *elf.File) []function {
+
+	functions := make([]function, 0)
+	symbols, _ := elfFile.Symbols()
+	dynSymbols, _ := elfFile.DynamicSymbols()
+	for _, s := range append(symbols, dynSymbols...) {
+		if elf.ST_TYPE(s.Info) == elf.STT_FUNC && s.Value > 0 {
+			functions = append(functions, function{s.Name, s.Value, s.Size})
+		}
+	}
+
+	sort.Slice(functions, func(i, j int) bool {
+		return functions[i].addr < functions[j].addr
+	})
+	return functions
+}
+
+// findFunction returns the name of the function which contains the given
+// address (or an empty string).
+func findFunction(functions []function, addr uint64) string {
+	i := sort.Search(len(functions), func(i int) bool {
+		return functions[i].addr > addr
+	}) - 1
+	if i < 0 {
+		return ""
+	}
+	if f := functions[i]; f.size == 0 || addr < f.addr+f.size {
+		return f.name
+	}
+	return ""
+}
+
+// regFamily returns the 64-bit name of an x86 register (e.g., "eax" -> "rax").
+// It returns an empty string if the operand is not a general purpose
+// register.
+func regFamily(reg string) string {
+
+	switch reg {
+	case "rax", "eax", "ax", "al", "ah":
+		return "rax"
+	case "rbx", "ebx", "bx", "bl", "bh":
+		return "rbx"
+	case "rcx", "ecx", "cx", "cl", "ch":
+		return "rcx"
+	case "rdx", "edx", "dx", "dl", "dh":
+		return "rdx"
+	case "rsi", "esi", "si", "sil":
+		return "rsi"
+	case "rdi", "edi", "di", "dil":
+		return "rdi"
+	case "rbp", "ebp", "bp", "bpl":
+		return "rbp"
+	case "rsp", "esp", "sp", "spl":
+		return "rsp"
+	}
+
+	// r8-r15 and their sub-registers (r8d, r8w, r8b)
+	if strings.HasPrefix(reg, "r") {
+		name := strings.TrimRight(reg, "dwb")
+		if n, err := strconv.Atoi(name[1:]); err == nil && n >= 8 && n <= 15 {
+			return name
+		}
+	}
+	return ""
+}
+
+// immValue returns the value of an immediate operand.
+func immValue(operand string) (int, bool) {
+	v, err := strconv.ParseInt(strings.TrimSpace(operand), 0, 64)
+	if err != nil {
+		return 0, false
+	}
+	return int(v), true
+}
+
+// isControlFlow returns true if an instruction can change the control flow
+// or the value of rax (previous system call).
+func isControlFlow(insn gapstone.Instruction) bool {
+	if strings.HasPrefix(insn.Mnemonic, "j") || strings.HasPrefix(insn.Mnemonic, "loop") {
+		return true
+	}
+	switch insn.Mnemonic {
+	case "call", "ret", "syscall", "sysenter", "int", "hlt", "ud2":
+		return true
+	}
+	return false
+}
+
+// resolveSyscallNumber resolves the system call number of a system call
+// instruction by walking back (within the same basic block) to the
+// instruction which sets rax/eax.
+//
+// It returns the system call number and true if it can be resolved.
+func resolveSyscallNumber(insns []gapstone.Instruction, index int) (int, bool) {
+
+	target := "rax"
+	for i := index - 1; i >= 0 && index-i <= maxBackwardInsns; i-- {
+
+		insn := insns[i]
+		if isControlFlow(insn) {
+			return -1, false
+		}
+
+		ops := strings.Split(insn.OpStr, ", ")
+		if regFamily(ops[0]) != target {
+			continue
+		}
+
+		switch insn.Mnemonic {
+		case "cmp", "test", "push":
+			// The register is not written
+			continue
+		case "mov", "movabs":
+			if len(ops) != 2 {
+				return -1, false
+			}
+			if v, ok := immValue(ops[1]); ok {
+				return v, true
+			}
+			if reg := regFamily(ops[1]); len(reg) > 0 {
+				// Follow the source register (e.g., mov eax, edi)
+				target = reg
+				continue
+			}
+		case "xor", "sub":
+			if len(ops) == 2 && ops[0] == ops[1] {
+				return 0, true
+			}
+		case "pop":
+			// push 0x3c; pop rax
+			if i > 0 && insns[i-1].Mnemonic == "push" {
+				if v, ok := immValue(insns[i-1].OpStr); ok {
+					return v, true
+				}
+			}
+		}
+
+		// The value is computed or loaded from memory
+		return -1, false
+	}
+	return -1, false
+}
+
+// disassemble disassembles a section (invalid bytes are skipped).
+//
+// It returns a slice of instructions.
+func disassemble(engine *gapstone.Engine, content []byte, addr uint64) []gapstone.Instruction {
+
+	insns := make([]gapstone.Instruction, 0)
+	for offset := 0; offset < len(content); {
+		part, err := engine.Disasm(content[offset:], addr+uint64(offset), 0)
+		if err != nil || len(part) == 0 {
+			offset++
+			continue
+		}
+		insns = append(insns, part...)
+
+		last := part[len(part)-1]
+		offset = int(uint64(last.Address)+uint64(last.Size)-addr) + 1
+	}
+	return insns
+}
+
+// gatherSystemCallSites disassembles the executable sections of an ELF file
+// to find the system call instructions (syscall, sysenter and int 0x80) and
+// resolves the system call number loaded into rax/eax.
+//
+// It returns an error if any, otherwise it returns nil.
+func gatherSystemCallSites(elfFile *elf.File, path string, data *u.StaticData) error {
+
+	var mode int
+	switch elfFile.Machine {
+	case elf.EM_X86_64:
+		mode = gapstone.CS_MODE_64
+	case elf.EM_386:
+		mode = gapstone.CS_MODE_32
+	default:
+		return fmt.Errorf("disassembly of %s binaries is not supported",
+			elfFile.Machine)
+	}
+
+	engine, err := gapstone.New(gapstone.CS_ARCH_X86, mode)
+	if err != nil {
+		return err
+	}
+	defer engine.Close()
+
+	// Numbers of the x86_64 and i386 ABIs
+	systemCalls := initSystemCalls()
+	names := map[bool]map[int]string{true: {}, false: {}}
+	for name, number := range systemCalls {
+		names[true][number] = name
+	}
+	for name, number := range initSystemCalls386() {
+		names[false][number] = name
+	}
+
+	functions := functionSymbols(elfFile)
+	nbSites, nbUnresolved := 0, 0
+	for _, section := range elfFile.Sections {
+
+		if section.Flags&elf.SHF_EXECINSTR == 0 || section.Type == elf.SHT_NOBITS {
+			continue
+		}
+
+		content, err := section.Data()
+		if err != nil {
+			u.PrintWarning(err)
+			continue
+		}
+
+		insns := disassemble(&engine, content, section.Addr)
+		for i, insn := range insns {
+
+			var abi64 bool
+			if insn.Mnemonic == "syscall" && mode == gapstone.CS_MODE_64 {
+				abi64 = true
+			} else if v, ok := immValue(insn.OpStr); insn.Mnemonic != "sysenter" &&
+				(insn.Mnemonic != "int" || !ok || v != 0x80) {
+				continue
+			}
+
+			site := u.SystemCallSite{
+				Binary:   filepath.Base(path),
+				Address:  uint64(insn.Address),
+				Function: findFunction(functions, uint64(insn.Address)),
+				Number:   -1,
+			}
+
+			if number, ok := resolveSyscallNumber(insns, i); ok {
+				site.Number = number
+				site.Name, site.Resolved = names[abi64][number]
+			}
+
+			if site.Resolved {
+				if number, ok := systemCalls[site.Name]; ok {
+					data.SystemCalls[site.Name] = number
+				} else {
+					// i386 only system call
+					data.SystemCalls[site.Name] = site.Number
+				}
+			} else {
+				nbUnresolved++
+			}
+
+			nbSites++
+			data.SystemCallSites = append(data.SystemCallSites, site)
+		}
+	}
+
+	u.PrintInfo(fmt.Sprintf("%d system call instructions found in %s "+
+		"(%d unresolved)", nbSites, filepath.Base(path), nbUnresolved))
+
+	return nil
+}
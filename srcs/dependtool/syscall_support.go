@@ -0,0 +1,387 @@
+// Copyright 2019 The UNICORE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// Author: Gaulthier Gain <gaulthier.gain@uliege.be>
+
+package dependtool
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	u "tools/srcs/common"
+)
+
+var (
+	// Definition of a system call (e.g., UK_SYSCALL_R_DEFINE(int, close, int, fd))
+	syscallDefineRe = regexp.MustCompile(
+		`\bUK_(?:LL)?SYSCALL(?:_R)?(?:_E)?_DEFINE\s*\(\s*[^,]+,\s*([a-zA-Z0-9_]+)`)
+	// Registration of system calls in a Makefile.uk (e.g.,
+	// UK_PROVIDED_SYSCALLS-$(CONFIG_LIBVFSCORE) += read-3 write-3)
+	providedSyscallsRe = regexp.MustCompile(`^\s*UK_PROVIDED_SYSCALLS-\S+\s*\+?=(.*)$`)
+	providedSyscallRe  = regexp.MustCompile(`^([a-zA-Z0-9_]+)-\d+[eE]?$`)
+	// Body of a system call which is not implemented
+	stubBodyRe = regexp.MustCompile(`\bENOSYS\b|WARN_STUBBED`)
+)
+
+// Maximum number of statements of a stub (e.g., "UK_WARN_STUBBED(); errno =
+// ENOSYS; return -1;"). Longer bodies only reject some arguments.
+const maxStubStatements = 3
+
+// unikraftSyscalls represents the system calls of a Unikraft checkout and
+// the micro-libs which provide them.
+type unikraftSyscalls struct {
+	implemented map[string][]string
+	stubbed     map[string][]string
+	registered  map[string][]string
+}
+
+// addSyscallLib associates a micro-lib to a system call (only once).
+func addSyscallLib(syscalls map[string][]string, name, lib string) {
+	if !u.Contains(syscalls[name], lib) {
+		syscalls[name] = append(syscalls[name], lib)
+	}
+}
+
+// microLibName returns the name of the micro-lib which contains the given
+// file (relative to the workspace).
+//
+// It returns the name of the micro-lib.
+func microLibName(rel string) string {
+
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) > 3 && parts[0]+u.SEP == u.UNIKRAFTFOLDER {
+		// unikraft/lib/<lib>/... or unikraft/plat/<plat>/...
+		if parts[1] == "lib" {
+			return parts[2]
+		}
+		return parts[1] + "/" + parts[2]
+	} else if len(parts) > 2 && parts[0]+u.SEP == u.LIBSFOLDER {
+		// libs/<lib>/...
+		return parts[1]
+	}
+	return filepath.Dir(rel)
+}
+
+// functionBody returns the body of the function whose definition starts at
+// the given offset.
+//
+// It returns the body or nil if it is only a declaration.
+func functionBody(content []byte, offset int) []byte {
+
+	start := bytes.IndexByte(content[offset:], '{')
+	if start < 0 {
+		return nil
+	} else if end := bytes.IndexByte(content[offset:], ';'); end >= 0 && end < start {
+		return nil
+	}
+
+	start += offset
+	depth := 0
+	for i := start; i < len(content); i++ {
+		switch content[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return content[start : i+1]
+			}
+		}
+	}
+	return content[start:]
+}
+
+// scanSyscallDefinitions scans the system calls defined in a source file.
+func (s *unikraftSyscalls) scanSyscallDefinitions(path, lib string) {
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil || !bytes.Contains(content, []byte("SYSCALL")) {
+		return
+	}
+
+	for _, match := range syscallDefineRe.FindAllSubmatchIndex(content, -1) {
+
+		// Skip the definition of the macros themselves
+		lineStart := bytes.LastIndexByte(content[:match[0]], '\n') + 1
+		if bytes.HasPrefix(bytes.TrimSpace(content[lineStart:match[0]]), []byte("#")) {
+			continue
+		}
+
+		name := string(content[match[2]:match[3]])
+		body := functionBody(content, match[1])
+		if stubBodyRe.Match(body) && bytes.Count(body, []byte(";")) <= maxStubStatements {
+			addSyscallLib(s.stubbed, name, lib)
+		} else {
+			addSyscallLib(s.implemented, name, lib)
+		}
+	}
+}
+
+// scanProvidedSyscalls scans the system calls registered in a Makefile.uk.
+func (s *unikraftSyscalls) scanProvidedSyscalls(path, lib string) {
+
+	lines, err := u.ReadLinesFile(path)
+	if err != nil {
+		return
+	}
+
+	// Join the continuation lines
+	statement := ""
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasSuffix(line, "\\") {
+			statement += strings.TrimSuffix(line, "\\") + " "
+			continue
+		}
+		statement += line
+
+		if match := providedSyscallsRe.FindStringSubmatch(statement); match != nil {
+			for _, field := range strings.Fields(match[1]) {
+				if m := providedSyscallRe.FindStringSubmatch(field); m != nil {
+					addSyscallLib(s.registered, m[1], lib)
+				}
+			}
+		}
+		statement = ""
+	}
+}
+
+// scanUnikraftSyscalls scans the system calls defined (UK_SYSCALL_DEFINE,
+// UK_SYSCALL_R_DEFINE and UK_LLSYSCALL_* macros) and registered
+// (UK_PROVIDED_SYSCALLS) by the micro-libs of the workspace (unikraft and
+// external libs).
+//
+// It returns a pointer to an unikraftSyscalls structure and an error if any,
+// otherwise it returns nil.
+func scanUnikraftSyscalls(workspace string) (*unikraftSyscalls, error) {
+
+	syscalls := &unikraftSyscalls{
+		implemented: make(map[string][]string),
+		stubbed:     make(map[string][]string),
+		registered:  make(map[string][]string),
+	}
+
+	for _, folder := range []string{u.UNIKRAFTFOLDER, u.LIBSFOLDER} {
+		if _, err := os.Stat(workspace + folder); os.IsNotExist(err) {
+			continue
+		}
+
+		err := filepath.Walk(workspace+folder, func(path string, info os.FileInfo,
+			err error) error {
+			if err != nil {
+				return nil
+			}
+
+			if info.IsDir() {
+				// Skip git metadata and downloaded sources
+				if info.Name() == ".git" || info.Name() == "build" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			rel, err := filepath.Rel(workspace, path)
+			if err != nil {
+				return err
+			}
+			if filepath.Ext(path) == ".c" {
+				syscalls.scanSyscallDefinitions(path, microLibName(rel))
+			} else if info.Name() == "Makefile.uk" {
+				syscalls.scanProvidedSyscalls(path, microLibName(rel))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return syscalls, nil
+}
+
+// syscallSupport cross-references the system calls found by the analyses
+// with the system calls of Unikraft.
+//
+// It returns a slice of SyscallSupport ordered by system call number.
+func syscallSupport(data *u.Data, unikraft *unikraftSyscalls) []u.SyscallSupport {
+
+	systemCalls := initSystemCalls()
+	analyses := make(map[string][]string)
+	for _, analysis := range []struct {
+		name  string
+		calls map[string]int
+	}{
+		{"static", data.StaticData.SystemCalls},
+		{"dynamic", data.DynamicData.SystemCalls},
+		{"sources", data.SourcesData.SystemCalls},
+	} {
+		for name := range analysis.calls {
+			if _, ok := systemCalls[name]; ok {
+				analyses[name] = append(analyses[name], analysis.name)
+			}
+		}
+	}
+
+	support := make([]u.SyscallSupport, 0, len(analyses))
+	for name, found := range analyses {
+		s := u.SyscallSupport{Name: name, Number: systemCalls[name], Analyses: found}
+		if libs, ok := unikraft.implemented[name]; ok {
+			s.Status, s.Libs = u.SYSCALL_IMPLEMENTED, libs
+		} else if libs, ok := unikraft.stubbed[name]; ok {
+			s.Status, s.Libs = u.SYSCALL_STUBBED, libs
+		} else if libs, ok := unikraft.registered[name]; ok {
+			s.Status, s.Libs = u.SYSCALL_IMPLEMENTED, libs
+		} else {
+			s.Status = u.SYSCALL_MISSING
+		}
+		support = append(support, s)
+	}
+
+	sort.Slice(support, func(i, j int) bool {
+		return support[i].Number < support[j].Number
+	})
+	return support
+}
+
+// SystemCallSupport returns the support of the system calls of the
+// application by Unikraft. The support computed by the dependency analyser is
+// used if any, otherwise the workspace is scanned.
+//
+// It returns a slice of SyscallSupport and an error if any, otherwise it
+// returns nil.
+func SystemCallSupport(workspace string, data *u.Data) ([]u.SyscallSupport, error) {
+
+	if len(data.SyscallSupport) > 0 {
+		return data.SyscallSupport, nil
+	}
+
+	if !strings.HasSuffix(workspace, u.SEP) {
+		workspace += u.SEP
+	}
+	if _, err := os.Stat(workspace + u.UNIKRAFTFOLDER); err != nil {
+		return nil, err
+	}
+
+	unikraft, err := scanUnikraftSyscalls(workspace)
+	if err != nil {
+		return nil, err
+	}
+	return syscallSupport(data, unikraft), nil
+}
+
+// MissingSystemCalls returns the system calls of the application which are
+// neither implemented nor stubbed by Unikraft (see SystemCallSupport).
+//
+// It returns a slice of SyscallSupport and an error if any, otherwise it
+// returns nil.
+func MissingSystemCalls(workspace string, data *u.Data) ([]u.SyscallSupport, error) {
+
+	support, err := SystemCallSupport(workspace, data)
+	if err != nil {
+		return nil, err
+	}
+
+	missing := make([]u.SyscallSupport, 0)
+	for _, s := range support {
+		if s.Status == u.SYSCALL_MISSING {
+			missing = append(missing, s)
+		}
+	}
+	return missing, nil
+}
+
+// SystemCallArgs returns the types of the arguments of a Linux system call
+// (see initSystemCallsArgs). The types are empty if the system call has no
+// argument.
+//
+// It returns the types of the arguments and false if it is not a system call
+// or if its arguments are unknown.
+func SystemCallArgs(name string) (string, bool) {
+	kinds, ok := initSystemCallsArgs()[name]
+	return kinds, ok
+}
+
+// syscallSupportReport formats the support of the system calls as a text
+// report (one system call per line).
+//
+// It returns the report.
+func syscallSupportReport(support []u.SyscallSupport) string {
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%-4s %-24s %-12s %-32s %s\n", "NR", "SYSCALL", "STATUS",
+		"MICRO-LIBS", "ANALYSES"))
+	for _, s := range support {
+		libs := strings.Join(s.Libs, ",")
+		if len(libs) == 0 {
+			libs = "-"
+		}
+		sb.WriteString(fmt.Sprintf("%-4d %-24s %-12s %-32s %s\n", s.Number, s.Name,
+			s.Status, libs, strings.Join(s.Analyses, ",")))
+	}
+	return sb.String()
+}
+
+// runSyscallSupportAnalyser compares the system calls of the application
+// with the system calls implemented by Unikraft and displays the porting
+// gaps.
+func runSyscallSupportAnalyser(args *u.Arguments, programName, outFolder,
+	homeDir string, data *u.Data) {
+
+	workspace := *args.StringArg[workspaceArg]
+	if len(workspace) == 0 {
+		workspace = homeDir + u.SEP + u.WORKSPACEFOLDER
+	} else if !strings.HasSuffix(workspace, u.SEP) {
+		workspace += u.SEP
+	}
+	if _, err := os.Stat(workspace + u.UNIKRAFTFOLDER); err != nil {
+		u.PrintWarning("Cannot find Unikraft in " + workspace + ": system call support " +
+			"analysis is skipped")
+		return
+	}
+
+	unikraft, err := scanUnikraftSyscalls(workspace)
+	if err != nil {
+		u.PrintWarning(err)
+		return
+	}
+	defined := make(map[string]bool)
+	for _, syscalls := range []map[string][]string{unikraft.implemented, unikraft.registered} {
+		for name := range syscalls {
+			defined[name] = true
+		}
+	}
+	u.PrintInfo(strconv.Itoa(len(defined)) + " system calls implemented by Unikraft (" +
+		strconv.Itoa(len(unikraft.stubbed)) + " stubs)")
+
+	data.SyscallSupport = syscallSupport(data, unikraft)
+	count := make(map[string]int)
+	for _, s := range data.SyscallSupport {
+		count[s.Status]++
+		switch s.Status {
+		case u.SYSCALL_STUBBED:
+			u.PrintWarning(s.Name + " is stubbed by " + strings.Join(s.Libs, ", "))
+		case u.SYSCALL_MISSING:
+			u.PrintWarning(s.Name + " is missing")
+		}
+	}
+	u.PrintInfo(fmt.Sprintf("%d system calls: %d implemented, %d stubbed, %d missing",
+		len(data.SyscallSupport), count[u.SYSCALL_IMPLEMENTED], count[u.SYSCALL_STUBBED],
+		count[u.SYSCALL_MISSING]))
+
+	// Save the support matrix into a text file if display mode is set
+	if *args.BoolArg[saveOutputArg] {
+		fn := outFolder + programName + "_syscall_support.txt"
+		if err := u.WriteToFile(fn, []byte(syscallSupportReport(
+			data.SyscallSupport))); err != nil {
+			u.PrintWarning(err)
+		} else {
+			u.PrintOk("System call support saved into " + fn)
+		}
+	}
+}
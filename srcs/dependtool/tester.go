@@ -8,10 +8,13 @@ package dependtool
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
 	"net"
 	"os/exec"
+	"regexp"
 	"strconv"
 	"strings"
 	"syscall"
@@ -23,6 +26,7 @@ const (
 	stdinTest = iota
 	execTest
 	telnetTest
+	httpTest
 	externalTesting
 )
 
@@ -30,6 +34,7 @@ const (
 	stdinTestString  = "stdin"
 	execTestString   = "exec"
 	telnetTestString = "telnet"
+	httpTestString   = "http"
 )
 
 const (
@@ -39,17 +44,73 @@ const (
 
 type Testing struct {
 	TypeTest    string `json:"typeTest"`
-	TimeOutTest int    `json:"timeOutMsTest"`
+	TimeOutTest int    `json:"timeOutMsTest,omitempty"`
 
 	// Only for telnet test
-	AddressTelnet string `json:"addressTelnet"`
-	PortTelnet    int    `json:"portTelnet"`
+	AddressTelnet string `json:"addressTelnet,omitempty"`
+	PortTelnet    int    `json:"portTelnet,omitempty"`
 
-	TimeCommand  int32    `json:"timeMsCommand"`
-	ListCommands []string `json:"listCommands"`
+	// Only for http test
+	BaseUrl      string        `json:"baseUrl,omitempty"`
+	CACert       string        `json:"caCert,omitempty"`
+	Insecure     bool          `json:"insecure,omitempty"`
+	ListRequests []HttpRequest `json:"listRequests,omitempty"`
+
+	TimeCommand  int32         `json:"timeMsCommand"`
+	ListCommands []TestCommand `json:"listCommands,omitempty"`
+}
+
+// TestCommand represents a command of a test file and the assertions on its
+// output. A command can be written as a single string (no assertion).
+type TestCommand struct {
+	Command string `json:"command"`
+
+	// Assertions (the output is compared without surrounding whitespaces)
+	ExpectedOutput *string `json:"expectedOutput,omitempty"`
+	OutputMatches  string  `json:"outputMatches,omitempty"`
+	// Only for exec test
+	ExitCode *int `json:"exitCode,omitempty"`
+
+	// Timeout of the command (overrides timeMsCommand)
+	TimeOutMs int32 `json:"timeOutMs,omitempty"`
+}
+
+// UnmarshalJSON decodes a test command from a string or from an object.
+func (c *TestCommand) UnmarshalJSON(b []byte) error {
+
+	var command string
+	if err := json.Unmarshal(b, &command); err == nil {
+		*c = TestCommand{Command: command}
+		return nil
+	}
+
+	// Avoid a recursive call of UnmarshalJSON
+	type testCommand TestCommand
+	return json.Unmarshal(b, (*testCommand)(c))
 }
 
-// checkTypeTest checks the type of testing: exec, stdin and telnet tests.
+// MarshalJSON encodes a test command as a string if it has no assertion and
+// no timeout, otherwise as an object.
+func (c TestCommand) MarshalJSON() ([]byte, error) {
+
+	if c.ExpectedOutput == nil && len(c.OutputMatches) == 0 && c.ExitCode == nil &&
+		c.TimeOutMs == 0 {
+		return json.Marshal(c.Command)
+	}
+
+	type testCommand TestCommand
+	return json.Marshal(testCommand(c))
+}
+
+// timeOut returns the timeout of the command.
+func (c TestCommand) timeOut() time.Duration {
+	if c.TimeOutMs > 0 {
+		return time.Duration(c.TimeOutMs) * time.Millisecond
+	}
+	return time.Duration(timeOutMs) * time.Millisecond
+}
+
+// checkTypeTest checks the type of testing: exec, stdin, telnet and http tests.
 //
 // It returns an integer which represents the type of tests
 func checkTypeTest(testStruct *Testing) int {
@@ -64,11 +125,34 @@ func checkTypeTest(testStruct *Testing) int {
 		return execTest
 	} else if strings.Compare(testStruct.TypeTest, telnetTestString) == 0 {
 		return telnetTest
+	} else if strings.Compare(testStruct.TypeTest, httpTestString) == 0 {
+		return httpTest
 	}
 
 	return externalTesting
 }
 
+// testCommands returns the commands of the test file (the name of the
+// requests for http tests).
+//
+// It returns a slice of string which represents the test commands.
+func testCommands(testStruct *Testing) []string {
+
+	if checkTypeTest(testStruct) != httpTest {
+		commands := make([]string, len(testStruct.ListCommands))
+		for i, cmd := range testStruct.ListCommands {
+			commands[i] = cmd.Command
+		}
+		return commands
+	}
+
+	commands := make([]string, len(testStruct.ListRequests))
+	for i, request := range testStruct.ListRequests {
+		commands[i] = request.String()
+	}
+	return commands
+}
+
 // setDurationTimeOut sets the duration of the timeout by computing a reference
 // value. In addition an extra margin value is added (3sec).
 //
@@ -77,7 +161,14 @@ func setDurationTimeOut(t *Testing, waitTime int) time.Duration {
 
 	if checkTypeTest(t) != externalTesting {
 		// Compute the number of commands + execution time (+ 3 seconds safe margin)
-		totalMs := t.TimeCommand*int32(len(t.ListCommands)) + timeOutMs
+		totalMs := t.TimeCommand*int32(len(testCommands(t))) + timeOutMs
+
+		// The timeout of a command overrides timeMsCommand
+		for _, cmd := range t.ListCommands {
+			if cmd.TimeOutMs > 0 {
+				totalMs += cmd.TimeOutMs - t.TimeCommand
+			}
+		}
 		return time.Duration(totalMs) * time.Millisecond
 	}
 
@@ -97,7 +188,8 @@ func runCommandTester(programPath, programName, command, option string,
 	ctx, cancel := context.WithTimeout(context.Background(), timeOut)
 	defer cancel()
 
-	args := strings.Fields("-f " + programPath + " " + option)
+	// Timestamps (-tt) are used to attribute the trace to the test commands
+	args := strings.Fields("-f -tt " + programPath + " " + option)
 	cmd := exec.CommandContext(ctx, command, args...)
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 
@@ -109,7 +201,7 @@ func runCommandTester(programPath, programName, command, option string,
 		cmd.Stdin = bufIn
 		for _, cmds := range testStruct.ListCommands {
 			time.Sleep(100 * time.Millisecond)
-			bufIn.Write([]byte(cmds + "\n"))
+			bufIn.Write([]byte(cmds.Command + "\n"))
 		}
 	}
 
@@ -119,19 +211,11 @@ func runCommandTester(programPath, programName, command, option string,
 	}
 
 	// Run a go routine to handle the tests
-	go func() {
-		if checkTypeTest(testStruct) != stdinTest {
-			Tester(programName, cmd, data, testStruct, dArgs)
-
-			// Kill the program after the tester has finished the job
-			if err := u.PKill(programName, syscall.SIGINT); err != nil {
-				u.PrintErr(err)
-			}
-		}
-	}()
+	waitTester := startTester(programName, cmd, data, testStruct, dArgs)
 
 	// Ignore the error because the program is killed (waitTime)
 	_ = cmd.Wait()
+	waitTester()
 
 	if ctx.Err() == context.DeadlineExceeded {
 		u.PrintInfo("Time out during executing: " + cmd.String())
@@ -165,7 +249,7 @@ func RunVerifCommandTester(programPath, programName, option string, testStruct *
 			} else {
 				time.Sleep(100 * time.Millisecond)
 			}
-			bufIn.Write([]byte(cmds + "\n"))
+			bufIn.Write([]byte(cmds.Command + "\n"))
 		}
 	}
 
@@ -175,9 +259,10 @@ func RunVerifCommandTester(programPath, programName, option string, testStruct *
 	}
 
 	// Run a go routine to handle the tests
+	report := make(chan string, 1)
 	go func() {
 		if checkTypeTest(testStruct) != stdinTest {
-			VerifTester(testStruct)
+			report <- VerifTester(testStruct)
 
 			// Kill the program after the tester has finished the job
 			if err := u.PKill(programName, syscall.SIGINT); err != nil {
@@ -189,6 +274,13 @@ func RunVerifCommandTester(programPath, programName, option string, testStruct *
 	// Ignore the error because the program is killed (waitTime)
 	_ = cmd.Wait()
 
+	// The responses of the tests are compared too
+	select {
+	case r := <-report:
+		bufOut.WriteString(r)
+	default:
+	}
+
 	if ctx.Err() == context.DeadlineExceeded {
 		u.PrintInfo("Time out during executing: " + cmd.String())
 		return bufOut.String(), bufErr.String()
@@ -197,6 +289,44 @@ func RunVerifCommandTester(programPath, programName, option string, testStruct *
 	return bufOut.String(), bufErr.String()
 }
 
+// startTester runs the Tester in a go routine (except for stdin tests) and
+// kills the program once the tests are done. The Tester records its data
+// (shared libraries, files and test results) into its own DynamicData since
+// data is written by the tracer at the same time.
+//
+// It returns a function which waits for the Tester and merges its data into
+// data.
+func startTester(programName string, cmd *exec.Cmd, data *u.DynamicData,
+	testStruct *Testing, dArgs DynamicArgs) func() {
+
+	if checkTypeTest(testStruct) == stdinTest {
+		return func() {}
+	}
+
+	testerData := &u.DynamicData{SharedLibs: make(map[string][]string)}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Tester(programName, cmd, testerData, testStruct, dArgs)
+
+		// Kill the program after the tester has finished the job
+		if err := u.PKill(programName, syscall.SIGINT); err != nil {
+			u.PrintErr(err)
+		}
+	}()
+
+	return func() {
+		<-done
+		for name, libs := range testerData.SharedLibs {
+			data.SharedLibs[name] = libs
+		}
+		for path := range testerData.Files {
+			recordFile(data, path, "")
+		}
+		data.TestResults = append(data.TestResults, testerData.TestResults...)
+	}
+}
+
 // Tester runs the executable file of a given application to perform tests to
 // get program dependencies.
 //
@@ -209,16 +339,12 @@ func Tester(programName string, cmd *exec.Cmd, data *u.DynamicData,
 		u.PrintInfo("Run internal tests from file " + dArgs.testFile)
 
 		// Launch execution tests
-		if checkTypeTest(testStruct) == execTest {
-			launchTestsExternal(testStruct)
-		} else if checkTypeTest(testStruct) == telnetTest {
-			if len(testStruct.AddressTelnet) == 0 || testStruct.PortTelnet == 0 {
-				u.PrintWarning("Cannot find Address and port for telnet " +
-					"within json file. Skip tests")
-			} else {
-				launchTelnetTest(testStruct)
-			}
-		}
+		report := &testReport{}
+		_ = runTests(testStruct, dArgs.phases, report)
+		dArgs.phases.begin(shutdownPhase)
+
+		report.summary()
+		data.TestResults = report.results
 	} else {
 		u.PrintInfo("Waiting for external tests for " + strconv.Itoa(
 			dArgs.waitTime) + " sec")
@@ -238,83 +364,227 @@ func Tester(programName string, cmd *exec.Cmd, data *u.DynamicData,
 	}
 }
 
-func VerifTester(testStruct *Testing) {
+// VerifTester runs the tests of the test file against the application or
+// its unikernel.
+//
+// It returns the outputs of the tests.
+func VerifTester(testStruct *Testing) string {
 	// Wait until the program has started
 	time.Sleep(time.Second * startupSec)
 	u.PrintInfo("Run internal tests from test file")
 
 	// Launch execution tests
-	if checkTypeTest(testStruct) == execTest {
-		launchTestsExternal(testStruct)
-	} else if checkTypeTest(testStruct) == telnetTest {
+	report := &testReport{}
+	output := runTests(testStruct, nil, report)
+	report.summary()
+
+	return output
+}
+
+// runTests runs the tests of the test file according to their type. The
+// beginning of each test is recorded into phases (if not nil).
+//
+// It returns the outputs of the tests.
+func runTests(testStruct *Testing, phases *testPhases, report *testReport) string {
+
+	switch checkTypeTest(testStruct) {
+	case execTest:
+		return launchTestsExternal(testStruct, phases, report)
+	case telnetTest:
 		if len(testStruct.AddressTelnet) == 0 || testStruct.PortTelnet == 0 {
 			u.PrintWarning("Cannot find Address and port for telnet " +
 				"within json file. Skip tests")
-		} else {
-			launchTelnetTest(testStruct)
+			return ""
 		}
+		return launchTelnetTest(testStruct, phases, report)
+	case httpTest:
+		return launchHttpTest(testStruct, phases, report)
 	}
+	return ""
 }
 
 //----------------------------------Tests---------------------------------------
 
-// launchTestsExternal runs external tests written in the 'test.json' file.
+// testReport records the result of each test.
+type testReport struct {
+	results []u.TestResult
+}
+
+// add records the result of a test from its failed assertions.
+func (r *testReport) add(command string, failures []string) {
+
+	r.results = append(r.results, u.TestResult{
+		Command:  command,
+		Passed:   len(failures) == 0,
+		Failures: failures,
+	})
+
+	if len(failures) == 0 {
+		u.PrintOk("PASS: " + command)
+	}
+	for _, failure := range failures {
+		u.PrintWarning("FAIL: " + command + ": " + failure)
+	}
+}
+
+// summary displays the number of passed tests and the failed ones.
+func (r *testReport) summary() {
+
+	passed := 0
+	for _, result := range r.results {
+		if result.Passed {
+			passed++
+		}
+	}
+
+	u.PrintInfo(fmt.Sprintf("%d/%d tests passed", passed, len(r.results)))
+	for _, result := range r.results {
+		if !result.Passed {
+			u.PrintWarning("Failed test: " + result.Command)
+		}
+	}
+}
+
+// checkOutput checks the assertions of a command on its output (without
+// surrounding whitespaces, e.g. "^[0-9]+$" matches "2\n").
+//
+// It returns a slice of string which represents the failed assertions.
+func checkOutput(cmd TestCommand, output string) []string {
+
+	output = strings.TrimSpace(output)
+	failures := make([]string, 0)
+	if cmd.ExpectedOutput != nil && output != strings.TrimSpace(*cmd.ExpectedOutput) {
+		failures = append(failures, "output "+strconv.Quote(output)+
+			" is not "+strconv.Quote(strings.TrimSpace(*cmd.ExpectedOutput)))
+	}
+
+	if len(cmd.OutputMatches) > 0 {
+		if re, err := regexp.Compile(cmd.OutputMatches); err != nil {
+			failures = append(failures, "invalid regexp "+strconv.Quote(cmd.OutputMatches))
+		} else if !re.MatchString(output) {
+			failures = append(failures, "output does not match "+
+				strconv.Quote(cmd.OutputMatches))
+		}
+	}
+	return failures
+}
+
+// executeTestCommand executes a command of an exec test within a shell.
+//
+// It returns the output (stdout) of the command, its exit code and an error
+// if the command cannot be executed or is timed out.
+func executeTestCommand(cmd TestCommand) (string, int, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), cmd.timeOut())
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "/bin/bash", "-c", cmd.Command).Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return string(out), -1, errors.New("timed out after " +
+			cmd.timeOut().String())
+	}
+
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return string(out), exitErr.ExitCode(), nil
+	} else if err != nil {
+		return "", -1, err
+	}
+	return string(out), 0, nil
+}
+
+// launchTestsExternal runs external tests written in the 'test.json' file and
+// checks their outputs and exit codes. The beginning of each test is recorded
+// into phases (if not nil).
 //
-func launchTestsExternal(testStruct *Testing) {
+// It returns the outputs of the tests.
+func launchTestsExternal(testStruct *Testing, phases *testPhases, report *testReport) string {
 
+	var sb strings.Builder
 	for _, cmd := range testStruct.ListCommands {
-		if len(cmd) > 0 {
+		if len(cmd.Command) > 0 {
 
 			// Perform a sleep between command if specified
 			if testStruct.TimeCommand > 0 {
 				timeMs := rand.Int31n(testStruct.TimeCommand)
 				time.Sleep(time.Duration(timeMs) * time.Millisecond)
 			}
+			phases.begin(cmd.Command)
 
 			// Execute each line as a command
-			if _, err := u.ExecutePipeCommand(cmd); err != nil {
-				u.PrintWarning("Impossible to execute test: " + cmd)
-			} else {
-				u.PrintInfo("Test executed: " + cmd)
+			output, exitCode, err := executeTestCommand(cmd)
+			sb.WriteString(output)
+
+			failures := make([]string, 0)
+			if err != nil {
+				failures = append(failures, err.Error())
+			} else if cmd.ExitCode != nil && exitCode != *cmd.ExitCode {
+				failures = append(failures, fmt.Sprintf("exit code %d is not %d", exitCode,
+					*cmd.ExitCode))
+			} else if cmd.ExitCode == nil && exitCode != 0 {
+				failures = append(failures, fmt.Sprintf("exit code %d", exitCode))
 			}
+			report.add(cmd.Command, append(failures, checkOutput(cmd, output)...))
 		}
 	}
+	return sb.String()
 }
 
-// launchTelnetTest runs telnet tests written in the 'test.json' file.
+// launchTelnetTest runs telnet tests written in the 'test.json' file and
+// checks the responses of the server. The beginning of each test is recorded
+// into phases (if not nil).
 //
-func launchTelnetTest(testStruct *Testing) {
+// It returns the responses of the server.
+func launchTelnetTest(testStruct *Testing, phases *testPhases, report *testReport) string {
 
 	addr := testStruct.AddressTelnet + ":" + strconv.Itoa(testStruct.PortTelnet)
-	conn, _ := net.Dial("tcp", addr)
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		u.PrintWarning("Impossible to connect to " + addr + ": " + err.Error())
+	} else {
+		defer conn.Close()
+	}
 
+	var sb strings.Builder
 	for _, cmd := range testStruct.ListCommands {
-		if len(cmd) > 0 {
+		if len(cmd.Command) > 0 {
 
 			// Perform a sleep between command if specified
 			if testStruct.TimeCommand > 0 {
 				timeMs := rand.Int31n(testStruct.TimeCommand)
 				time.Sleep(time.Duration(timeMs) * time.Millisecond)
 			}
+			phases.begin(cmd.Command)
+
+			if conn == nil {
+				report.add(cmd.Command, []string{"not connected to " + addr})
+				continue
+			}
 
 			// Set a timeout to avoid blocking
-			if err := conn.SetReadDeadline(
-				time.Now().Add(time.Duration(timeOutMs) * time.Millisecond)); err != nil {
+			if err := conn.SetReadDeadline(time.Now().Add(cmd.timeOut())); err != nil {
 				u.PrintWarning("Impossible to set a timeout to TCP command")
 			}
 
 			// Send commands (test)
-			if _, err := fmt.Fprintf(conn, cmd+"\n"); err != nil {
-				u.PrintWarning("Impossible to execute test: " + cmd)
-			} else {
-				u.PrintInfo("Test executed: " + cmd)
+			if _, err := fmt.Fprintf(conn, cmd.Command+"\n"); err != nil {
+				report.add(cmd.Command, []string{err.Error()})
+				continue
 			}
+			u.PrintInfo("Test executed: " + cmd.Command)
 
 			// Read response
 			message := readerTelnet(conn)
 			fmt.Println("----->Message from server: " + message)
+			sb.WriteString(message)
+
+			failures := checkOutput(cmd, message)
+			if cmd.ExitCode != nil {
+				u.PrintWarning("exitCode is ignored for telnet tests: " + cmd.Command)
+			}
+			report.add(cmd.Command, failures)
 		}
 	}
+	return sb.String()
 }
 
 // readerTelnet reads data from the telnet connection.
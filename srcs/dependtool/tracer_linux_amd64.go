@@ -0,0 +1,424 @@
+// Copyright 2019 The UNICORE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// Author: Gaulthier Gain <gaulthier.gain@uliege.be>
+
+//go:build linux && amd64
+// +build linux,amd64
+
+package dependtool
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	u "tools/srcs/common"
+)
+
+const (
+	// Maximum number of recorded system calls
+	maxTraces = 1 << 16
+	// Maximum length of a decoded string argument (PATH_MAX)
+	maxStrLength = 4096
+
+	// Kill the tracees if the tracer exits (not defined by syscall)
+	ptraceExitKill = 0x100000
+	traceOptions   = syscall.PTRACE_O_TRACESYSGOOD | syscall.PTRACE_O_TRACEFORK |
+		syscall.PTRACE_O_TRACEVFORK | syscall.PTRACE_O_TRACECLONE |
+		syscall.PTRACE_O_TRACEEXEC | ptraceExitKill
+	// Current working directory (dirfd of openat)
+	atFdCwd = -100
+)
+
+// nativeTracer indicates if the native tracer is supported.
+const nativeTracer = true
+
+// tracee represents a traced process (or thread).
+type tracee struct {
+	inSyscall bool
+	started   bool
+	// The tracee may have left the process group of the program (setsid,
+	// setpgid), it is thus waited by pid
+	outside bool
+	trace   u.SystemCallTrace
+}
+
+// tracer records the system calls of a process and of its children (processes
+// and threads) by using PTRACE_SYSCALL.
+type tracer struct {
+	pid     int
+	names   map[int]string
+	args    map[string]string
+	tracees map[int]*tracee
+	data    *u.DynamicData
+	full    bool
+	phases  *testPhases
+	// Number of files opened with a relative path which is not resolved
+	unresolved int
+}
+
+func newTracer(pid int, data *u.DynamicData, phases *testPhases) *tracer {
+
+	names := make(map[int]string)
+	for name, number := range initSystemCalls() {
+		names[number] = name
+	}
+
+	return &tracer{
+		pid:     pid,
+		names:   names,
+		args:    initSystemCallsArgs(),
+		tracees: make(map[int]*tracee),
+		data:    data,
+		phases:  phases,
+	}
+}
+
+// runCommandTracer runs a program under the native tracer and captures stdout
+// and stderr of the program. It will also run the Tester to explore several
+// execution paths of the given app.
+//
+// It returns two strings which are respectively stdout and stderr and an
+// error if any, otherwise it returns nil.
+func runCommandTracer(programPath, programName, option string,
+	testStruct *Testing, dArgs DynamicArgs, data *u.DynamicData) (string, string, error) {
+
+	// All the ptrace requests must come from the thread which started the
+	// traced process
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	timeOut := setDurationTimeOut(testStruct, dArgs.waitTime)
+	u.PrintInfo("Max testing duration of " + programName + " : " + timeOut.String())
+
+	cmd := exec.Command(programPath, strings.Fields(option)...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Ptrace: true}
+
+	bufOut, bufErr, bufIn := &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdout = bufOut
+	cmd.Stderr = bufErr
+
+	if checkTypeTest(testStruct) == stdinTest {
+		cmd.Stdin = bufIn
+		for _, cmds := range testStruct.ListCommands {
+			bufIn.Write([]byte(cmds.Command + "\n"))
+		}
+	}
+
+	if err := cmd.Start(); err != nil {
+		return "", "", err
+	}
+
+	// Kill the whole process group once the testing duration is elapsed
+	timer := time.AfterFunc(timeOut, func() {
+		u.PrintInfo("Time out during executing: " + cmd.String())
+		_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	})
+	defer timer.Stop()
+
+	// Run a go routine to handle the tests
+	waitTester := startTester(programName, cmd, data, testStruct, dArgs)
+
+	err := newTracer(cmd.Process.Pid, data, dArgs.phases).run()
+
+	// The process is already reaped by the tracer, only wait for its outputs
+	_ = cmd.Wait()
+	waitTester()
+
+	return bufOut.String(), bufErr.String(), err
+}
+
+// run traces the process until all the tracees have exited.
+//
+// It returns an error if any, otherwise it returns nil.
+func (t *tracer) run() error {
+
+	// The process is stopped after its execve (PTRACE_TRACEME)
+	var status syscall.WaitStatus
+	if _, err := syscall.Wait4(t.pid, &status, syscall.WALL, nil); err != nil {
+		return err
+	}
+	if err := syscall.PtraceSetOptions(t.pid, traceOptions); err != nil {
+		return err
+	}
+	t.tracees[t.pid] = &tracee{started: true}
+	if err := syscall.PtraceSyscall(t.pid, 0); err != nil {
+		return err
+	}
+
+	for {
+		pid, err := t.wait(&status)
+		if err == syscall.EINTR {
+			continue
+		} else if err == syscall.ECHILD {
+			// No more tracee
+			if t.unresolved > 0 {
+				u.PrintWarning(fmt.Sprintf("%d file(s) opened with a relative path are "+
+					"not recorded", t.unresolved))
+			}
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if status.Exited() || status.Signaled() {
+			if tr, ok := t.tracees[pid]; ok && tr.inSyscall {
+				// exit and exit_group never return
+				t.record(tr.trace)
+			}
+			delete(t.tracees, pid)
+			continue
+		} else if !status.Stopped() {
+			continue
+		}
+
+		tr, known := t.tracees[pid]
+		if !known {
+			tr = &tracee{}
+			t.tracees[pid] = tr
+		}
+
+		signal := 0
+		switch sig := status.StopSignal(); {
+		case sig == syscall.SIGTRAP|0x80:
+			t.syscallStop(pid, tr)
+		case sig == syscall.SIGTRAP && status.TrapCause() > 0:
+			// fork, vfork, clone and exec events (children are traced
+			// automatically)
+			t.newChild(pid, tr, status.TrapCause())
+		case sig == syscall.SIGSTOP && !tr.started:
+			// First stop of a new tracee
+		default:
+			// Deliver the signal to the tracee
+			signal = int(sig)
+		}
+		tr.started = true
+
+		// Ignore the error since the tracee may have been killed
+		_ = syscall.PtraceSyscall(pid, signal)
+	}
+}
+
+// wait waits for a state change of a tracee. Only the tracees are waited
+// (the commands run by the Tester must not be reaped): the process group of
+// the program is waited, and the tracees which may have left it are polled.
+//
+// It returns the pid of the tracee and an error if any, otherwise it returns
+// nil. ECHILD is returned when there is no more tracee.
+func (t *tracer) wait(status *syscall.WaitStatus) (int, error) {
+
+	for {
+		pids := make([]int, 0)
+		for pid, tr := range t.tracees {
+			if tr.outside {
+				pids = append(pids, pid)
+			}
+		}
+		if len(pids) == 0 {
+			// The program was started with Setpgid (pgid == pid)
+			return syscall.Wait4(-t.pid, status, syscall.WALL, nil)
+		}
+
+		alive := false
+		for _, pid := range append(pids, -t.pid) {
+			wpid, err := syscall.Wait4(pid, status, syscall.WALL|syscall.WNOHANG, nil)
+			if err == syscall.ECHILD {
+				if pid > 0 {
+					// Reaped by the group wait
+					delete(t.tracees, pid)
+				}
+				continue
+			} else if err != nil && err != syscall.EINTR {
+				return wpid, err
+			}
+			alive = true
+			if wpid > 0 {
+				return wpid, nil
+			}
+		}
+		if !alive {
+			return 0, syscall.ECHILD
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// newChild registers the child of a fork, vfork or clone event. A child
+// inherits the process group of its parent.
+func (t *tracer) newChild(pid int, tr *tracee, cause int) {
+
+	if cause != syscall.PTRACE_EVENT_FORK && cause != syscall.PTRACE_EVENT_VFORK &&
+		cause != syscall.PTRACE_EVENT_CLONE {
+		return
+	}
+	msg, err := syscall.PtraceGetEventMsg(pid)
+	if err != nil {
+		return
+	}
+	child, ok := t.tracees[int(msg)]
+	if !ok {
+		child = &tracee{}
+		t.tracees[int(msg)] = child
+	}
+	child.outside = child.outside || tr.outside
+}
+
+// syscallStop handles the entry and the exit of a system call.
+func (t *tracer) syscallStop(pid int, tr *tracee) {
+
+	var regs syscall.PtraceRegs
+	if err := syscall.PtraceGetRegs(pid, &regs); err != nil {
+		return
+	}
+
+	if !tr.inSyscall {
+		tr.inSyscall = true
+		number := int(regs.Orig_rax)
+		name, ok := t.names[number]
+		if !ok {
+			name = "syscall_" + strconv.Itoa(number)
+		}
+		tr.trace = u.SystemCallTrace{
+			Pid:    pid,
+			Number: number,
+			Name:   name,
+			Args:   t.decodeArgs(pid, name, &regs),
+		}
+
+		// Poll the tracees which may leave the process group
+		switch name {
+		case "setsid":
+			tr.outside = true
+		case "setpgid":
+			tr.outside = true
+			if target, ok := t.tracees[int(int32(regs.Rdi))]; ok {
+				target.outside = true
+			}
+		}
+		return
+	}
+
+	tr.inSyscall = false
+	tr.trace.Return = int64(regs.Rax)
+	if tr.trace.Return < 0 && tr.trace.Return > -4096 {
+		tr.trace.Error = syscall.Errno(-tr.trace.Return).Error()
+	} else {
+		tr.trace.Path = resolvePath(pid, tr.trace)
+	}
+	t.record(tr.trace)
+}
+
+// resolvePath resolves the relative path of an open system call by using the
+// working directory of the tracee (or the folder of dirfd for openat). The
+// tracee must be stopped.
+//
+// It returns the absolute path or an empty string if the path is absolute or
+// cannot be resolved.
+func resolvePath(pid int, trace u.SystemCallTrace) string {
+
+	index, dir := 0, "/proc/"+strconv.Itoa(pid)+"/cwd"
+	switch trace.Name {
+	case "open":
+	case "openat":
+		index = 1
+		if fd, err := strconv.Atoi(trace.Args[0]); err == nil && fd != atFdCwd {
+			dir = "/proc/" + strconv.Itoa(pid) + "/fd/" + trace.Args[0]
+		}
+	default:
+		return ""
+	}
+
+	path, err := strconv.Unquote(trace.Args[index])
+	if err != nil || filepath.IsAbs(path) {
+		return ""
+	}
+	base, err := os.Readlink(dir)
+	if err != nil || !filepath.IsAbs(base) {
+		return ""
+	}
+	return filepath.Join(base, path)
+}
+
+// record adds a system call to the dynamic data and attributes it to the
+// current test command.
+func (t *tracer) record(trace u.SystemCallTrace) {
+
+	t.data.SystemCalls[trace.Name] = trace.Number
+	t.unresolved += attributeSystemCall(t.data, t.phases.current(), trace)
+
+	if len(t.data.Traces) < maxTraces {
+		t.data.Traces = append(t.data.Traces, trace)
+	} else if !t.full {
+		u.PrintWarning("Too many system calls, only the first " +
+			strconv.Itoa(maxTraces) + " are recorded")
+		t.full = true
+	}
+}
+
+// decodeArgs decodes the arguments of a system call according to its
+// signature (see initSystemCallsArgs).
+//
+// It returns a slice of string which represents the arguments.
+func (t *tracer) decodeArgs(pid int, name string, regs *syscall.PtraceRegs) []string {
+
+	values := []uint64{regs.Rdi, regs.Rsi, regs.Rdx, regs.R10, regs.R8, regs.R9}
+
+	kinds, ok := t.args[name]
+	if !ok {
+		// Unknown signature
+		kinds = "xxxxxx"
+	}
+
+	args := make([]string, len(kinds))
+	for i, kind := range kinds {
+		switch kind {
+		case 'i':
+			args[i] = strconv.Itoa(int(int32(values[i])))
+		case 'u':
+			args[i] = strconv.FormatUint(values[i], 10)
+		case 's':
+			args[i] = readString(pid, values[i])
+		default:
+			args[i] = "0x" + strconv.FormatUint(values[i], 16)
+		}
+	}
+	return args
+}
+
+// readString reads a null-terminated string from the memory of a tracee.
+//
+// It returns the quoted string, followed by truncatedSuffix if it is longer
+// than maxStrLength (as strace does).
+func readString(pid int, addr uint64) string {
+
+	if addr == 0 {
+		return "NULL"
+	}
+
+	buf := make([]byte, 0, maxStrLength)
+	word := make([]byte, 8)
+	for len(buf) < maxStrLength {
+		n, err := syscall.PtracePeekData(pid, uintptr(addr)+uintptr(len(buf)), word)
+		if err != nil || n == 0 {
+			break
+		}
+		if i := bytes.IndexByte(word[:n], 0); i >= 0 {
+			return strconv.Quote(string(append(buf, word[:i]...)))
+		}
+		buf = append(buf, word[:n]...)
+	}
+
+	if len(buf) == 0 {
+		// Invalid address
+		return "0x" + strconv.FormatUint(addr, 16)
+	}
+	return strconv.Quote(string(buf)) + truncatedSuffix
+}
@@ -0,0 +1,24 @@
+// Copyright 2019 The UNICORE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// Author: Gaulthier Gain <gaulthier.gain@uliege.be>
+
+//go:build !linux || !amd64
+// +build !linux !amd64
+
+package dependtool
+
+import (
+	"errors"
+	u "tools/srcs/common"
+)
+
+// nativeTracer indicates if the native tracer is supported.
+const nativeTracer = false
+
+// runCommandTracer is only supported on linux/amd64 (strace is used instead).
+func runCommandTracer(programPath, programName, option string,
+	testStruct *Testing, dArgs DynamicArgs, data *u.DynamicData) (string, string, error) {
+	return "", "", errors.New("the native tracer is not supported on this platform")
+}
@@ -16,6 +16,7 @@ import (
 	"tools/srcs/crawlertool"
 	"tools/srcs/dependtool"
 	"tools/srcs/extractertool"
+	"tools/srcs/reporttool"
 	"tools/srcs/veriftool"
 )
 
@@ -73,6 +74,12 @@ func main() {
 		return
 	}
 
+	if *args.BoolArg[u.REPORT] {
+		u.PrintHeader1("(*) RUN PORT-READINESS REPORT TOOL")
+		reporttool.RunReportTool(usr.HomeDir)
+		return
+	}
+
 	if all || *args.BoolArg[u.DEP] {
 
 		// Initialize data
@@ -0,0 +1,36 @@
+// Copyright 2019 The UNICORE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// Author: Gaulthier Gain <gaulthier.gain@uliege.be>
+
+package reporttool
+
+import (
+	"github.com/akamensky/argparse"
+	"os"
+	u "tools/srcs/common"
+)
+
+const (
+	programArg   = "program"
+	workspaceArg = "workspace"
+	outputArg    = "output"
+)
+
+// parseLocalArguments parses arguments of the application.
+//
+// It returns an error if any, otherwise it returns nil.
+func parseLocalArguments(p *argparse.Parser, args *u.Arguments) error {
+
+	args.InitArgParse(p, args, u.STRINGLIST, "p", programArg,
+		&argparse.Options{Required: true, Help: "Program name (can be repeated " +
+			"to rank several programs)"})
+	args.InitArgParse(p, args, u.STRING, "u", workspaceArg,
+		&argparse.Options{Required: false, Help: "Workspace Path"})
+	args.InitArgParse(p, args, u.STRING, "o", outputArg,
+		&argparse.Options{Required: false, Help: "Output folder of the " +
+			"ranking (default: home folder)"})
+
+	return u.ParserWrapper(p, os.Args)
+}
@@ -0,0 +1,328 @@
+// Copyright 2019 The UNICORE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// Author: Gaulthier Gain <gaulthier.gain@uliege.be>
+
+package reporttool
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"tools/srcs/buildtool"
+	u "tools/srcs/common"
+	"tools/srcs/dependtool"
+)
+
+// Maximum readiness score
+const maxScore = 100
+
+// Criteria of the readiness score. Each occurrence of a criterion costs
+// weight points, up to max points.
+var criteria = []struct {
+	name   string
+	weight int
+	max    int
+}{
+	{missingSyscallsCriterion, 3, 30},
+	{stubbedSyscallsCriterion, 1, 10},
+	{missingLibcCriterion, 1, 20},
+	{forkCriterion, 15, 15},
+	{execCriterion, 10, 10},
+	{threadsCriterion, 5, 5},
+	{ipcCriterion, 5, 10},
+	{sharedLibsCriterion, 5, 20},
+	{microLibsCriterion, 1, 10},
+}
+
+const (
+	missingSyscallsCriterion = "Missing system calls"
+	stubbedSyscallsCriterion = "Stubbed system calls"
+	missingLibcCriterion     = "Missing libc functions"
+	forkCriterion            = "Process creation (fork)"
+	execCriterion            = "Program execution (exec)"
+	threadsCriterion         = "Threading"
+	ipcCriterion             = "SysV IPC"
+	sharedLibsCriterion      = "Unmatched shared libraries"
+	microLibsCriterion       = "Micro-libs (above 15)"
+)
+
+// Number of micro-libs which does not lower the score
+const baseMicroLibs = 15
+
+var (
+	// Functions and system calls which create processes
+	forkFunctions = []string{"fork", "vfork", "daemon", "forkpty"}
+	// Functions and system calls which execute programs
+	execFunctions = []string{"execve", "execveat", "execv", "execvp", "execvpe", "execl",
+		"execlp", "execle", "fexecve", "system", "popen", "posix_spawn", "posix_spawnp"}
+	// Functions and system calls which create threads (clone is also used by
+	// fork, see CloneCreatesThread)
+	threadFunctions = []string{"pthread_create", "thrd_create", "clone3"}
+)
+
+// Penalty represents the points lost by an application for a criterion.
+type Penalty struct {
+	Criterion string   `json:"criterion"`
+	Count     int      `json:"count"`
+	Points    int      `json:"points"`
+	Items     []string `json:"items,omitempty"`
+}
+
+// SyscallsReadiness represents the support of the system calls of an
+// application by Unikraft.
+type SyscallsReadiness struct {
+	Total       int      `json:"total"`
+	Implemented int      `json:"implemented"`
+	Stubbed     []string `json:"stubbed"`
+	Missing     []string `json:"missing"`
+}
+
+// LibcReadiness represents the support of the libc functions of an
+// application by the libc micro-lib.
+type LibcReadiness struct {
+	MicroLib  string   `json:"micro_lib"`
+	Suggested string   `json:"suggested,omitempty"`
+	Total     int      `json:"total"`
+	Missing   []string `json:"missing"`
+}
+
+// Readiness represents the port-readiness of an application.
+type Readiness struct {
+	Program             string             `json:"program"`
+	Score               int                `json:"score"`
+	SystemCalls         *SyscallsReadiness `json:"system_calls,omitempty"`
+	Libc                *LibcReadiness     `json:"libc,omitempty"`
+	Fork                []string           `json:"fork"`
+	Exec                []string           `json:"exec"`
+	Threads             []string           `json:"threads"`
+	IPC                 []string           `json:"ipc"`
+	UnmatchedSharedLibs []string           `json:"unmatched_shared_libs"`
+	MicroLibs           []string           `json:"micro_libs"`
+	Penalties           []Penalty          `json:"penalties"`
+	Warnings            []string           `json:"warnings,omitempty"`
+}
+
+// usedFunctions returns the given functions which are used by the
+// application (symbols or system calls of any analysis).
+//
+// It returns the sorted names of the used functions.
+func usedFunctions(data *u.Data, functions []string) []string {
+
+	used := make([]string, 0)
+	for _, name := range functions {
+		for _, symbols := range []map[string]string{data.StaticData.Symbols,
+			data.DynamicData.Symbols, data.SourcesData.Symbols} {
+			if _, ok := symbols[name]; ok && !u.Contains(used, name) {
+				used = append(used, name)
+			}
+		}
+		for _, systemCalls := range []map[string]int{data.StaticData.SystemCalls,
+			data.DynamicData.SystemCalls, data.SourcesData.SystemCalls} {
+			if _, ok := systemCalls[name]; ok && !u.Contains(used, name) {
+				used = append(used, name)
+			}
+		}
+	}
+	sort.Strings(used)
+	return used
+}
+
+// computeReadiness computes the port-readiness of an application from the
+// results of the dependency analysis and the Unikraft checkout.
+//
+// It returns a pointer to a Readiness structure.
+func computeReadiness(programName, workspace string, data *u.Data) *Readiness {
+
+	r := &Readiness{
+		Program: programName,
+		Fork:    usedFunctions(data, forkFunctions),
+		Exec:    usedFunctions(data, execFunctions),
+		Threads: usedFunctions(data, threadFunctions),
+		IPC:     []string{},
+	}
+
+	// Threads created by clone (CLONE_THREAD) at runtime
+	if len(data.DynamicData.Threads) > 0 {
+		r.Threads = append(r.Threads, "clone")
+		sort.Strings(r.Threads)
+	}
+
+	// Use the execution patterns of the dependency analysis if any (they
+	// exclude the functions of the shared libraries and the threads)
+	if len(data.ExecutionPatterns) > 0 {
+		r.Fork, r.Exec = []string{}, []string{}
+		for _, p := range data.ExecutionPatterns {
+			switch p.Category {
+			case u.PATTERN_PROCESS:
+				r.Fork = append(r.Fork, p.Name)
+			case u.PATTERN_EXECUTION:
+				r.Exec = append(r.Exec, p.Name)
+			case u.PATTERN_SYSV_IPC:
+				r.IPC = append(r.IPC, p.Name)
+			}
+		}
+	}
+
+	items := map[string][]string{
+		forkCriterion:    r.Fork,
+		execCriterion:    r.Exec,
+		threadsCriterion: r.Threads,
+		ipcCriterion:     r.IPC,
+	}
+	counts := make(map[string]int)
+
+	// System calls
+	if support, err := dependtool.SystemCallSupport(workspace, data); err != nil {
+		r.Warnings = append(r.Warnings, "system calls are not scored (cannot find "+
+			"Unikraft: "+err.Error()+")")
+	} else {
+		r.SystemCalls = &SyscallsReadiness{Total: len(support), Stubbed: []string{},
+			Missing: []string{}}
+		for _, s := range support {
+			switch s.Status {
+			case u.SYSCALL_IMPLEMENTED:
+				r.SystemCalls.Implemented++
+			case u.SYSCALL_STUBBED:
+				r.SystemCalls.Stubbed = append(r.SystemCalls.Stubbed, s.Name)
+			default:
+				r.SystemCalls.Missing = append(r.SystemCalls.Missing, s.Name)
+			}
+		}
+		items[missingSyscallsCriterion] = r.SystemCalls.Missing
+		items[stubbedSyscallsCriterion] = r.SystemCalls.Stubbed
+	}
+
+	// Micro-libs, libc functions and shared libraries
+	if matching, err := buildtool.MatchMicroLibs(data); err != nil {
+		r.Warnings = append(r.Warnings, "micro-libs are not scored ("+err.Error()+")")
+	} else {
+		r.Libc = &LibcReadiness{
+			MicroLib:  matching.Libc.Libc,
+			Suggested: matching.Libc.Suggested,
+			Total:     matching.Libc.Functions,
+			Missing:   append([]string{}, matching.Libc.Missing...),
+		}
+		r.UnmatchedSharedLibs = matching.UnmatchedSharedLibs
+		r.MicroLibs = matching.MicroLibs
+		items[missingLibcCriterion] = r.Libc.Missing
+		items[sharedLibsCriterion] = r.UnmatchedSharedLibs
+		if len(r.MicroLibs) > baseMicroLibs {
+			counts[microLibsCriterion] = len(r.MicroLibs) - baseMicroLibs
+		}
+	}
+
+	// Compute the penalties
+	r.Score = maxScore
+	for _, c := range criteria {
+		count, ok := counts[c.name]
+		if !ok {
+			count = len(items[c.name])
+		}
+		points := count * c.weight
+		if points > c.max {
+			points = c.max
+		}
+		r.Penalties = append(r.Penalties, Penalty{Criterion: c.name, Count: count,
+			Points: points, Items: items[c.name]})
+		r.Score -= points
+	}
+	if r.Score < 0 {
+		r.Score = 0
+	}
+	return r
+}
+
+// listItems formats a list of items for the Markdown report.
+//
+// It returns the formatted list.
+func listItems(items []string) string {
+	if len(items) == 0 {
+		return "-"
+	}
+	return "`" + strings.Join(items, "`, `") + "`"
+}
+
+// markdown formats the port-readiness of an application as a Markdown
+// report.
+//
+// It returns the Markdown report.
+func (r *Readiness) markdown() string {
+
+	var sb strings.Builder
+	sb.WriteString("# Port-readiness of " + r.Program + "\n\n")
+	sb.WriteString(fmt.Sprintf("**Score: %d/%d**\n\n", r.Score, maxScore))
+	for _, warning := range r.Warnings {
+		sb.WriteString("> Warning: " + warning + "\n\n")
+	}
+
+	sb.WriteString("| Criterion | Count | Penalty |\n|---|---:|---:|\n")
+	for _, p := range r.Penalties {
+		sb.WriteString(fmt.Sprintf("| %s | %d | -%d |\n", p.Criterion, p.Count, p.Points))
+	}
+
+	if r.SystemCalls != nil {
+		sb.WriteString(fmt.Sprintf("\n## System calls\n\n%d system calls: %d implemented, "+
+			"%d stubbed, %d missing.\n\n", r.SystemCalls.Total, r.SystemCalls.Implemented,
+			len(r.SystemCalls.Stubbed), len(r.SystemCalls.Missing)))
+		sb.WriteString("- Missing: " + listItems(r.SystemCalls.Missing) + "\n")
+		sb.WriteString("- Stubbed: " + listItems(r.SystemCalls.Stubbed) + "\n")
+	}
+
+	if r.Libc != nil {
+		suggestion := "only " + r.Libc.MicroLib + " is checked"
+		if len(r.Libc.Suggested) > 0 {
+			suggestion = "suggested libc: " + r.Libc.Suggested
+		}
+		sb.WriteString(fmt.Sprintf("\n## libc\n\n%d libc functions, %d missing from %s "+
+			"(%s).\n\n", r.Libc.Total, len(r.Libc.Missing), r.Libc.MicroLib, suggestion))
+		sb.WriteString("- Missing: " + listItems(r.Libc.Missing) + "\n")
+	}
+
+	sb.WriteString("\n## Processes and threads\n\n")
+	sb.WriteString("- Process creation: " + listItems(r.Fork) + "\n")
+	sb.WriteString("- Program execution: " + listItems(r.Exec) + "\n")
+	sb.WriteString("- Threading: " + listItems(r.Threads) + "\n")
+	sb.WriteString("- SysV IPC: " + listItems(r.IPC) + "\n")
+
+	sb.WriteString(fmt.Sprintf("\n## Micro-libs\n\n%d micro-libs (estimation).\n\n",
+		len(r.MicroLibs)))
+	sb.WriteString("- Micro-libs: " + listItems(r.MicroLibs) + "\n")
+	sb.WriteString("- Unmatched shared libraries: " + listItems(r.UnmatchedSharedLibs) + "\n")
+	return sb.String()
+}
+
+// rankingMarkdown formats the ranking of several applications (sorted by
+// score) as a Markdown report.
+//
+// It returns the Markdown report.
+func rankingMarkdown(ranking []*Readiness) string {
+
+	var sb strings.Builder
+	sb.WriteString("# Port-readiness ranking\n\n")
+	sb.WriteString("| Rank | Program | Score | Missing syscalls | Missing libc | Fork/exec/IPC " +
+		"| Threads | Unmatched libs | Micro-libs |\n" +
+		"|---:|---|---:|---:|---:|:-:|:-:|---:|---:|\n")
+
+	for i, r := range ranking {
+		missingSyscalls, missingLibc := "?", "?"
+		if r.SystemCalls != nil {
+			missingSyscalls = fmt.Sprint(len(r.SystemCalls.Missing))
+		}
+		if r.Libc != nil {
+			missingLibc = fmt.Sprint(len(r.Libc.Missing))
+		}
+		forkExec, threads := "no", "no"
+		if len(r.Fork)+len(r.Exec)+len(r.IPC) > 0 {
+			forkExec = "yes"
+		}
+		if len(r.Threads) > 0 {
+			threads = "yes"
+		}
+		sb.WriteString(fmt.Sprintf("| %d | %s | %d | %s | %s | %s | %s | %d | %d |\n", i+1,
+			r.Program, r.Score, missingSyscalls, missingLibc, forkExec, threads,
+			len(r.UnmatchedSharedLibs), len(r.MicroLibs)))
+	}
+	return sb.String()
+}
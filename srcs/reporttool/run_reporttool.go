@@ -0,0 +1,105 @@
+// Copyright 2019 The UNICORE Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+//
+// Author: Gaulthier Gain <gaulthier.gain@uliege.be>
+
+package reporttool
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	u "tools/srcs/common"
+)
+
+// RunReportTool computes the port-readiness score of one or several
+// applications from the results of the dependency analysis and saves the
+// reports (Markdown and JSON). Several applications are ranked by score.
+func RunReportTool(homeDir string) {
+
+	// Init and parse local arguments
+	args := new(u.Arguments)
+	p, err := args.InitArguments("--report",
+		"The Report tool computes the port-readiness score of applications")
+	if err != nil {
+		u.PrintErr(err)
+	}
+	if err := parseLocalArguments(p, args); err != nil {
+		u.PrintErr(err)
+	}
+
+	workspace := homeDir + u.SEP + u.WORKSPACEFOLDER
+	if len(*args.StringArg[workspaceArg]) > 0 {
+		workspace = *args.StringArg[workspaceArg]
+	}
+
+	ranking := make([]*Readiness, 0)
+	for _, programName := range *args.StringListArg[programArg] {
+
+		// Take base path if absolute path is used
+		if filepath.IsAbs(programName) {
+			programName = filepath.Base(programName)
+		}
+
+		u.PrintHeader2("(*) Port-readiness of " + programName)
+		outFolder := homeDir + u.SEP + programName + "_" + u.OUTFOLDER
+		data, err := u.ReadDataJson(outFolder+programName, new(u.Data))
+		if err != nil {
+			u.PrintWarning("Cannot read the dependency analysis of " + programName +
+				" (run --dep first): " + err.Error())
+			continue
+		}
+
+		r := computeReadiness(programName, workspace, data)
+		for _, warning := range r.Warnings {
+			u.PrintWarning(warning)
+		}
+		for _, penalty := range r.Penalties {
+			if penalty.Points > 0 {
+				u.PrintInfo(fmt.Sprintf("%s: %d (-%d)", penalty.Criterion, penalty.Count,
+					penalty.Points))
+			}
+		}
+		u.PrintOk(fmt.Sprintf("Score of %s: %d/%d", programName, r.Score, maxScore))
+
+		// Save the reports into the output folder of the program
+		filename := outFolder + programName + "_readiness"
+		if err := u.WriteToFile(filename+".md", []byte(r.markdown())); err != nil {
+			u.PrintWarning(err)
+		} else if err := u.RecordDataJson(filename, r); err != nil {
+			u.PrintWarning(err)
+		} else {
+			u.PrintOk("Reports saved into " + filename + ".{md,json}")
+		}
+		ranking = append(ranking, r)
+	}
+
+	if len(ranking) < 2 {
+		return
+	}
+
+	// Rank the programs (highest score first)
+	sort.SliceStable(ranking, func(i, j int) bool {
+		return ranking[i].Score > ranking[j].Score
+	})
+
+	u.PrintHeader2("(*) Port-readiness ranking")
+	for i, r := range ranking {
+		u.PrintInfo(fmt.Sprintf("%d. %s (%d/%d)", i+1, r.Program, r.Score, maxScore))
+	}
+
+	outFolder := homeDir + u.SEP
+	if len(*args.StringArg[outputArg]) > 0 {
+		outFolder = strings.TrimSuffix(*args.StringArg[outputArg], u.SEP) + u.SEP
+	}
+	filename := outFolder + "readiness_ranking"
+	if err := u.WriteToFile(filename+".md", []byte(rankingMarkdown(ranking))); err != nil {
+		u.PrintWarning(err)
+	} else if err := u.RecordDataJson(filename, ranking); err != nil {
+		u.PrintWarning(err)
+	} else {
+		u.PrintOk("Ranking saved into " + filename + ".{md,json}")
+	}
+}
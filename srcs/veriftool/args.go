@@ -37,6 +37,7 @@ func parseLocalArguments(p *argparse.Parser, args *u.Arguments) error {
 
 	args.InitArgParse(p, args, u.STRING, "u", workspaceArg,
 		&argparse.Options{Required: false, Help: "Workspace Path"})
+	args.InitBatchArgParse(p)
 
 	return u.ParserWrapper(p, os.Args)
 }
@@ -33,6 +33,12 @@ func RunVerificationTool(homeDir string) {
 		u.PrintErr(err)
 	}
 
+	// Get the answers of the batch mode
+	answers, err := u.ReadAnswers(args)
+	if err != nil {
+		u.PrintErr(err)
+	}
+
 	// Get program path
 	programPath, err := u.GetProgramPath(&*args.StringArg[programArg])
 	if err != nil {
@@ -60,6 +66,12 @@ func RunVerificationTool(homeDir string) {
 		appFolder = workspacePath + u.APPSFOLDER + programName + u.SEP
 	}
 
+	// The app folder may have been renamed by the build tool (batch mode)
+	if answers.Batch && answers.AppFolder != "" && answers.AppFolder != u.OVERWRITE &&
+		answers.AppFolder != u.EXIT {
+		appFolder = strings.TrimSuffix(answers.AppFolder, u.SEP) + u.SEP
+	}
+
 	// Get the build folder
 	buildAppFolder := appFolder + u.BUILDFOLDER
 
@@ -142,7 +154,12 @@ func RunVerificationTool(homeDir string) {
 		}
 	}
 
-	c := askForConfirmation("Do you want to see a diff between the two output")
+	var c bool
+	if answers.Batch {
+		c = answers.DiffShown()
+	} else {
+		c = askForConfirmation("Do you want to see a diff between the two output")
+	}
 	if c {
 		u.PrintInfo("Comparison output:")
 